@@ -0,0 +1,5 @@
+// Package presencepb contains Go messages and gRPC stubs of PresenceService, generated from api/presence.proto, so
+// clients written in Go don't have to generate them
+package presencepb
+
+//go:generate protoc -I .. --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative ../presence.proto
@@ -0,0 +1,195 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package presencepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// PresenceServiceClient is the client API for PresenceService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PresenceServiceClient interface {
+	// StreamSamples streams samples of each scrapping process, that match filter
+	StreamSamples(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (PresenceService_StreamSamplesClient, error)
+	// StreamChanges streams changes of users found between scrapping processes, that match filter
+	StreamChanges(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (PresenceService_StreamChangesClient, error)
+}
+
+type presenceServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPresenceServiceClient(cc grpc.ClientConnInterface) PresenceServiceClient {
+	return &presenceServiceClient{cc}
+}
+
+func (c *presenceServiceClient) StreamSamples(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (PresenceService_StreamSamplesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &PresenceService_ServiceDesc.Streams[0], "/discordusermonitor.v1.PresenceService/StreamSamples", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &presenceServiceStreamSamplesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type PresenceService_StreamSamplesClient interface {
+	Recv() (*PresenceSample, error)
+	grpc.ClientStream
+}
+
+type presenceServiceStreamSamplesClient struct {
+	grpc.ClientStream
+}
+
+func (x *presenceServiceStreamSamplesClient) Recv() (*PresenceSample, error) {
+	m := new(PresenceSample)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *presenceServiceClient) StreamChanges(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (PresenceService_StreamChangesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &PresenceService_ServiceDesc.Streams[1], "/discordusermonitor.v1.PresenceService/StreamChanges", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &presenceServiceStreamChangesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type PresenceService_StreamChangesClient interface {
+	Recv() (*ChangeEvent, error)
+	grpc.ClientStream
+}
+
+type presenceServiceStreamChangesClient struct {
+	grpc.ClientStream
+}
+
+func (x *presenceServiceStreamChangesClient) Recv() (*ChangeEvent, error) {
+	m := new(ChangeEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PresenceServiceServer is the server API for PresenceService service.
+// All implementations must embed UnimplementedPresenceServiceServer
+// for forward compatibility
+type PresenceServiceServer interface {
+	// StreamSamples streams samples of each scrapping process, that match filter
+	StreamSamples(*SubscribeRequest, PresenceService_StreamSamplesServer) error
+	// StreamChanges streams changes of users found between scrapping processes, that match filter
+	StreamChanges(*SubscribeRequest, PresenceService_StreamChangesServer) error
+	mustEmbedUnimplementedPresenceServiceServer()
+}
+
+// UnimplementedPresenceServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedPresenceServiceServer struct {
+}
+
+func (UnimplementedPresenceServiceServer) StreamSamples(*SubscribeRequest, PresenceService_StreamSamplesServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamSamples not implemented")
+}
+func (UnimplementedPresenceServiceServer) StreamChanges(*SubscribeRequest, PresenceService_StreamChangesServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamChanges not implemented")
+}
+func (UnimplementedPresenceServiceServer) mustEmbedUnimplementedPresenceServiceServer() {}
+
+// UnsafePresenceServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PresenceServiceServer will
+// result in compilation errors.
+type UnsafePresenceServiceServer interface {
+	mustEmbedUnimplementedPresenceServiceServer()
+}
+
+func RegisterPresenceServiceServer(s grpc.ServiceRegistrar, srv PresenceServiceServer) {
+	s.RegisterService(&PresenceService_ServiceDesc, srv)
+}
+
+func _PresenceService_StreamSamples_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PresenceServiceServer).StreamSamples(m, &presenceServiceStreamSamplesServer{stream})
+}
+
+type PresenceService_StreamSamplesServer interface {
+	Send(*PresenceSample) error
+	grpc.ServerStream
+}
+
+type presenceServiceStreamSamplesServer struct {
+	grpc.ServerStream
+}
+
+func (x *presenceServiceStreamSamplesServer) Send(m *PresenceSample) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _PresenceService_StreamChanges_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PresenceServiceServer).StreamChanges(m, &presenceServiceStreamChangesServer{stream})
+}
+
+type PresenceService_StreamChangesServer interface {
+	Send(*ChangeEvent) error
+	grpc.ServerStream
+}
+
+type presenceServiceStreamChangesServer struct {
+	grpc.ServerStream
+}
+
+func (x *presenceServiceStreamChangesServer) Send(m *ChangeEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// PresenceService_ServiceDesc is the grpc.ServiceDesc for PresenceService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PresenceService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "discordusermonitor.v1.PresenceService",
+	HandlerType: (*PresenceServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamSamples",
+			Handler:       _PresenceService_StreamSamples_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamChanges",
+			Handler:       _PresenceService_StreamChanges_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "presence.proto",
+}
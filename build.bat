@@ -3,4 +3,4 @@ SET GO111MODULE=on
 
 mkdir bin
 
-go build -o bin\scrapper.exe cmd\scrapper\main.go
+go build -o bin\scrapper.exe .\cmd\scrapper
@@ -1 +1 @@
-go build -o ./bin/scrapper ./cmd/scrapper/main.go
+go build -o ./bin/scrapper ./cmd/scrapper
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// alertRule is a rule supplied with --alert, like 'user=Alice status=Online', it sends notification, when change of
+// user matches all its conditions. Rule isn't triggered again for the same user during cooldown, so flapping status
+// doesn't spam notifications.
+type alertRule struct {
+	text     string
+	users    []userPattern
+	server   string
+	from     string
+	to       string
+	events   []string
+	cooldown time.Duration
+
+	triggered map[string]time.Time // user -> time when rule was triggered last time
+}
+
+// parseAlertRule parses rule from space separated key=value conditions: user (name, ID or /regexp/), server, status
+// (new status), from (previous status), event (type of change, status and appeared by default) and cooldown
+func parseAlertRule(text string, cooldown time.Duration) (*alertRule, error) {
+	rule := &alertRule{
+		text:      text,
+		events:    []string{changeStatus, changeAppeared},
+		cooldown:  cooldown,
+		triggered: make(map[string]time.Time),
+	}
+
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("alert rule is empty")
+	}
+
+	for _, field := range fields {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("condition %q of alert rule %q isn't in key=value format", field, text)
+		}
+
+		key, value := strings.ToLower(parts[0]), parts[1]
+		switch key {
+		case "user":
+			patterns, err := compileUserPatterns([]string{value})
+			if err != nil {
+				return nil, err
+			}
+			rule.users = patterns
+		case "server":
+			rule.server = value
+		case "status", "to":
+			rule.to = alertStatus(value)
+		case "from":
+			rule.from = alertStatus(value)
+		case "event":
+			events := strings.Split(value, ",")
+			for _, event := range events {
+				if !containsString(changeTypes, event) {
+					return nil, fmt.Errorf("unknown change event %s in alert rule %q, known ones are: %s", event, text, strings.Join(changeTypes, ", "))
+				}
+			}
+			rule.events = events
+		case "cooldown":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("cooldown %q of alert rule %q isn't duration, like 10m", value, text)
+			}
+			rule.cooldown = d
+		default:
+			return nil, fmt.Errorf("unknown condition %s of alert rule %q, known ones are: user, server, status, from, event, cooldown", key, text)
+		}
+	}
+
+	return rule, nil
+}
+
+// alertStatus converts status of rule to status, as it's shown by Discord, so 'dnd' and 'online' can be used
+func alertStatus(value string) string {
+	if status, ok := discordStatuses[strings.ToLower(value)]; ok {
+		return status
+	}
+
+	return value
+}
+
+// match checks if change event matches all conditions of rule. Users appear in member lists of big servers, when
+// they come online, so previous status of appeared user is Offline.
+func (r *alertRule) match(event ChangeEvent) bool {
+	if !containsString(r.events, event.Type) {
+		return false
+	}
+	if r.server != "" && r.server != event.Server {
+		return false
+	}
+	if len(r.users) > 0 && !matchAny(r.users, User{ID: event.ID, Username: event.Username}) {
+		return false
+	}
+
+	from := event.From
+	if event.Type == changeAppeared && from == "" {
+		from = "Offline"
+	}
+	if r.from != "" && !strings.EqualFold(r.from, from) {
+		return false
+	}
+
+	return r.to == "" || strings.EqualFold(r.to, event.To)
+}
+
+// alertNotifier sends notification for each change event, that matches alert rule
+type alertNotifier struct {
+	rules []*alertRule
+}
+
+// newAlertNotifier parses alert rules, cooldown is used by rules, that don't have their own
+func newAlertNotifier(texts []string, cooldown time.Duration) (*alertNotifier, error) {
+	n := &alertNotifier{}
+	for _, text := range texts {
+		rule, err := parseAlertRule(text, cooldown)
+		if err != nil {
+			return nil, err
+		}
+		n.rules = append(n.rules, rule)
+	}
+
+	return n, nil
+}
+
+func (n *alertNotifier) WriteChanges(events []ChangeEvent) error {
+	now := time.Now()
+	for _, event := range events {
+		for _, rule := range n.rules {
+			if !rule.match(event) {
+				continue
+			}
+
+			key := event.Server + "\x00" + event.ID + "\x00" + event.Username
+			if last, ok := rule.triggered[key]; ok && now.Sub(last) < rule.cooldown {
+				logger.With("rule", rule.text).Debugf("Alert for %s is skipped, it's cooling down", event.Username)
+				continue
+			}
+			rule.triggered[key] = now
+
+			notify("alert", fmt.Sprintf("%s (alert: %s)", event, rule.text))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// userReport is an activity of user over all scrapping processes stored in output
+type userReport struct {
+	Server        string    `json:"server"`
+	ID            string    `json:"id"`
+	Username      string    `json:"username"`
+	Samples       int       `json:"samples"`        // amount of scrapping processes, where user was seen
+	OnlinePercent float64   `json:"online_percent"` // percent of samples, where user wasn't offline
+	Changes       int       `json:"changes"`        // amount of status changes
+	Status        string    `json:"status"`         // last status
+	FirstSeen     time.Time `json:"first_seen"`
+	LastSeen      time.Time `json:"last_seen"`
+}
+
+// analyzeUsers builds report of each user from stored users, reports are sorted by online percent, most active
+// users go first
+func analyzeUsers(users []User) []userReport {
+	// users are stored in order of scrapping processes, but they are sorted anyway, as several files may be merged
+	sort.SliceStable(users, func(i, j int) bool {
+		return users[i].StatusTime.Before(users[j].StatusTime.Time)
+	})
+
+	reports := make(map[string]*userReport)
+	online := make(map[string]int)
+	keys := make([]string, 0)
+	for _, user := range users {
+		key := user.Server + "/" + userKey(user)
+		r, ok := reports[key]
+		if !ok {
+			r = &userReport{Server: user.Server, ID: user.ID, FirstSeen: user.StatusTime.Time}
+			reports[key] = r
+			keys = append(keys, key)
+		} else if r.Status != user.Status {
+			r.Changes++
+		}
+
+		r.Username = user.Username
+		r.Status = user.Status
+		r.LastSeen = user.StatusTime.Time
+		r.Samples++
+		if user.Status != "Offline" {
+			online[key]++
+		}
+	}
+
+	result := make([]userReport, 0, len(keys))
+	for _, key := range keys {
+		r := reports[key]
+		r.OnlinePercent = float64(online[key]) / float64(r.Samples) * 100
+		result = append(result, *r)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].OnlinePercent > result[j].OnlinePercent
+	})
+
+	return result
+}
+
+// analyzeCommand prints report of each user's activity from stored users: how often user was online, how many
+// times status changed and when user was seen first and last time. Other reports are its subcommands.
+func analyzeCommand(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "timeline":
+			return analyzeTimelineCommand(args[1:])
+		case "uptime":
+			return analyzeUptimeCommand(args[1:])
+		case "heatmap":
+			return analyzeHeatmapCommand(args[1:])
+		case "daily":
+			return analyzeDailyCommand(args[1:])
+		case "report":
+			return analyzeReportCommand(args[1:])
+		case "chart":
+			return analyzeChartCommand(args[1:])
+		case "sessions":
+			return analyzeSessionsCommand(args[1:])
+		case "anomalies":
+			return analyzeAnomaliesCommand(args[1:])
+		}
+	}
+
+	flags := pflag.NewFlagSet("analyze", pflag.ExitOnError)
+	inputs := flags.StringArrayP("input", "i", nil, "path to file (csv, json or ndjson, optionally .gz) or SQLite database (sqlite://users.db), where users are read from, can be repeated")
+	server := flags.String("server", "", "ID or name of server, only its users are reported")
+	top := flags.Int("top", 0, "amount of most active users to report, 0 means all users")
+	format := flags.String("format", "text", "format of report (text or json)")
+	flags.Parse(args)
+
+	logger = newLogger(os.Stderr, levelInfo, false)
+
+	if len(*inputs) == 0 || (*format != "text" && *format != "json") {
+		fmt.Fprintln(os.Stderr, "Usage: scrapper analyze --input <file> [--server <server>] [--top <n>] [--format text|json]")
+		flags.PrintDefaults()
+		return 1
+	}
+
+	users, err := readAnalyzedUsers(*inputs, *server)
+	if err != nil {
+		logger.Errorf("Couldn't read users: %v", err)
+		return 1
+	}
+
+	reports := analyzeUsers(users)
+	if *top > 0 && len(reports) > *top {
+		reports = reports[:*top]
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(reports)
+	} else {
+		err = writeReportTable(os.Stdout, reports)
+	}
+	if err != nil {
+		logger.Errorf("Couldn't write report: %v", err)
+		return 1
+	}
+
+	return 0
+}
+
+// readAnalyzedUsers reads users from all inputs, only users of server are kept, if it's supplied
+func readAnalyzedUsers(inputs []string, server string) ([]User, error) {
+	users := make([]User, 0)
+	for _, input := range inputs {
+		inputUsers, err := readUsers(input)
+		if err != nil {
+			return nil, fmt.Errorf("reading users of %s: %w", input, err)
+		}
+
+		for _, user := range inputUsers {
+			if server == "" || user.Server == server {
+				users = append(users, user)
+			}
+		}
+	}
+
+	return users, nil
+}
+
+// writeReportTable writes reports as table aligned with spaces
+func writeReportTable(w io.Writer, reports []userReport) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVER\tUSERNAME\tID\tSAMPLES\tONLINE\tCHANGES\tSTATUS\tFIRST SEEN\tLAST SEEN")
+	for _, r := range reports {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%.1f%%\t%d\t%s\t%s\t%s\n", r.Server, r.Username, r.ID, r.Samples, r.OnlinePercent,
+			r.Changes, r.Status, r.FirstSeen.Format(timeFormat), r.LastSeen.Format(timeFormat))
+	}
+
+	return tw.Flush()
+}
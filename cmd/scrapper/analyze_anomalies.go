@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// types of anomalies
+const (
+	anomalyUnusualHour  = "unusual_hour"  // user is online at hour of day, when user was never online before
+	anomalyActivityDrop = "activity_drop" // much less users of server are online, than usually at this time of week
+)
+
+// anomaly is an unusual presence pattern, found by comparing recent samples with history
+type anomaly struct {
+	Type     string    `json:"type"`
+	Server   string    `json:"server"`
+	ID       string    `json:"id,omitempty"`
+	Username string    `json:"username,omitempty"`
+	Time     time.Time `json:"time"`
+	Value    float64   `json:"value"`    // users online during hour for drop of activity, hour of day for unusual hour
+	Expected float64   `json:"expected"` // users online usually for drop of activity, days of history for unusual hour
+}
+
+// String describes anomaly for report and notifications, eg: 'alice is online at 04:00 for the first time in 14
+// days of history'
+func (a anomaly) String() string {
+	switch a.Type {
+	case anomalyUnusualHour:
+		return fmt.Sprintf("%s is online at %02.0f:00 on %s for the first time in %.0f days of history", a.Username, a.Value, a.Server, a.Expected)
+	case anomalyActivityDrop:
+		return fmt.Sprintf("%.1f users are online on %s, usually %.1f are online at this time of week", a.Value, a.Server, a.Expected)
+	default:
+		return a.Type
+	}
+}
+
+// anomalyOptions are thresholds of anomalies
+type anomalyOptions struct {
+	since      time.Time      // samples since it are checked, older ones are history
+	minHistory time.Duration  // user's history has to be at least that long, so unusual hours are found
+	drop       float64        // percent, by which users online have to drop below average
+	minOnline  float64        // average of users online, below which drops aren't reported, as small numbers vary a lot
+	location   *time.Location // location of hours of day
+}
+
+// userHours is a history of user, when user was sampled and online by hour of day
+type userHours struct {
+	samples, online [24]int
+	first, last     time.Time
+}
+
+// findAnomalies learns typical online hours of each user and typical amount of users online of each server by hour
+// of week from history, and finds recent samples, that don't match them. Each anomaly is reported once per hour.
+func findAnomalies(users []User, opts anomalyOptions) []anomaly {
+	var history, recent []User
+	for _, user := range users {
+		if user.StatusTime.Before(opts.since) {
+			history = append(history, user)
+		} else {
+			recent = append(recent, user)
+		}
+	}
+
+	anomalies := make([]anomaly, 0)
+	reported := make(map[string]bool)
+
+	hours := make(map[string]*userHours)
+	for _, user := range history {
+		key := user.Server + "/" + userKey(user)
+		h, ok := hours[key]
+		if !ok {
+			h = &userHours{first: user.StatusTime.Time}
+			hours[key] = h
+		}
+
+		hour := user.StatusTime.In(opts.location).Hour()
+		h.samples[hour]++
+		if user.Status != "Offline" {
+			h.online[hour]++
+		}
+		if user.StatusTime.Before(h.first) {
+			h.first = user.StatusTime.Time
+		}
+		if user.StatusTime.After(h.last) {
+			h.last = user.StatusTime.Time
+		}
+	}
+
+	sort.SliceStable(recent, func(i, j int) bool {
+		return recent[i].StatusTime.Before(recent[j].StatusTime.Time)
+	})
+	for _, user := range recent {
+		h, ok := hours[user.Server+"/"+userKey(user)]
+		if user.Status == "Offline" || !ok || h.last.Sub(h.first) < opts.minHistory {
+			continue
+		}
+
+		// hour, when user wasn't sampled, isn't known, eg: tool is run only during day
+		t := user.StatusTime.In(opts.location)
+		if h.samples[t.Hour()] == 0 || h.online[t.Hour()] > 0 {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s/%s", anomalyUnusualHour, user.Server, userKey(user)) + t.Format("2006-01-02T15")
+		if reported[key] {
+			continue
+		}
+		reported[key] = true
+
+		anomalies = append(anomalies, anomaly{
+			Type:     anomalyUnusualHour,
+			Server:   user.Server,
+			ID:       user.ID,
+			Username: user.Username,
+			Time:     user.StatusTime.UTC(),
+			Value:    float64(t.Hour()),
+			Expected: float64(int(h.last.Sub(h.first) / (24 * time.Hour))),
+		})
+	}
+
+	// typical amount of users online is averaged over scrapping processes of the same hour of week
+	type hourOfWeek struct {
+		server    string
+		day, hour int
+	}
+	totals := make(map[hourOfWeek]float64)
+	counts := make(map[hourOfWeek]int)
+	for _, s := range splitSnapshots(history) {
+		t := s.Time.In(opts.location)
+		key := hourOfWeek{s.Server, weekdayIndex(t), t.Hour()}
+		totals[key] += float64(s.Online)
+		counts[key]++
+	}
+
+	// recent scrapping processes are averaged over each hour too, so single incomplete member list isn't reported
+	type recentHour struct {
+		server string
+		hour   time.Time
+	}
+	recentTotals := make(map[recentHour]float64)
+	recentCounts := make(map[recentHour]int)
+	recentOrder := make([]recentHour, 0)
+	for _, s := range splitSnapshots(recent) {
+		t := s.Time.In(opts.location)
+		key := recentHour{s.Server, time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, opts.location)}
+		if recentCounts[key] == 0 {
+			recentOrder = append(recentOrder, key)
+		}
+		recentTotals[key] += float64(s.Online)
+		recentCounts[key]++
+	}
+
+	for _, rh := range recentOrder {
+		key := hourOfWeek{rh.server, weekdayIndex(rh.hour), rh.hour.Hour()}
+		if counts[key] < 2 {
+			continue
+		}
+
+		average := totals[key] / float64(counts[key])
+		online := recentTotals[rh] / float64(recentCounts[rh])
+		if average < opts.minOnline || online >= average*(1-opts.drop/100) {
+			continue
+		}
+
+		anomalies = append(anomalies, anomaly{
+			Type:     anomalyActivityDrop,
+			Server:   rh.server,
+			Time:     rh.hour.UTC(),
+			Value:    online,
+			Expected: average,
+		})
+	}
+
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		return anomalies[i].Time.Before(anomalies[j].Time)
+	})
+
+	return anomalies
+}
+
+// analyzeAnomaliesCommand prints anomalies of recent samples, and sends them as notifications, so it can be run by
+// cron, eg: every hour with --since 1h
+func analyzeAnomaliesCommand(args []string) int {
+	flags := pflag.NewFlagSet("analyze anomalies", pflag.ExitOnError)
+	inputs := flags.StringArrayP("input", "i", nil, "path to file (csv, json or ndjson, optionally .gz) or SQLite database (sqlite://users.db), where users are read from, can be repeated")
+	server := flags.String("server", "", "ID or name of server, only its users are checked")
+	since := flags.String("since", "24h", "start of checked samples: duration before now (eg: 1h, 7d), date (2006-01-02) or RFC 3339 time, older samples are history")
+	minHistory := flags.String("min-history", "7d", "minimum history of user (eg: 14d), before hours of day, when user is online, are known")
+	drop := flags.Float64("drop", 50, "percent, by which amount of users online has to drop below average of the same hour of week")
+	minOnline := flags.Float64("min-online", 5, "minimum average of users online, below which drops of activity aren't reported")
+	timezone := flags.String("timezone", "Local", "timezone of hours of day (UTC, Local, or IANA name)")
+	format := flags.String("format", "text", "format of report (text or json)")
+	output := flags.StringP("output", "o", "", "path to file, where report is written, stdout by default")
+	notifyAnomalies := flags.Bool("notify", false, "send each anomaly as notification (event anomaly)")
+	flags.StringVar(notifyWebhookURL, "notify-webhook-url", "", "URL where notifications are sent with POST request (in .json format)")
+	flags.StringVar(slackWebhookURL, "slack-webhook-url", "", "URL of Slack incoming webhook, where notifications are sent as messages")
+	flags.Parse(args)
+
+	logger = newLogger(os.Stderr, levelInfo, false)
+
+	if len(*inputs) == 0 || (*format != "text" && *format != "json") {
+		fmt.Fprintln(os.Stderr, "Usage: scrapper analyze anomalies --input <file> [--server <server>] [--since 24h] [--min-history 7d] [--drop 50] [--min-online 5] [--timezone <tz>] [--format text|json] [--output <file>] [--notify] [--notify-webhook-url <url>] [--slack-webhook-url <url>]")
+		flags.PrintDefaults()
+		return 1
+	}
+
+	location, err := time.LoadLocation(*timezone)
+	if err != nil {
+		logger.Errorf("Couldn't load timezone %s: %v", *timezone, err)
+		return 1
+	}
+
+	opts := anomalyOptions{drop: *drop, minOnline: *minOnline, location: location}
+	opts.since, err = parseQueryTime(*since, time.Now(), false)
+	if err != nil {
+		logger.Errorf("Couldn't parse --since: %v", err)
+		return 1
+	}
+	opts.minHistory, err = parseAge(*minHistory)
+	if err != nil {
+		logger.Errorf("Couldn't parse --min-history: %v", err)
+		return 1
+	}
+
+	users, err := readAnalyzedUsers(*inputs, *server)
+	if err != nil {
+		logger.Errorf("Couldn't read users: %v", err)
+		return 1
+	}
+
+	anomalies := findAnomalies(users, opts)
+	if *notifyAnomalies {
+		for _, a := range anomalies {
+			notify("anomaly", a.String())
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			logger.Errorf("Couldn't create output file: %v", err)
+			return 1
+		}
+		defer file.Close()
+
+		w = file
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(anomalies)
+	} else {
+		err = writeAnomaliesTable(w, anomalies, location)
+	}
+	if err != nil {
+		logger.Errorf("Couldn't write anomalies: %v", err)
+		return 1
+	}
+
+	return 0
+}
+
+// writeAnomaliesTable writes anomalies as table aligned with spaces, times are shown in location
+func writeAnomaliesTable(w io.Writer, anomalies []anomaly, location *time.Location) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tTYPE\tSERVER\tDESCRIPTION")
+	for _, a := range anomalies {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", a.Time.In(location).Format(timeFormat), a.Type, a.Server, a)
+	}
+
+	return tw.Flush()
+}
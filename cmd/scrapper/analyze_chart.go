@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// statusSeries returns amount of users in each status in each scrapping process of one server, all series have the
+// same points, so they can be stacked
+func statusSeries(snapshots []snapshot) []chartSeries {
+	found := make(map[string]bool)
+	for _, s := range snapshots {
+		for status := range s.Statuses {
+			found[status] = true
+		}
+	}
+
+	statuses := make([]string, 0, len(found))
+	for _, status := range knownStatuses {
+		if found[status] {
+			statuses = append(statuses, status)
+			delete(found, status)
+		}
+	}
+	others := make([]string, 0, len(found))
+	for status := range found {
+		others = append(others, status)
+	}
+	sort.Strings(others)
+	statuses = append(statuses, others...)
+
+	series := make([]chartSeries, len(statuses))
+	for i, status := range statuses {
+		series[i].Name = status
+		for _, s := range snapshots {
+			series[i].Points = append(series[i].Points, chartPoint{Time: s.Time, Value: float64(s.Statuses[status])})
+		}
+	}
+
+	return series
+}
+
+// analyzeChartCommand draws chart of stored users as png or svg image: users online in each scrapping process, or
+// users in each status stacked on top of each other
+func analyzeChartCommand(args []string) int {
+	flags := pflag.NewFlagSet("analyze chart", pflag.ExitOnError)
+	inputs := flags.StringArrayP("input", "i", nil, "path to file (csv, json or ndjson, optionally .gz) or SQLite database (sqlite://users.db), where users are read from, can be repeated")
+	server := flags.String("server", "", "ID or name of server, only its users are drawn")
+	from := flags.String("from", "", "first date (2006-01-02) or time (RFC 3339) of range, since first sample by default")
+	to := flags.String("to", "", "last date (2006-01-02, inclusive) or time (RFC 3339, exclusive) of range, until last sample by default")
+	timezone := flags.String("timezone", "Local", "timezone of time axis (UTC, Local, or IANA name)")
+	kind := flags.String("type", "online", "type of chart: online (users online, line of each server) or statuses (users in each status, stacked areas of one server)")
+	title := flags.String("title", "", "title of chart, it's based on type of chart by default")
+	output := flags.StringP("output", "o", "", "path to .png or .svg file, where chart is written")
+	flags.Parse(args)
+
+	logger = newLogger(os.Stderr, levelInfo, false)
+
+	ext := strings.ToLower(filepath.Ext(*output))
+	if len(*inputs) == 0 || (ext != ".png" && ext != ".svg") || (*kind != "online" && *kind != "statuses") {
+		fmt.Fprintln(os.Stderr, "Usage: scrapper analyze chart --input <file> --output <file.png|file.svg> [--type online|statuses] [--server <server>] [--from <date>] [--to <date>] [--timezone <tz>] [--title <title>]")
+		flags.PrintDefaults()
+		return 1
+	}
+
+	location, err := time.LoadLocation(*timezone)
+	if err != nil {
+		logger.Errorf("Couldn't load timezone %s: %v", *timezone, err)
+		return 1
+	}
+
+	var rangeFrom, rangeTo time.Time
+	if *from != "" {
+		rangeFrom, err = parseRangeTime(*from, false)
+		if err != nil {
+			logger.Errorf("Couldn't parse --from: %v", err)
+			return 1
+		}
+	}
+	if *to != "" {
+		rangeTo, err = parseRangeTime(*to, true)
+		if err != nil {
+			logger.Errorf("Couldn't parse --to: %v", err)
+			return 1
+		}
+	}
+
+	users, err := readAnalyzedUsers(*inputs, *server)
+	if err != nil {
+		logger.Errorf("Couldn't read users: %v", err)
+		return 1
+	}
+
+	users = filterTimeRange(users, rangeFrom, rangeTo)
+	if len(users) == 0 {
+		logger.Errorf("Couldn't find users sampled in range")
+		return 1
+	}
+
+	snapshots := splitSnapshots(users)
+	for i := range snapshots {
+		snapshots[i].Time = snapshots[i].Time.In(location)
+	}
+
+	var series []chartSeries
+	if *kind == "statuses" {
+		servers := make(map[string]bool)
+		for _, s := range snapshots {
+			servers[s.Server] = true
+		}
+		if len(servers) > 1 {
+			logger.Errorf("Couldn't draw statuses of %d servers, supply one of them with --server", len(servers))
+			return 1
+		}
+
+		series = statusSeries(snapshots)
+		if *title == "" {
+			*title = fmt.Sprintf("Users in each status on %s", snapshots[0].Server)
+		}
+	} else {
+		series = onlineSeries(snapshots)
+		if *title == "" {
+			*title = "Users online in each scrapping process"
+		}
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		logger.Errorf("Couldn't create output file: %v", err)
+		return 1
+	}
+	defer file.Close()
+
+	switch {
+	case ext == ".svg" && *kind == "statuses":
+		err = writeAreaChartSVG(file, *title, series)
+	case ext == ".svg":
+		err = writeLineChartSVG(file, *title, series)
+	case *kind == "statuses":
+		err = png.Encode(file, drawAreaChart(*title, series))
+	default:
+		err = png.Encode(file, drawLineChart(*title, series))
+	}
+	if err != nil {
+		logger.Errorf("Couldn't draw chart: %v", err)
+		return 1
+	}
+
+	logger.Infof("Chart of %d scrapping processes is written to %s", len(snapshots), *output)
+	return 0
+}
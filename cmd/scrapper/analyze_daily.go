@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// snapshot is a member list scrapped in one scrapping process
+type snapshot struct {
+	Server   string
+	Time     time.Time // time of first user in list
+	Members  int
+	Online   int
+	Statuses map[string]int // status -> amount of users
+}
+
+// splitSnapshots groups stored users into member lists of scrapping processes. Users of one process are stored one
+// after another, so next process starts, when user of the same list is seen again.
+func splitSnapshots(users []User) []snapshot {
+	sort.SliceStable(users, func(i, j int) bool {
+		return users[i].StatusTime.Before(users[j].StatusTime.Time)
+	})
+
+	snapshots := make([]snapshot, 0)
+	current := make(map[string]int)          // member list -> index of its last snapshot
+	seen := make(map[string]map[string]bool) // member list -> users of its last snapshot
+	for _, user := range users {
+		list := memberListKey(user)
+		key := userKey(user)
+
+		i, ok := current[list]
+		if !ok || seen[list][key] {
+			i = len(snapshots)
+			current[list] = i
+			seen[list] = make(map[string]bool)
+			snapshots = append(snapshots, snapshot{Server: user.Server, Time: user.StatusTime.Time, Statuses: make(map[string]int)})
+		}
+
+		seen[list][key] = true
+		snapshots[i].Members++
+		snapshots[i].Statuses[user.Status]++
+		if user.Status != "Offline" {
+			snapshots[i].Online++
+		}
+	}
+
+	return snapshots
+}
+
+// dailyReport is an activity of server during one day
+type dailyReport struct {
+	Server       string    `json:"server"`
+	Date         string    `json:"date"`
+	Snapshots    int       `json:"snapshots"`     // amount of scrapping processes
+	PeakOnline   int       `json:"peak_online"`   // highest amount of users online at the same time
+	PeakTime     time.Time `json:"peak_time"`     // time of scrapping process with peak
+	UniqueActive int       `json:"unique_active"` // amount of users, that were online at least once
+	Members      int       `json:"members"`       // amount of users in last scrapping process of day
+	Growth       int       `json:"growth"`        // change of members since previous day, 0 for first day
+}
+
+// analyzeDaily builds report of each day of each server, days are taken in location. Reports are sorted by server
+// and date.
+func analyzeDaily(users []User, location *time.Location) []dailyReport {
+	reports := make(map[string]*dailyReport)
+	keys := make([]string, 0)
+	report := func(server string, t time.Time) *dailyReport {
+		date := t.In(location).Format("2006-01-02")
+		key := server + "\x00" + date
+		r, ok := reports[key]
+		if !ok {
+			r = &dailyReport{Server: server, Date: date}
+			reports[key] = r
+			keys = append(keys, key)
+		}
+		return r
+	}
+
+	active := make(map[string]map[string]bool) // server and date -> users, that were online
+	for _, user := range users {
+		if user.Status == "Offline" {
+			continue
+		}
+
+		key := user.Server + "\x00" + user.StatusTime.In(location).Format("2006-01-02")
+		if active[key] == nil {
+			active[key] = make(map[string]bool)
+		}
+		active[key][userKey(user)] = true
+	}
+
+	for _, s := range splitSnapshots(users) {
+		r := report(s.Server, s.Time)
+		r.Snapshots++
+		r.Members = s.Members
+		if s.Online > r.PeakOnline || r.PeakTime.IsZero() {
+			r.PeakOnline = s.Online
+			r.PeakTime = s.Time
+		}
+	}
+
+	sort.Strings(keys)
+
+	result := make([]dailyReport, 0, len(keys))
+	for i, key := range keys {
+		r := reports[key]
+		r.UniqueActive = len(active[key])
+		if i > 0 && result[i-1].Server == r.Server {
+			r.Growth = r.Members - result[i-1].Members
+		}
+		result = append(result, *r)
+	}
+
+	return result
+}
+
+// analyzeDailyCommand prints peak of users online, unique active users and growth of members of each day
+func analyzeDailyCommand(args []string) int {
+	flags := pflag.NewFlagSet("analyze daily", pflag.ExitOnError)
+	inputs := flags.StringArrayP("input", "i", nil, "path to file (csv, json or ndjson, optionally .gz) or SQLite database (sqlite://users.db), where users are read from, can be repeated")
+	server := flags.String("server", "", "ID or name of server, only its days are reported")
+	from := flags.String("from", "", "first date (2006-01-02) or time (RFC 3339) of range, since first sample by default")
+	to := flags.String("to", "", "last date (2006-01-02, inclusive) or time (RFC 3339, exclusive) of range, until last sample by default")
+	timezone := flags.String("timezone", "Local", "timezone of days (UTC, Local, or IANA name)")
+	format := flags.String("format", "text", "format of report (text, csv or json)")
+	output := flags.StringP("output", "o", "", "path to file, where report is written, stdout by default")
+	flags.Parse(args)
+
+	logger = newLogger(os.Stderr, levelInfo, false)
+
+	if len(*inputs) == 0 || (*format != "text" && *format != "csv" && *format != "json") {
+		fmt.Fprintln(os.Stderr, "Usage: scrapper analyze daily --input <file> [--server <server>] [--from <date>] [--to <date>] [--timezone <tz>] [--format text|csv|json] [--output <file>]")
+		flags.PrintDefaults()
+		return 1
+	}
+
+	location, err := time.LoadLocation(*timezone)
+	if err != nil {
+		logger.Errorf("Couldn't load timezone %s: %v", *timezone, err)
+		return 1
+	}
+
+	var rangeFrom, rangeTo time.Time
+	if *from != "" {
+		rangeFrom, err = parseRangeTime(*from, false)
+		if err != nil {
+			logger.Errorf("Couldn't parse --from: %v", err)
+			return 1
+		}
+	}
+	if *to != "" {
+		rangeTo, err = parseRangeTime(*to, true)
+		if err != nil {
+			logger.Errorf("Couldn't parse --to: %v", err)
+			return 1
+		}
+	}
+
+	users, err := readAnalyzedUsers(*inputs, *server)
+	if err != nil {
+		logger.Errorf("Couldn't read users: %v", err)
+		return 1
+	}
+
+	users = filterTimeRange(users, rangeFrom, rangeTo)
+	if len(users) == 0 {
+		logger.Errorf("Couldn't find users sampled in range")
+		return 1
+	}
+
+	reports := analyzeDaily(users, location)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			logger.Errorf("Couldn't create output file: %v", err)
+			return 1
+		}
+		defer file.Close()
+
+		w = file
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(reports)
+	case "csv":
+		err = writeDailyCSV(w, reports)
+	default:
+		err = writeDailyTable(w, reports, location)
+	}
+	if err != nil {
+		logger.Errorf("Couldn't write report: %v", err)
+		return 1
+	}
+
+	return 0
+}
+
+// writeDailyTable writes reports as table aligned with spaces, time of peak is shown in location
+func writeDailyTable(w io.Writer, reports []dailyReport, location *time.Location) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVER\tDATE\tSNAPSHOTS\tPEAK ONLINE\tPEAK TIME\tUNIQUE ACTIVE\tMEMBERS\tGROWTH")
+	for _, r := range reports {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%d\t%d\t%+d\n", r.Server, r.Date, r.Snapshots, r.PeakOnline,
+			r.PeakTime.In(location).Format("15:04"), r.UniqueActive, r.Members, r.Growth)
+	}
+
+	return tw.Flush()
+}
+
+// writeDailyCSV writes reports as csv rows, time of peak is written in RFC 3339 format in UTC
+func writeDailyCSV(w io.Writer, reports []dailyReport) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"server", "date", "snapshots", "peak_online", "peak_time", "unique_active", "members", "growth"})
+	for _, r := range reports {
+		cw.Write([]string{r.Server, r.Date, strconv.Itoa(r.Snapshots), strconv.Itoa(r.PeakOnline),
+			r.PeakTime.UTC().Format(time.RFC3339), strconv.Itoa(r.UniqueActive), strconv.Itoa(r.Members),
+			strconv.Itoa(r.Growth)})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
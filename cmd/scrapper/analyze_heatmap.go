@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// weekdays are rows of heatmap, week starts on Monday
+var weekdays = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// heatmap is an activity by day of week and hour of day. For server it's average amount of users, that were online
+// during hour, for user it's percent of samples, where user was online.
+type heatmap struct {
+	Server string
+	User   string
+	Values [7][24]float64
+}
+
+// max returns highest value of heatmap
+func (h heatmap) max() float64 {
+	var max float64
+	for _, row := range h.Values {
+		for _, v := range row {
+			if v > max {
+				max = v
+			}
+		}
+	}
+
+	return max
+}
+
+// weekdayIndex returns row of heatmap for time, Monday is first
+func weekdayIndex(t time.Time) int {
+	return (int(t.Weekday()) + 6) % 7
+}
+
+// buildHeatmaps builds heatmap of each server, or of user on each server, if user is supplied. Hours are taken in
+// location, so heatmap shows local time of community.
+func buildHeatmaps(users []User, user string, location *time.Location) []heatmap {
+	type cell struct {
+		online  map[string]bool // users, that were online during hour
+		samples int
+		onlines int
+	}
+
+	// each hour of each date is a cell, so average is computed over all days, when tool was running
+	hours := make(map[string]map[time.Time]*cell)
+	for _, u := range users {
+		if user != "" && !isUser(u, user) {
+			continue
+		}
+
+		t := u.StatusTime.In(location)
+		hour := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, location)
+		if hours[u.Server] == nil {
+			hours[u.Server] = make(map[time.Time]*cell)
+		}
+		c, ok := hours[u.Server][hour]
+		if !ok {
+			c = &cell{online: make(map[string]bool)}
+			hours[u.Server][hour] = c
+		}
+
+		c.samples++
+		if u.Status != "Offline" {
+			c.online[userKey(u)] = true
+			c.onlines++
+		}
+	}
+
+	servers := make([]string, 0, len(hours))
+	for server := range hours {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+
+	heatmaps := make([]heatmap, 0, len(servers))
+	for _, server := range servers {
+		var sums, counts [7][24]float64
+		for hour, c := range hours[server] {
+			d, h := weekdayIndex(hour), hour.Hour()
+			if user != "" {
+				sums[d][h] += float64(c.onlines)
+				counts[d][h] += float64(c.samples)
+			} else {
+				sums[d][h] += float64(len(c.online))
+				counts[d][h]++
+			}
+		}
+
+		hm := heatmap{Server: server, User: user}
+		for d := range sums {
+			for h := range sums[d] {
+				if counts[d][h] == 0 {
+					continue
+				}
+				hm.Values[d][h] = sums[d][h] / counts[d][h]
+				if user != "" {
+					hm.Values[d][h] *= 100
+				}
+			}
+		}
+		heatmaps = append(heatmaps, hm)
+	}
+
+	return heatmaps
+}
+
+// analyzeHeatmapCommand writes heatmap of online users by day of week and hour of day, as csv and as chart
+func analyzeHeatmapCommand(args []string) int {
+	flags := pflag.NewFlagSet("analyze heatmap", pflag.ExitOnError)
+	inputs := flags.StringArrayP("input", "i", nil, "path to file (csv, json or ndjson, optionally .gz) or SQLite database (sqlite://users.db), where users are read from, can be repeated")
+	server := flags.String("server", "", "ID or name of server, only its heatmap is written")
+	user := flags.StringP("user", "u", "", "ID, username, nickname or global username of user, heatmap shows percent of samples, where user was online")
+	timezone := flags.String("timezone", "Local", "timezone of hours (UTC, Local, or IANA name)")
+	output := flags.StringP("output", "o", "", "path to csv file, where heatmap is written, stdout by default")
+	chart := flags.String("chart", "", "path to .png or .svg file, where heatmap is drawn, it's drawn for one server only")
+	flags.Parse(args)
+
+	logger = newLogger(os.Stderr, levelInfo, false)
+
+	chartExt := strings.ToLower(filepath.Ext(*chart))
+	if len(*inputs) == 0 || (*chart != "" && chartExt != ".png" && chartExt != ".svg") {
+		fmt.Fprintln(os.Stderr, "Usage: scrapper analyze heatmap --input <file> [--server <server>] [--user <name|id>] [--timezone <tz>] [--output <file.csv>] [--chart <file.png|file.svg>]")
+		flags.PrintDefaults()
+		return 1
+	}
+
+	location, err := time.LoadLocation(*timezone)
+	if err != nil {
+		logger.Errorf("Couldn't load timezone %s: %v", *timezone, err)
+		return 1
+	}
+
+	users, err := readAnalyzedUsers(*inputs, *server)
+	if err != nil {
+		logger.Errorf("Couldn't read users: %v", err)
+		return 1
+	}
+
+	heatmaps := buildHeatmaps(users, *user, location)
+	if len(heatmaps) == 0 {
+		logger.Errorf("Couldn't find users in input")
+		return 1
+	}
+	if *chart != "" && len(heatmaps) > 1 {
+		logger.Errorf("Couldn't draw chart of %d servers, supply one of them with --server", len(heatmaps))
+		return 1
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			logger.Errorf("Couldn't create output file: %v", err)
+			return 1
+		}
+		defer file.Close()
+
+		w = file
+	}
+
+	err = writeHeatmapCSV(w, heatmaps)
+	if err != nil {
+		logger.Errorf("Couldn't write heatmap: %v", err)
+		return 1
+	}
+
+	if *chart != "" {
+		file, err := os.Create(*chart)
+		if err != nil {
+			logger.Errorf("Couldn't create chart file: %v", err)
+			return 1
+		}
+		defer file.Close()
+
+		if chartExt == ".svg" {
+			err = writeHeatmapSVG(file, heatmaps[0])
+		} else {
+			err = png.Encode(file, drawHeatmap(heatmaps[0]))
+		}
+		if err != nil {
+			logger.Errorf("Couldn't draw chart: %v", err)
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// writeHeatmapCSV writes row of each day of week of each heatmap, with column of each hour
+func writeHeatmapCSV(w io.Writer, heatmaps []heatmap) error {
+	header := []string{"server", "day"}
+	for h := 0; h < 24; h++ {
+		header = append(header, strconv.Itoa(h))
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	for _, hm := range heatmaps {
+		for d, row := range hm.Values {
+			record := []string{hm.Server, weekdays[d]}
+			for _, v := range row {
+				record = append(record, strconv.FormatFloat(v, 'f', 1, 64))
+			}
+			cw.Write(record)
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// sizes of heatmap chart in pixels
+const (
+	heatmapCell   = 28
+	heatmapLeft   = 48
+	heatmapTop    = 48
+	heatmapMargin = 12
+)
+
+// heatmapColor returns color of value, it goes from light gray for 0 to Discord blurple for max
+func heatmapColor(value, max float64) color.RGBA {
+	share := 0.0
+	if max > 0 {
+		share = value / max
+	}
+
+	mix := func(from, to uint8) uint8 {
+		return uint8(float64(from) + (float64(to)-float64(from))*share)
+	}
+	return color.RGBA{R: mix(235, 88), G: mix(237, 101), B: mix(239, 242), A: 255}
+}
+
+// heatmapTitle returns title of chart
+func heatmapTitle(hm heatmap) string {
+	if hm.User != "" {
+		return fmt.Sprintf("%s on %s, percent of samples online", hm.User, hm.Server)
+	}
+
+	return fmt.Sprintf("%s, average users online", hm.Server)
+}
+
+// writeHeatmapSVG draws heatmap as svg, value of each cell is shown on hover
+func writeHeatmapSVG(w io.Writer, hm heatmap) error {
+	width := heatmapLeft + 24*heatmapCell + heatmapMargin
+	height := heatmapTop + 7*heatmapCell + heatmapMargin
+	max := hm.max()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="11">`+"\n", width, height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#ffffff"/>`+"\n", width, height)
+	fmt.Fprintf(&b, `<text x="%d" y="16" font-size="13">%s</text>`+"\n", heatmapMargin, escapeXML(heatmapTitle(hm)))
+	for h := 0; h < 24; h++ {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle">%d</text>`+"\n", heatmapLeft+h*heatmapCell+heatmapCell/2, heatmapTop-6, h)
+	}
+	for d, row := range hm.Values {
+		y := heatmapTop + d*heatmapCell
+		fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`+"\n", heatmapMargin, y+heatmapCell/2+4, weekdays[d])
+		for h, v := range row {
+			c := heatmapColor(v, max)
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="#%02x%02x%02x" stroke="#ffffff"><title>%s %02d:00 - %.1f</title></rect>`+"\n",
+				heatmapLeft+h*heatmapCell, y, heatmapCell, heatmapCell, c.R, c.G, c.B, weekdays[d], h, v)
+		}
+	}
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeXML escapes text, so it can be written inside svg element
+func escapeXML(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
+
+// drawHeatmap draws heatmap as image, with title and labels of days and hours
+func drawHeatmap(hm heatmap) image.Image {
+	width := heatmapLeft + 24*heatmapCell + heatmapMargin
+	height := heatmapTop + 7*heatmapCell + heatmapMargin
+	max := hm.max()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	fillRect(img, img.Bounds(), color.RGBA{R: 255, G: 255, B: 255, A: 255})
+
+	black := color.RGBA{A: 255}
+	drawLabel(img, heatmapMargin, 8, heatmapTitle(hm), black)
+	for h := 0; h < 24; h++ {
+		label := strconv.Itoa(h)
+		drawLabel(img, heatmapLeft+h*heatmapCell+(heatmapCell-labelWidth(label))/2, heatmapTop-18, label, black)
+	}
+	for d, row := range hm.Values {
+		y := heatmapTop + d*heatmapCell
+		drawLabel(img, heatmapMargin, y+(heatmapCell-10)/2, weekdays[d], black)
+		for h, v := range row {
+			x := heatmapLeft + h*heatmapCell
+			fillRect(img, image.Rect(x+1, y+1, x+heatmapCell-1, y+heatmapCell-1), heatmapColor(v, max))
+		}
+	}
+
+	return img
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// reportTemplate is a self-contained page with activity over date range, charts are embedded as svg, so it can be
+// sent by email
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #2e3338; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 4px 12px; border-bottom: 1px solid #ddd; text-align: left; }
+td.number { text-align: right; }
+svg { display: block; margin-bottom: 2em; max-width: 100%; height: auto; }
+.Online { color: #23a55a; } .Idle { color: #f0b232; } .Do.Not.Disturb { color: #f23f43; } .Offline { color: #80848e; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.From.Format "2006-01-02 15:04"}} - {{.To.Format "2006-01-02 15:04"}}, {{.Servers}} servers, {{.Users}} users, {{.Samples}} samples, {{.Snapshots}} scrapping processes</p>
+
+<h2>Users online</h2>
+{{.OnlineChart}}
+<table>
+<tr><th>Server</th><th>Date</th><th>Peak online</th><th>Peak time</th><th>Unique active</th><th>Members</th><th>Growth</th></tr>
+{{range .Daily}}<tr><td>{{.Server}}</td><td>{{.Date}}</td><td class="number">{{.PeakOnline}}</td><td>{{.PeakTime.Format "15:04"}}</td><td class="number">{{.UniqueActive}}</td><td class="number">{{.Members}}</td><td class="number">{{printf "%+d" .Growth}}</td></tr>
+{{end}}</table>
+
+<h2>Status distribution</h2>
+{{.StatusChart}}
+
+<h2>Top users</h2>
+<table>
+<tr><th>Server</th><th>Username</th><th>Samples</th><th>Online</th><th>Changes</th><th>Status</th><th>Last seen</th></tr>
+{{range .TopUsers}}<tr><td>{{.Server}}</td><td>{{.Username}}</td><td class="number">{{.Samples}}</td><td class="number">{{printf "%.1f" .OnlinePercent}}%</td><td class="number">{{.Changes}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{.LastSeen.Format "2006-01-02 15:04"}}</td></tr>
+{{end}}</table>
+
+<h2>Activity by hour</h2>
+{{range .Heatmaps}}{{.}}
+{{end}}
+<p>Generated by Discord User Monitor at {{.Generated.Format "2006-01-02 15:04"}}</p>
+</body>
+</html>
+`))
+
+// reportPage is a data of report template
+type reportPage struct {
+	Title     string
+	From, To  time.Time
+	Generated time.Time
+	Servers   int
+	Users     int
+	Samples   int
+	Snapshots int
+
+	OnlineChart template.HTML
+	StatusChart template.HTML
+	Heatmaps    []template.HTML
+	Daily       []dailyReport
+	TopUsers    []userReport
+}
+
+// statusShares returns percent of samples in each status, known statuses go first
+func statusShares(users []User) []chartBar {
+	counts := make(map[string]int)
+	for _, user := range users {
+		counts[user.Status]++
+	}
+
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		if !containsString(knownStatuses, status) {
+			statuses = append(statuses, status)
+		}
+	}
+	sort.Strings(statuses)
+
+	bars := make([]chartBar, 0, len(counts))
+	for _, status := range append(append([]string(nil), knownStatuses...), statuses...) {
+		if counts[status] > 0 {
+			bars = append(bars, chartBar{Label: status, Value: float64(counts[status]) / float64(len(users)) * 100})
+		}
+	}
+
+	return bars
+}
+
+// onlineSeries returns amount of users online in each scrapping process, series of each server
+func onlineSeries(snapshots []snapshot) []chartSeries {
+	index := make(map[string]int)
+	series := make([]chartSeries, 0)
+	for _, s := range snapshots {
+		i, ok := index[s.Server]
+		if !ok {
+			i = len(series)
+			index[s.Server] = i
+			series = append(series, chartSeries{Name: s.Server})
+		}
+		series[i].Points = append(series[i].Points, chartPoint{Time: s.Time, Value: float64(s.Online)})
+	}
+
+	return series
+}
+
+// buildReportPage builds report of users over date range from first to last sample, times are shown in location
+func buildReportPage(title string, users []User, top int, location *time.Location) (reportPage, error) {
+	page := reportPage{Title: title, Generated: time.Now(), Samples: len(users)}
+
+	servers := make(map[string]bool)
+	keys := make(map[string]bool)
+	for _, user := range users {
+		servers[user.Server] = true
+		keys[user.Server+"/"+userKey(user)] = true
+		if page.From.IsZero() || user.StatusTime.Before(page.From) {
+			page.From = user.StatusTime.Time
+		}
+		if user.StatusTime.After(page.To) {
+			page.To = user.StatusTime.Time
+		}
+	}
+	page.Servers, page.Users = len(servers), len(keys)
+	page.From, page.To, page.Generated = page.From.In(location), page.To.In(location), page.Generated.In(location)
+
+	snapshots := splitSnapshots(users)
+	page.Snapshots = len(snapshots)
+	for i := range snapshots {
+		snapshots[i].Time = snapshots[i].Time.In(location)
+	}
+
+	var b strings.Builder
+	err := writeLineChartSVG(&b, "Users online in each scrapping process", onlineSeries(snapshots))
+	if err != nil {
+		return page, fmt.Errorf("drawing online chart: %w", err)
+	}
+	page.OnlineChart = template.HTML(b.String())
+
+	b.Reset()
+	err = writeBarChartSVG(&b, "Percent of samples in each status", statusShares(users), "%")
+	if err != nil {
+		return page, fmt.Errorf("drawing status chart: %w", err)
+	}
+	page.StatusChart = template.HTML(b.String())
+
+	for _, hm := range buildHeatmaps(users, "", location) {
+		b.Reset()
+		err = writeHeatmapSVG(&b, hm)
+		if err != nil {
+			return page, fmt.Errorf("drawing heatmap: %w", err)
+		}
+		page.Heatmaps = append(page.Heatmaps, template.HTML(b.String()))
+	}
+
+	page.Daily = analyzeDaily(users, location)
+	for i := range page.Daily {
+		page.Daily[i].PeakTime = page.Daily[i].PeakTime.In(location)
+	}
+
+	page.TopUsers = analyzeUsers(users)
+	if top > 0 && len(page.TopUsers) > top {
+		page.TopUsers = page.TopUsers[:top]
+	}
+	for i := range page.TopUsers {
+		page.TopUsers[i].LastSeen = page.TopUsers[i].LastSeen.In(location)
+	}
+
+	return page, nil
+}
+
+// analyzeReportCommand writes html report of activity over date range: users online, status distribution, top
+// users and activity by hour
+func analyzeReportCommand(args []string) int {
+	flags := pflag.NewFlagSet("analyze report", pflag.ExitOnError)
+	inputs := flags.StringArrayP("input", "i", nil, "path to file (csv, json or ndjson, optionally .gz) or SQLite database (sqlite://users.db), where users are read from, can be repeated")
+	server := flags.String("server", "", "ID or name of server, only its users are reported")
+	from := flags.String("from", "", "first date (2006-01-02) or time (RFC 3339) of range, since first sample by default")
+	to := flags.String("to", "", "last date (2006-01-02, inclusive) or time (RFC 3339, exclusive) of range, until last sample by default")
+	timezone := flags.String("timezone", "Local", "timezone of days and hours (UTC, Local, or IANA name)")
+	top := flags.Int("top", 20, "amount of most active users in report, 0 means all users")
+	title := flags.String("title", "Discord activity report", "title of report")
+	output := flags.StringP("output", "o", "report.html", "path to html file, where report is written")
+	flags.Parse(args)
+
+	logger = newLogger(os.Stderr, levelInfo, false)
+
+	if len(*inputs) == 0 || *output == "" {
+		fmt.Fprintln(os.Stderr, "Usage: scrapper analyze report --input <file> [--server <server>] [--from <date>] [--to <date>] [--timezone <tz>] [--top 20] [--title <title>] [--output report.html]")
+		flags.PrintDefaults()
+		return 1
+	}
+
+	location, err := time.LoadLocation(*timezone)
+	if err != nil {
+		logger.Errorf("Couldn't load timezone %s: %v", *timezone, err)
+		return 1
+	}
+
+	var rangeFrom, rangeTo time.Time
+	if *from != "" {
+		rangeFrom, err = parseRangeTime(*from, false)
+		if err != nil {
+			logger.Errorf("Couldn't parse --from: %v", err)
+			return 1
+		}
+	}
+	if *to != "" {
+		rangeTo, err = parseRangeTime(*to, true)
+		if err != nil {
+			logger.Errorf("Couldn't parse --to: %v", err)
+			return 1
+		}
+	}
+
+	users, err := readAnalyzedUsers(*inputs, *server)
+	if err != nil {
+		logger.Errorf("Couldn't read users: %v", err)
+		return 1
+	}
+
+	users = filterTimeRange(users, rangeFrom, rangeTo)
+	if len(users) == 0 {
+		logger.Errorf("Couldn't find users sampled in range")
+		return 1
+	}
+
+	page, err := buildReportPage(*title, users, *top, location)
+	if err != nil {
+		logger.Errorf("Couldn't build report: %v", err)
+		return 1
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		logger.Errorf("Couldn't create output file: %v", err)
+		return 1
+	}
+	defer file.Close()
+
+	err = reportTemplate.Execute(file, page)
+	if err != nil {
+		logger.Errorf("Couldn't write report: %v", err)
+		return 1
+	}
+
+	logger.Infof("Report of %d users is written to %s", page.Users, *output)
+	return 0
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// statusInterval is a span of time, when user had the same status
+type statusInterval struct {
+	Server  string    `json:"server"`
+	Status  string    `json:"status"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Samples int       `json:"samples"` // amount of scrapping processes, where user had this status
+}
+
+// Duration returns length of interval
+func (i statusInterval) Duration() time.Duration {
+	return i.End.Sub(i.Start)
+}
+
+// isUser checks if stored user is the one supplied by ID or name, names are compared case insensitively
+func isUser(user User, value string) bool {
+	if user.ID != "" && user.ID == value {
+		return true
+	}
+
+	for _, name := range []string{user.Username, user.Nickname, user.GlobalUsername} {
+		if name != "" && strings.EqualFold(name, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// buildTimeline reconstructs status intervals of user from samples. Status is considered changed at time of sample,
+// where new status was seen first, so interval ends when next one starts. When samples are more than maxGap apart
+// (eg: tool wasn't running, or user wasn't in member list), interval ends at last sample before gap, 0 means samples
+// are never too far apart.
+func buildTimeline(users []User, user string, maxGap time.Duration) []statusInterval {
+	samples := make([]User, 0)
+	for _, u := range users {
+		if isUser(u, user) {
+			samples = append(samples, u)
+		}
+	}
+
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].StatusTime.Before(samples[j].StatusTime.Time)
+	})
+
+	// user can be on several servers, each of them has its own timeline
+	intervals := make([]statusInterval, 0)
+	current := make(map[string]int) // server -> index of its last interval
+	for _, sample := range samples {
+		t := sample.StatusTime.UTC()
+
+		i, ok := current[sample.Server]
+		switch {
+		case ok && (maxGap <= 0 || t.Sub(intervals[i].End) <= maxGap) && intervals[i].Status == sample.Status:
+			intervals[i].End = t
+			intervals[i].Samples++
+			continue
+		case ok && (maxGap <= 0 || t.Sub(intervals[i].End) <= maxGap):
+			intervals[i].End = t
+		}
+
+		current[sample.Server] = len(intervals)
+		intervals = append(intervals, statusInterval{
+			Server:  sample.Server,
+			Status:  sample.Status,
+			Start:   t,
+			End:     t,
+			Samples: 1,
+		})
+	}
+
+	sort.SliceStable(intervals, func(i, j int) bool {
+		if intervals[i].Server != intervals[j].Server {
+			return intervals[i].Server < intervals[j].Server
+		}
+		return intervals[i].Start.Before(intervals[j].Start)
+	})
+
+	return intervals
+}
+
+// analyzeTimelineCommand prints status intervals of one user, reconstructed from stored users
+func analyzeTimelineCommand(args []string) int {
+	flags := pflag.NewFlagSet("analyze timeline", pflag.ExitOnError)
+	inputs := flags.StringArrayP("input", "i", nil, "path to file (csv, json or ndjson, optionally .gz) or SQLite database (sqlite://users.db), where users are read from, can be repeated")
+	user := flags.StringP("user", "u", "", "ID, username, nickname or global username of user")
+	server := flags.String("server", "", "ID or name of server, only its timeline is reported")
+	maxGap := flags.Duration("max-gap", 10*time.Minute, "maximum time between samples of one interval, interval ends before longer gap, 0 disables gaps")
+	format := flags.String("format", "text", "format of report (text, csv or json)")
+	output := flags.StringP("output", "o", "", "path to file, where report is written, stdout by default")
+	flags.Parse(args)
+
+	logger = newLogger(os.Stderr, levelInfo, false)
+
+	if len(*inputs) == 0 || *user == "" || (*format != "text" && *format != "csv" && *format != "json") {
+		fmt.Fprintln(os.Stderr, "Usage: scrapper analyze timeline --input <file> --user <name|id> [--server <server>] [--max-gap 10m] [--format text|csv|json] [--output <file>]")
+		flags.PrintDefaults()
+		return 1
+	}
+
+	users, err := readAnalyzedUsers(*inputs, *server)
+	if err != nil {
+		logger.Errorf("Couldn't read users: %v", err)
+		return 1
+	}
+
+	intervals := buildTimeline(users, *user, *maxGap)
+	if len(intervals) == 0 {
+		logger.Errorf("Couldn't find user %s in input", *user)
+		return 1
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			logger.Errorf("Couldn't create output file: %v", err)
+			return 1
+		}
+		defer file.Close()
+
+		w = file
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(intervals)
+	case "csv":
+		err = writeTimelineCSV(w, intervals)
+	default:
+		err = writeTimelineTable(w, intervals)
+	}
+	if err != nil {
+		logger.Errorf("Couldn't write timeline: %v", err)
+		return 1
+	}
+
+	return 0
+}
+
+// writeTimelineTable writes intervals as table aligned with spaces, times are shown in local time
+func writeTimelineTable(w io.Writer, intervals []statusInterval) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVER\tSTATUS\tSTART\tEND\tDURATION\tSAMPLES")
+	for _, i := range intervals {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\n", i.Server, i.Status, i.Start.Local().Format(timeFormat),
+			i.End.Local().Format(timeFormat), i.Duration().Round(time.Second), i.Samples)
+	}
+
+	return tw.Flush()
+}
+
+// writeTimelineCSV writes intervals as csv rows, times are written in RFC 3339 format and duration in seconds
+func writeTimelineCSV(w io.Writer, intervals []statusInterval) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"server", "status", "start", "end", "duration_seconds", "samples"})
+	for _, i := range intervals {
+		cw.Write([]string{i.Server, i.Status, i.Start.Format(time.RFC3339), i.End.Format(time.RFC3339),
+			strconv.FormatInt(int64(i.Duration()/time.Second), 10), strconv.Itoa(i.Samples)})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
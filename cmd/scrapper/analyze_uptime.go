@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// knownStatuses are statuses shown by Discord, they go first in uptime report, other statuses follow them
+var knownStatuses = []string{"Online", "Idle", "Do Not Disturb", "Offline"}
+
+// uptimeReport is a share of sampled time, which user spent in each status
+type uptimeReport struct {
+	Server   string             `json:"server"`
+	ID       string             `json:"id"`
+	Username string             `json:"username"`
+	Samples  int                `json:"samples"`
+	Sampled  float64            `json:"sampled_seconds"` // time covered by samples
+	Percent  map[string]float64 `json:"percent"`         // status -> percent of sampled time
+}
+
+// analyzeUptime computes share of sampled time in each status of each user. Each sample covers time until next
+// sample of user, but at most maxGap, as user wasn't sampled during longer gaps. Last sample of user covers median
+// time of other samples, it's maxGap if user has only one sample. Reports are sorted by server and username.
+func analyzeUptime(users []User, maxGap time.Duration) []uptimeReport {
+	samples := make(map[string][]User)
+	keys := make([]string, 0)
+	for _, user := range users {
+		key := user.Server + "/" + userKey(user)
+		if _, ok := samples[key]; !ok {
+			keys = append(keys, key)
+		}
+		samples[key] = append(samples[key], user)
+	}
+
+	reports := make([]uptimeReport, 0, len(keys))
+	for _, key := range keys {
+		userSamples := samples[key]
+		sort.SliceStable(userSamples, func(i, j int) bool {
+			return userSamples[i].StatusTime.Before(userSamples[j].StatusTime.Time)
+		})
+
+		weights := make([]time.Duration, 0, len(userSamples))
+		for i := 0; i+1 < len(userSamples); i++ {
+			weight := userSamples[i+1].StatusTime.Sub(userSamples[i].StatusTime.Time)
+			if weight > maxGap {
+				weight = maxGap
+			}
+			weights = append(weights, weight)
+		}
+		weights = append(weights, medianDuration(weights, maxGap))
+
+		last := userSamples[len(userSamples)-1]
+		r := uptimeReport{
+			Server:   last.Server,
+			ID:       last.ID,
+			Username: last.Username,
+			Samples:  len(userSamples),
+			Percent:  make(map[string]float64),
+		}
+
+		var total time.Duration
+		spent := make(map[string]time.Duration)
+		for i, sample := range userSamples {
+			spent[sample.Status] += weights[i]
+			total += weights[i]
+		}
+		for status, d := range spent {
+			if total > 0 {
+				r.Percent[status] = float64(d) / float64(total) * 100
+			}
+		}
+		r.Sampled = total.Seconds()
+
+		reports = append(reports, r)
+	}
+
+	sort.SliceStable(reports, func(i, j int) bool {
+		if reports[i].Server != reports[j].Server {
+			return reports[i].Server < reports[j].Server
+		}
+		return strings.ToLower(reports[i].Username) < strings.ToLower(reports[j].Username)
+	})
+
+	return reports
+}
+
+// medianDuration returns median of durations, or fallback if there are none
+func medianDuration(durations []time.Duration, fallback time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return fallback
+	}
+
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	return sorted[len(sorted)/2]
+}
+
+// uptimeStatuses returns statuses found in reports, known ones go first in their order, others are sorted by name
+func uptimeStatuses(reports []uptimeReport) []string {
+	found := make(map[string]bool)
+	for _, r := range reports {
+		for status := range r.Percent {
+			found[status] = true
+		}
+	}
+
+	statuses := make([]string, 0, len(found))
+	for _, status := range knownStatuses {
+		if found[status] {
+			statuses = append(statuses, status)
+			delete(found, status)
+		}
+	}
+
+	others := make([]string, 0, len(found))
+	for status := range found {
+		others = append(others, status)
+	}
+	sort.Strings(others)
+
+	return append(statuses, others...)
+}
+
+// parseRangeTime parses bound of date range, it's date in local time (2006-01-02) or time in RFC 3339 format.
+// Date of end bound is inclusive, so its next day is returned.
+func parseRangeTime(value string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("time %q isn't date (2006-01-02) or RFC 3339 time", value)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return t, nil
+}
+
+// filterTimeRange returns users sampled between from and to, zero bound isn't checked
+func filterTimeRange(users []User, from, to time.Time) []User {
+	filtered := make([]User, 0, len(users))
+	for _, user := range users {
+		if !from.IsZero() && user.StatusTime.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !user.StatusTime.Before(to) {
+			continue
+		}
+		filtered = append(filtered, user)
+	}
+
+	return filtered
+}
+
+// analyzeUptimeCommand prints percent of sampled time, which each user spent in each status over date range
+func analyzeUptimeCommand(args []string) int {
+	flags := pflag.NewFlagSet("analyze uptime", pflag.ExitOnError)
+	inputs := flags.StringArrayP("input", "i", nil, "path to file (csv, json or ndjson, optionally .gz) or SQLite database (sqlite://users.db), where users are read from, can be repeated")
+	server := flags.String("server", "", "ID or name of server, only its users are reported")
+	from := flags.String("from", "", "first date (2006-01-02) or time (RFC 3339) of range, since first sample by default")
+	to := flags.String("to", "", "last date (2006-01-02, inclusive) or time (RFC 3339, exclusive) of range, until last sample by default")
+	maxGap := flags.Duration("max-gap", 10*time.Minute, "maximum time covered by one sample, user isn't counted during longer gaps between samples")
+	format := flags.String("format", "text", "format of report (text, csv or json)")
+	output := flags.StringP("output", "o", "", "path to file, where report is written, stdout by default")
+	flags.Parse(args)
+
+	logger = newLogger(os.Stderr, levelInfo, false)
+
+	if len(*inputs) == 0 || *maxGap <= 0 || (*format != "text" && *format != "csv" && *format != "json") {
+		fmt.Fprintln(os.Stderr, "Usage: scrapper analyze uptime --input <file> [--server <server>] [--from <date>] [--to <date>] [--max-gap 10m] [--format text|csv|json] [--output <file>]")
+		flags.PrintDefaults()
+		return 1
+	}
+
+	var rangeFrom, rangeTo time.Time
+	var err error
+	if *from != "" {
+		rangeFrom, err = parseRangeTime(*from, false)
+		if err != nil {
+			logger.Errorf("Couldn't parse --from: %v", err)
+			return 1
+		}
+	}
+	if *to != "" {
+		rangeTo, err = parseRangeTime(*to, true)
+		if err != nil {
+			logger.Errorf("Couldn't parse --to: %v", err)
+			return 1
+		}
+	}
+
+	users, err := readAnalyzedUsers(*inputs, *server)
+	if err != nil {
+		logger.Errorf("Couldn't read users: %v", err)
+		return 1
+	}
+
+	users = filterTimeRange(users, rangeFrom, rangeTo)
+	if len(users) == 0 {
+		logger.Errorf("Couldn't find users sampled in range")
+		return 1
+	}
+
+	reports := analyzeUptime(users, *maxGap)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			logger.Errorf("Couldn't create output file: %v", err)
+			return 1
+		}
+		defer file.Close()
+
+		w = file
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(reports)
+	case "csv":
+		err = writeUptimeCSV(w, reports)
+	default:
+		err = writeUptimeTable(w, reports)
+	}
+	if err != nil {
+		logger.Errorf("Couldn't write report: %v", err)
+		return 1
+	}
+
+	return 0
+}
+
+// writeUptimeTable writes reports as table aligned with spaces, with column of each status
+func writeUptimeTable(w io.Writer, reports []uptimeReport) error {
+	statuses := uptimeStatuses(reports)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "SERVER\tUSERNAME\tID\tSAMPLES\tSAMPLED\t%s\n", strings.ToUpper(strings.Join(statuses, "\t")))
+	for _, r := range reports {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s", r.Server, r.Username, r.ID, r.Samples,
+			(time.Duration(r.Sampled) * time.Second).Round(time.Second))
+		for _, status := range statuses {
+			fmt.Fprintf(tw, "\t%.1f%%", r.Percent[status])
+		}
+		fmt.Fprintln(tw)
+	}
+
+	return tw.Flush()
+}
+
+// writeUptimeCSV writes reports as csv rows, with column of percent of each status, like online_percent
+func writeUptimeCSV(w io.Writer, reports []uptimeReport) error {
+	statuses := uptimeStatuses(reports)
+
+	header := []string{"server", "id", "username", "samples", "sampled_seconds"}
+	for _, status := range statuses {
+		header = append(header, strings.ReplaceAll(strings.ToLower(status), " ", "_")+"_percent")
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	for _, r := range reports {
+		row := []string{r.Server, r.ID, r.Username, strconv.Itoa(r.Samples), strconv.FormatFloat(r.Sampled, 'f', 0, 64)}
+		for _, status := range statuses {
+			row = append(row, strconv.FormatFloat(r.Percent[status], 'f', 2, 64))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
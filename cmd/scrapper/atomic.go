@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// syncRecordWriter buffers users of each cycle in memory, and writes them to output with one write, that is synced
+// to disk. So output has either all rows of cycle or none of them, if tool crashes in the middle of encoding, and
+// rows aren't lost in buffers, when tool exits.
+type syncRecordWriter struct {
+	dst io.Writer
+	buf *bytes.Buffer
+	w   recordWriter // writer of output format, that writes into buf
+}
+
+func (w *syncRecordWriter) Write(users []User) error {
+	w.buf.Reset()
+
+	err := w.w.Write(users)
+	if err != nil {
+		return err
+	}
+
+	if w.buf.Len() == 0 {
+		return nil
+	}
+
+	file, ok := w.dst.(*os.File)
+	if !ok {
+		_, err = w.dst.Write(w.buf.Bytes())
+		return err
+	}
+
+	// size is remembered, so partially written rows (eg: when disk is full) are removed
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("getting info of output file: %w", err)
+	}
+
+	_, err = file.Write(w.buf.Bytes())
+	if err != nil {
+		if info.Mode().IsRegular() {
+			file.Truncate(info.Size())
+		}
+		return fmt.Errorf("writing output file: %w", err)
+	}
+
+	// stdout and pipes can't be synced
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	err = file.Sync()
+	if err != nil {
+		return fmt.Errorf("syncing output file: %w", err)
+	}
+
+	return nil
+}
+
+// writeFileAtomic writes file with write function into temporary file next to it, and renames it to path, when
+// it's synced to disk. So file has either old content or new one, if tool crashes in the middle of writing.
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	tmp, err := createTempFile(path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // it fails after rename
+
+	err = write(tmp)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	return commitTempFile(tmp, path)
+}
+
+// createTempFile creates hidden temporary file next to path, with the same permissions as existing file
+func createTempFile(path string) (*os.File, error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary file: %w", err)
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		tmp.Chmod(info.Mode())
+	}
+
+	return tmp, nil
+}
+
+// commitTempFile syncs temporary file to disk, closes it and renames it to path
+func commitTempFile(tmp *os.File, path string) error {
+	err := tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("syncing temporary file: %w", err)
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("closing temporary file: %w", err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return fmt.Errorf("renaming temporary file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// downloadAvatars saves avatars of users to dir, each file is named by user ID and avatar hash, so when user
+// changes avatar, new file is added next to old one. Users without ID or avatar are skipped.
+func downloadAvatars(users map[string]User, dir string) error {
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("creating avatars directory: %w", err)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	for _, user := range users {
+		if user.ID == "" || user.AvatarURL == "" {
+			continue
+		}
+
+		avatarURL, err := url.Parse(user.AvatarURL)
+		if err != nil {
+			logger.Errorf("Couldn't parse avatar url of %s: %v", user.Username, err)
+			continue
+		}
+
+		// avatar url looks like: https://cdn.discordapp.com/avatars/<id>/<hash>.webp?size=80
+		name := path.Base(avatarURL.Path)
+		pathToFile := filepath.Join(dir, user.ID+"-"+name)
+
+		// avatar with the same hash is already downloaded
+		_, err = os.Stat(pathToFile)
+		if !errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		err = downloadFile(client, user.AvatarURL, pathToFile)
+		if err != nil {
+			logger.Errorf("Couldn't download avatar of %s: %v", user.Username, err)
+		}
+	}
+
+	return nil
+}
+
+// downloadFile saves response body of GET request to file
+func downloadFile(client *http.Client, url, pathToFile string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server responded with %s", resp.Status)
+	}
+
+	file, err := os.Create(pathToFile)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, resp.Body)
+	if err != nil {
+		file.Close()
+		os.Remove(pathToFile)
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// discordMembersPageLimit is maximum amount of members returned by one request to Discord API
+const discordMembersPageLimit = 1000
+
+// discordGuild is a partial guild object of Discord API
+type discordGuild struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// resolveGuildID returns ID of server, servers supplied by name are looked up among servers of bot
+func resolveGuildID(api *discordAPI, server Server) (string, error) {
+	if server.ID != "" {
+		return server.ID, nil
+	}
+
+	var guilds []discordGuild
+	err := api.get("/users/@me/guilds", "", "/users/@me/guilds", &guilds)
+	if err != nil {
+		return "", err
+	}
+
+	for _, guild := range guilds {
+		if strings.EqualFold(guild.Name, server.Name) {
+			return guild.ID, nil
+		}
+	}
+
+	return "", fmt.Errorf("server %s isn't found among servers of bot", server)
+}
+
+// fetchRoles returns all roles of guild
+func fetchRoles(api *discordAPI, guildID string) ([]discordRole, error) {
+	var roles []discordRole
+	err := api.get("/guilds/{guild.id}/roles", guildID, "/guilds/"+guildID+"/roles", &roles)
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// fetchMembers returns all members of guild, they are paginated by user ID
+func fetchMembers(api *discordAPI, guildID string) ([]discordMember, error) {
+	members := make([]discordMember, 0)
+	after := "0"
+	for {
+		query := url.Values{}
+		query.Set("limit", fmt.Sprint(discordMembersPageLimit))
+		query.Set("after", after)
+
+		var page []discordMember
+		err := api.get("/guilds/{guild.id}/members", guildID, "/guilds/"+guildID+"/members?"+query.Encode(), &page)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, page...)
+
+		if len(page) < discordMembersPageLimit {
+			return members, nil
+		}
+		after = page[len(page)-1].User.ID
+	}
+}
+
+// fetchRoster returns all members of server from Discord API, by ID. Presences aren't available in API, so
+// members have Offline status, and role group is set by presences later.
+func fetchRoster(api *discordAPI, server Server) (map[string]User, map[string]discordMember, []discordRole, error) {
+	guildID, err := resolveGuildID(api, server)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	roles, err := fetchRoles(api, guildID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	members, err := fetchMembers(api, guildID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	users := make(map[string]User, len(members))
+	byID := make(map[string]discordMember, len(members))
+	for _, member := range members {
+		user := member.toUser(server, Channel{}, nil, "")
+		user.RoleGroup = member.roleGroup(roles, user.Status)
+		users[user.ID] = user
+		byID[member.User.ID] = member
+	}
+
+	return users, byID, roles, nil
+}
+
+// scrapBotAPI collects members of servers supplied by user from Discord API, and their presences from gateway,
+// it's available only for bots. If ctx is canceled, users fetched so far are returned.
+func scrapBotAPI(ctx context.Context) []User {
+	api := newDiscordAPI(ctx, *discordBotToken)
+
+	// presences are available only over gateway, they are sent in GUILD_CREATE events, when bot has presence intent
+	conn, err := dialGateway(ctx, *discordBotToken, true)
+	if err != nil {
+		logger.Errorf("Connecting to Discord gateway: %v", err)
+		metrics.incError("scrap")
+		return nil
+	}
+	defer conn.Close()
+
+	guilds, err := conn.waitGuilds()
+	if err != nil {
+		logger.Errorf("Receiving servers from Discord gateway: %v", err)
+		metrics.incError("scrap")
+		return nil
+	}
+
+	users := make([]User, 0)
+	for _, server := range servers() {
+		logger.With("server", server).Infof("Fetching members from Discord API...")
+		usernameStatuses, members, roles, err := fetchRoster(api, server)
+		if ctx.Err() != nil {
+			return users
+		}
+		if err != nil {
+			logger.With("server", server).Errorf("Fetching members: %v", err)
+			metrics.incError("scrap")
+			continue
+		}
+
+		guild, ok := findGuild(guilds, server)
+		if !ok {
+			logger.With("server", server).Warnf("Presences aren't received from gateway, all members are offline")
+		}
+
+		for i := range guild.Presences {
+			presence := &guild.Presences[i]
+			member, ok := members[presence.User.ID]
+			if !ok {
+				continue
+			}
+
+			user := member.toUser(server, Channel{}, presence, "")
+			user.RoleGroup = member.roleGroup(roles, user.Status)
+			usernameStatuses[user.ID] = user
+		}
+
+		// bot itself isn't added to output
+		delete(usernameStatuses, conn.userID)
+
+		users = collectUsers(users, usernameStatuses)
+	}
+
+	return users
+}
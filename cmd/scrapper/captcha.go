@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// handleCaptcha checks if Discord showed hCaptcha after login form was submitted, if so, then operator is notified
+// and browser is kept open until captcha is solved manually
+func handleCaptcha(driver Driver) error {
+	time.Sleep(2 * time.Second) // wait until captcha is shown
+
+	_, err := findElement(driver, selectors.CaptchaFrame)
+	if err != nil { // captcha isn't shown
+		return nil
+	}
+
+	notify("captcha", fmt.Sprintf("Discord asks to solve captcha, solve it in browser in %d seconds", *captchaTimeout))
+
+	deadline := time.Now().Add(time.Duration(*captchaTimeout) * time.Second)
+	for time.Now().Before(deadline) {
+		_, err = findElement(driver, selectors.CaptchaFrame)
+		if err != nil {
+			logger.Infof("Captcha is solved")
+			return nil
+		}
+
+		time.Sleep(time.Second)
+	}
+
+	return fmt.Errorf("captcha wasn't solved in %d seconds", *captchaTimeout)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// types of change events
+const (
+	changeStatus      = "status"      // user changed status, eg: Online -> Idle
+	changeAppeared    = "appeared"    // user appeared in member list
+	changeDisappeared = "disappeared" // user isn't in member list anymore
+	changeJoined      = "joined"      // user is seen in member list first time, or again after leaving
+	changeLeft        = "left"        // user isn't seen in member list for --left-after scrapping processes
+)
+
+// changeTypes are all types of change events, in order they are described to user
+var changeTypes = []string{changeStatus, changeAppeared, changeDisappeared, changeJoined, changeLeft}
+
+// ChangeEvent is a change of user between two scrapping processes
+type ChangeEvent struct {
+	Type     string    `json:"type"`
+	Server   string    `json:"server"`
+	Channel  string    `json:"channel"`
+	ID       string    `json:"id"`
+	Username string    `json:"username"`
+	From     string    `json:"from"` // previous status, empty for appeared and joined user
+	To       string    `json:"to"`   // new status, empty for disappeared and left user
+	Time     time.Time `json:"time"`
+}
+
+// String describes event for log and notifications, eg: 'bejaneps: Online -> Idle'
+func (e ChangeEvent) String() string {
+	switch e.Type {
+	case changeAppeared:
+		return fmt.Sprintf("%s appeared in member list of %s server (%s)", e.Username, e.Server, e.To)
+	case changeDisappeared:
+		return fmt.Sprintf("%s disappeared from member list of %s server (was %s)", e.Username, e.Server, e.From)
+	case changeJoined:
+		return fmt.Sprintf("%s joined %s server", e.Username, e.Server)
+	case changeLeft:
+		return fmt.Sprintf("%s left %s server", e.Username, e.Server)
+	default:
+		return fmt.Sprintf("%s: %s -> %s", e.Username, e.From, e.To)
+	}
+}
+
+// changeWriter consumes change events of each scrapping process
+type changeWriter interface {
+	WriteChanges(events []ChangeEvent) error
+}
+
+// changeTracker keeps previous snapshot of member lists, and compares new snapshot with it, so status changes,
+// and users, that appeared in member list or disappeared from it, are found. It also keeps roster of users, that
+// didn't leave, so users joining and leaving are found.
+type changeTracker struct {
+	snapshot  map[string]User        // server, channel and user -> user
+	roster    map[string]rosterEntry // server, channel and user -> user, that didn't leave yet
+	leftAfter int
+}
+
+// rosterEntry is a user of roster, with amount of scrapping processes in a row, where user wasn't in member list
+type rosterEntry struct {
+	User    User `json:"user"`
+	Missing int  `json:"missing"`
+}
+
+// newChangeTracker creates tracker, that doesn't know any snapshot yet, user leaves after being missing in leftAfter
+// scrapping processes in a row
+func newChangeTracker(leftAfter int) *changeTracker {
+	return &changeTracker{
+		snapshot:  make(map[string]User),
+		roster:    make(map[string]rosterEntry),
+		leftAfter: leftAfter,
+	}
+}
+
+// restore sets snapshot and roster saved before restart, so changes since last scrapping process are found. Roster
+// is built from snapshot, if it wasn't saved (eg: state file was written by previous version of tool).
+func (t *changeTracker) restore(users []User, roster map[string]rosterEntry) {
+	for _, user := range users {
+		t.snapshot[changeKey(user)] = user
+	}
+
+	if roster == nil {
+		for key, user := range t.snapshot {
+			t.roster[key] = rosterEntry{User: user}
+		}
+		return
+	}
+	t.roster = roster
+}
+
+// memberListKey returns key of member list of user, it's the same for all users of one list
+func memberListKey(user User) string {
+	return user.Server + "\x00" + user.Channel
+}
+
+// changeKey returns key of user in member list
+func changeKey(user User) string {
+	return memberListKey(user) + "\x00" + userKey(user)
+}
+
+// diff returns changes between previous snapshot and users, and keeps users as new snapshot. Member lists, that
+// aren't in users (eg: server couldn't be scrapped), keep their previous snapshot, and users aren't reported as
+// disappeared from them, or missing. Nothing is reported for member lists, that are seen first time.
+func (t *changeTracker) diff(users []User) []ChangeEvent {
+	now := time.Now()
+	events := make([]ChangeEvent, 0)
+
+	known := make(map[string]bool)
+	for _, previous := range t.snapshot {
+		known[memberListKey(previous)] = true
+	}
+
+	current := make(map[string]User, len(users))
+	lists := make(map[string]bool)
+	for _, user := range users {
+		list := memberListKey(user)
+		key := changeKey(user)
+		current[key] = user
+		lists[list] = true
+
+		_, inRoster := t.roster[key]
+		t.roster[key] = rosterEntry{User: user}
+
+		previous, ok := t.snapshot[key]
+		switch {
+		case !known[list]:
+			continue
+		case !ok:
+			if !inRoster {
+				events = append(events, newChangeEvent(changeJoined, user, "", user.Status, now))
+			}
+			events = append(events, newChangeEvent(changeAppeared, user, "", user.Status, now))
+		case previous.Status != user.Status:
+			events = append(events, newChangeEvent(changeStatus, user, previous.Status, user.Status, now))
+		}
+	}
+
+	for key, previous := range t.snapshot {
+		list := memberListKey(previous)
+		if !lists[list] {
+			current[key] = previous
+			continue
+		}
+		if _, ok := current[key]; !ok {
+			events = append(events, newChangeEvent(changeDisappeared, previous, previous.Status, "", now))
+		}
+	}
+
+	// user, that isn't in member list for several scrapping processes, left it, and is removed from roster, so user
+	// joins again, if user comes back
+	for key, entry := range t.roster {
+		if !lists[memberListKey(entry.User)] {
+			continue
+		}
+		if _, ok := current[key]; ok {
+			continue
+		}
+
+		entry.Missing++
+		if entry.Missing >= t.leftAfter {
+			events = append(events, newChangeEvent(changeLeft, entry.User, entry.User.Status, "", now))
+			delete(t.roster, key)
+			continue
+		}
+		t.roster[key] = entry
+	}
+
+	t.snapshot = current
+	return events
+}
+
+// newChangeEvent creates event of user
+func newChangeEvent(eventType string, user User, from, to string, now time.Time) ChangeEvent {
+	return ChangeEvent{
+		Type:     eventType,
+		Server:   user.Server,
+		Channel:  user.Channel,
+		ID:       user.ID,
+		Username: user.Username,
+		From:     from,
+		To:       to,
+		Time:     now,
+	}
+}
+
+// countChanges returns amount of events of each type
+func countChanges(events []ChangeEvent) map[string]int {
+	counts := make(map[string]int)
+	for _, event := range events {
+		counts[event.Type]++
+	}
+
+	return counts
+}
+
+// changesFileWriter writes change events to file, each of them as json object on its own line
+type changesFileWriter struct {
+	w io.Writer
+}
+
+func (w changesFileWriter) WriteChanges(events []ChangeEvent) error {
+	enc := json.NewEncoder(w.w)
+	for _, event := range events {
+		err := enc.Encode(event)
+		if err != nil {
+			return fmt.Errorf("writing change event: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// changesNotifier sends change events of types supplied by user as notifications
+type changesNotifier struct {
+	types map[string]bool
+}
+
+func (n changesNotifier) WriteChanges(events []ChangeEvent) error {
+	for _, event := range events {
+		if n.types[event.Type] {
+			notify(event.Type, event.String())
+		}
+	}
+
+	return nil
+}
+
+// parseChangeTypes checks types of change events supplied by user
+func parseChangeTypes(values []string) (map[string]bool, error) {
+	types := make(map[string]bool)
+	for _, value := range values {
+		if !containsString(changeTypes, value) {
+			return nil, fmt.Errorf("unknown change event %s, known ones are: %s", value, strings.Join(changeTypes, ", "))
+		}
+		types[value] = true
+	}
+
+	return types, nil
+}
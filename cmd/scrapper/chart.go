@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// statusColors are colors of statuses, as Discord shows them
+var statusColors = map[string]string{
+	"Online":         "#23a55a",
+	"Idle":           "#f0b232",
+	"Do Not Disturb": "#f23f43",
+	"Offline":        "#80848e",
+}
+
+// seriesColors are colors of series, that aren't statuses, eg: servers
+var seriesColors = []string{"#5865f2", "#23a55a", "#f0b232", "#f23f43", "#eb459e", "#80848e"}
+
+// chartColor returns color of series, status has its own color
+func chartColor(name string, i int) string {
+	if c, ok := statusColors[name]; ok {
+		return c
+	}
+
+	return seriesColors[i%len(seriesColors)]
+}
+
+// chartPoint is a value of series at time
+type chartPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+// chartSeries is a named line of chart
+type chartSeries struct {
+	Name   string
+	Points []chartPoint
+}
+
+// sizes of charts in pixels
+const (
+	chartWidth  = 800
+	chartHeight = 300
+	chartLeft   = 50
+	chartRight  = 20
+	chartTop    = 40
+	chartBottom = 30
+)
+
+// chartScale maps time and value to coordinates of chart area
+type chartScale struct {
+	from, to time.Time
+	max      float64
+}
+
+// newChartScale returns scale, that fits all points of series, maximum value is rounded up, so axis labels are round
+func newChartScale(series []chartSeries) chartScale {
+	var s chartScale
+	for _, ss := range series {
+		for _, p := range ss.Points {
+			if s.from.IsZero() || p.Time.Before(s.from) {
+				s.from = p.Time
+			}
+			if p.Time.After(s.to) {
+				s.to = p.Time
+			}
+			if p.Value > s.max {
+				s.max = p.Value
+			}
+		}
+	}
+	s.max = niceCeil(s.max)
+
+	return s
+}
+
+// x returns horizontal coordinate of time
+func (s chartScale) x(t time.Time) float64 {
+	width := float64(chartWidth - chartLeft - chartRight)
+	if !s.to.After(s.from) {
+		return chartLeft + width/2
+	}
+
+	return chartLeft + width*float64(t.Sub(s.from))/float64(s.to.Sub(s.from))
+}
+
+// y returns vertical coordinate of value
+func (s chartScale) y(v float64) float64 {
+	return chartHeight - chartBottom - float64(chartHeight-chartTop-chartBottom)*v/s.max
+}
+
+// niceCeil rounds value up, so it's split into 4 ticks with round step, like 1, 2.5 or 20, step is at least 1
+func niceCeil(v float64) float64 {
+	step := v / 4
+	if step <= 1 {
+		return 4
+	}
+
+	exp := math.Pow(10, math.Floor(math.Log10(step)))
+	for _, m := range []float64{1, 1.5, 2, 2.5, 3, 4, 5, 6, 8, 10} {
+		if step <= m*exp {
+			return 4 * m * exp
+		}
+	}
+
+	return 40 * exp
+}
+
+// formatChartValue formats value of axis label, without fraction for whole numbers
+func formatChartValue(v float64) string {
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+
+	return fmt.Sprintf("%.1f", v)
+}
+
+// writeChartFrame writes background, title, legend, grid and axis labels of time chart, times are shown in location
+// of points
+func writeChartFrame(b *strings.Builder, title string, series []chartSeries, s chartScale) {
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`+"\n",
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(b, `<rect width="%d" height="%d" fill="#ffffff"/>`+"\n", chartWidth, chartHeight)
+	fmt.Fprintf(b, `<text x="%d" y="16" font-size="13">%s</text>`+"\n", chartLeft, escapeXML(title))
+
+	x := chartLeft
+	for i, ss := range series {
+		fmt.Fprintf(b, `<rect x="%d" y="24" width="10" height="10" fill="%s"/><text x="%d" y="33">%s</text>`+"\n",
+			x, chartColor(ss.Name, i), x+14, escapeXML(ss.Name))
+		x += 24 + 7*len(ss.Name)
+	}
+
+	for i := 0; i <= 4; i++ {
+		v := s.max * float64(i) / 4
+		fmt.Fprintf(b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#e3e5e8"/>`+"\n", chartLeft, s.y(v), chartWidth-chartRight, s.y(v))
+		fmt.Fprintf(b, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`+"\n", chartLeft-6, s.y(v)+4, formatChartValue(v))
+	}
+
+	layout := "01-02 15:04"
+	if s.to.Sub(s.from) > 7*24*time.Hour {
+		layout = "2006-01-02"
+	}
+	for i := 0; i <= 4; i++ {
+		t := s.from.Add(time.Duration(float64(s.to.Sub(s.from)) * float64(i) / 4))
+		fmt.Fprintf(b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`+"\n", s.x(t), chartHeight-chartBottom+16, t.In(s.from.Location()).Format(layout))
+	}
+}
+
+// writeLineChartSVG draws series as lines over time
+func writeLineChartSVG(w io.Writer, title string, series []chartSeries) error {
+	s := newChartScale(series)
+
+	var b strings.Builder
+	writeChartFrame(&b, title, series, s)
+	for i, ss := range series {
+		points := make([]string, 0, len(ss.Points))
+		for _, p := range ss.Points {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", s.x(p.Time), s.y(p.Value)))
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5"/>`+"\n", strings.Join(points, " "), chartColor(ss.Name, i))
+	}
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// stackSeries returns series, where each value is a sum of values of the same point of series below, all series
+// have to have the same points in time
+func stackSeries(series []chartSeries) []chartSeries {
+	stacked := make([]chartSeries, len(series))
+	for i, ss := range series {
+		stacked[i] = chartSeries{Name: ss.Name, Points: make([]chartPoint, len(ss.Points))}
+		for j, p := range ss.Points {
+			if i > 0 && j < len(stacked[i-1].Points) {
+				p.Value += stacked[i-1].Points[j].Value
+			}
+			stacked[i].Points[j] = p
+		}
+	}
+
+	return stacked
+}
+
+// writeAreaChartSVG draws series as areas stacked on top of each other, first series is at the bottom. Areas are
+// drawn from the top one, each of them down to zero, so lower ones cover them.
+func writeAreaChartSVG(w io.Writer, title string, series []chartSeries) error {
+	stacked := stackSeries(series)
+	s := newChartScale(stacked)
+
+	var b strings.Builder
+	writeChartFrame(&b, title, series, s)
+	for i := len(stacked) - 1; i >= 0; i-- {
+		points := stacked[i].Points
+		if len(points) == 0 {
+			continue
+		}
+
+		coords := make([]string, 0, len(points)+2)
+		for _, p := range points {
+			coords = append(coords, fmt.Sprintf("%.1f,%.1f", s.x(p.Time), s.y(p.Value)))
+		}
+		coords = append(coords, fmt.Sprintf("%.1f,%.1f", s.x(points[len(points)-1].Time), s.y(0)),
+			fmt.Sprintf("%.1f,%.1f", s.x(points[0].Time), s.y(0)))
+		fmt.Fprintf(&b, `<polygon points="%s" fill="%s"/>`+"\n", strings.Join(coords, " "), chartColor(series[i].Name, i))
+	}
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// chartBar is a labeled bar of bar chart
+type chartBar struct {
+	Label string
+	Value float64
+}
+
+// writeBarChartSVG draws horizontal bars, values are shown with unit after them, eg: %
+func writeBarChartSVG(w io.Writer, title string, bars []chartBar, unit string) error {
+	const barHeight, barLeft = 24, 120
+	height := chartTop + len(bars)*barHeight + 10
+
+	var max float64
+	for _, bar := range bars {
+		if bar.Value > max {
+			max = bar.Value
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`+"\n",
+		chartWidth, height, chartWidth, height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#ffffff"/>`+"\n", chartWidth, height)
+	fmt.Fprintf(&b, `<text x="%d" y="16" font-size="13">%s</text>`+"\n", chartLeft, escapeXML(title))
+	for i, bar := range bars {
+		y := chartTop + i*barHeight
+		width := 0.0
+		if max > 0 {
+			width = float64(chartWidth-barLeft-chartRight-60) * bar.Value / max
+		}
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`+"\n", barLeft-6, y+15, escapeXML(bar.Label))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="%s"/>`+"\n", barLeft, y+3, width, barHeight-6, chartColor(bar.Label, i))
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d">%s%s</text>`+"\n", float64(barLeft)+width+6, y+15, formatChartValue(math.Round(bar.Value*10)/10), unit)
+	}
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fillRect fills rectangle of image with color
+func fillRect(img *image.RGBA, r image.Rectangle, c color.Color) {
+	r = r.Intersect(img.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.Set(x, y, c)
+		}
+	}
+}
+
+// drawLine draws line 2 pixels thick between two points
+func drawLine(img *image.RGBA, x0, y0, x1, y1 float64, c color.Color) {
+	steps := math.Max(math.Abs(x1-x0), math.Abs(y1-y0))
+	if steps < 1 {
+		steps = 1
+	}
+
+	for i := 0.0; i <= steps; i++ {
+		x := int(math.Round(x0 + (x1-x0)*i/steps))
+		y := int(math.Round(y0 + (y1-y0)*i/steps))
+		fillRect(img, image.Rect(x, y, x+2, y+2), c)
+	}
+}
+
+// parseHexColor parses color in #rrggbb format, black is returned for invalid color
+func parseHexColor(value string) color.RGBA {
+	n, err := strconv.ParseUint(strings.TrimPrefix(value, "#"), 16, 32)
+	if err != nil {
+		return color.RGBA{A: 255}
+	}
+
+	return color.RGBA{R: uint8(n >> 16), G: uint8(n >> 8), B: uint8(n), A: 255}
+}
+
+// glyphs is a 3x5 pixel font of upper case letters, digits and punctuation used in labels of charts, other
+// characters are drawn as spaces
+var glyphs = map[rune][5]string{
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"###", "..#", "###", "#..", "###"},
+	'3': {"###", "..#", "###", "..#", "###"},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "###", "..#", "###"},
+	'6': {"###", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", "..#", "..#", "..#"},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "###"},
+	'A': {"###", "#.#", "###", "#.#", "#.#"},
+	'B': {"##.", "#.#", "##.", "#.#", "##."},
+	'C': {"###", "#..", "#..", "#..", "###"},
+	'D': {"##.", "#.#", "#.#", "#.#", "##."},
+	'E': {"###", "#..", "##.", "#..", "###"},
+	'F': {"###", "#..", "##.", "#..", "#.."},
+	'G': {"###", "#..", "#.#", "#.#", "###"},
+	'H': {"#.#", "#.#", "###", "#.#", "#.#"},
+	'I': {"###", ".#.", ".#.", ".#.", "###"},
+	'J': {"..#", "..#", "..#", "#.#", "###"},
+	'K': {"#.#", "#.#", "##.", "#.#", "#.#"},
+	'L': {"#..", "#..", "#..", "#..", "###"},
+	'M': {"#.#", "###", "###", "#.#", "#.#"},
+	'N': {"##.", "#.#", "#.#", "#.#", "#.#"},
+	'O': {"###", "#.#", "#.#", "#.#", "###"},
+	'P': {"###", "#.#", "###", "#..", "#.."},
+	'Q': {"###", "#.#", "#.#", "###", "..#"},
+	'R': {"##.", "#.#", "##.", "#.#", "#.#"},
+	'S': {"###", "#..", "###", "..#", "###"},
+	'T': {"###", ".#.", ".#.", ".#.", ".#."},
+	'U': {"#.#", "#.#", "#.#", "#.#", "###"},
+	'V': {"#.#", "#.#", "#.#", "#.#", ".#."},
+	'W': {"#.#", "#.#", "###", "###", "#.#"},
+	'X': {"#.#", "#.#", ".#.", "#.#", "#.#"},
+	'Y': {"#.#", "#.#", ".#.", ".#.", ".#."},
+	'Z': {"###", "..#", ".#.", "#..", "###"},
+	'-': {"...", "...", "###", "...", "..."},
+	'+': {"...", ".#.", "###", ".#.", "..."},
+	':': {"...", ".#.", "...", ".#.", "..."},
+	'.': {"...", "...", "...", "...", ".#."},
+	',': {"...", "...", "...", ".#.", "#.."},
+	'%': {"#.#", "..#", ".#.", "#..", "#.#"},
+	'/': {"..#", "..#", ".#.", "#..", "#.."},
+	'_': {"...", "...", "...", "...", "###"},
+	'(': {".#.", "#..", "#..", "#..", ".#."},
+	')': {".#.", "..#", "..#", "..#", ".#."},
+}
+
+// labelScale is a size of glyph pixel in image pixels
+const labelScale = 2
+
+// labelWidth returns width of label in image pixels
+func labelWidth(label string) int {
+	return len([]rune(label))*4*labelScale - labelScale
+}
+
+// drawLabel draws label in upper case with top left corner at x, y
+func drawLabel(img *image.RGBA, x, y int, label string, c color.Color) {
+	for _, r := range strings.ToUpper(label) {
+		for row, line := range glyphs[r] {
+			for col, pixel := range line {
+				if pixel == '#' {
+					px, py := x+col*labelScale, y+row*labelScale
+					fillRect(img, image.Rect(px, py, px+labelScale, py+labelScale), c)
+				}
+			}
+		}
+		x += 4 * labelScale
+	}
+}
+
+// drawChartFrame draws background, title, legend, grid and axis labels of time chart, like writeChartFrame
+func drawChartFrame(title string, series []chartSeries, s chartScale) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	fillRect(img, img.Bounds(), color.RGBA{R: 255, G: 255, B: 255, A: 255})
+
+	black := color.RGBA{A: 255}
+	drawLabel(img, chartLeft, 6, title, black)
+
+	x := chartLeft
+	for i, ss := range series {
+		fillRect(img, image.Rect(x, 24, x+10, 34), parseHexColor(chartColor(ss.Name, i)))
+		drawLabel(img, x+14, 24, ss.Name, black)
+		x += 24 + labelWidth(ss.Name)
+	}
+
+	grid := color.RGBA{R: 227, G: 229, B: 232, A: 255}
+	for i := 0; i <= 4; i++ {
+		v := s.max * float64(i) / 4
+		y := int(s.y(v))
+		fillRect(img, image.Rect(chartLeft, y, chartWidth-chartRight, y+1), grid)
+		label := formatChartValue(v)
+		drawLabel(img, chartLeft-6-labelWidth(label), y-5, label, black)
+	}
+
+	layout := "01-02 15:04"
+	if s.to.Sub(s.from) > 7*24*time.Hour {
+		layout = "2006-01-02"
+	}
+	for i := 0; i <= 4; i++ {
+		t := s.from.Add(time.Duration(float64(s.to.Sub(s.from)) * float64(i) / 4))
+		label := t.In(s.from.Location()).Format(layout)
+		lx := int(s.x(t)) - labelWidth(label)/2
+		if lx < 0 {
+			lx = 0
+		}
+		if lx+labelWidth(label) > chartWidth {
+			lx = chartWidth - labelWidth(label) - 2
+		}
+		drawLabel(img, lx, chartHeight-chartBottom+8, label, black)
+	}
+
+	return img
+}
+
+// drawLineChart draws series as lines over time, like writeLineChartSVG
+func drawLineChart(title string, series []chartSeries) image.Image {
+	s := newChartScale(series)
+	img := drawChartFrame(title, series, s)
+
+	for i, ss := range series {
+		c := parseHexColor(chartColor(ss.Name, i))
+		for j := 1; j < len(ss.Points); j++ {
+			a, b := ss.Points[j-1], ss.Points[j]
+			drawLine(img, s.x(a.Time), s.y(a.Value), s.x(b.Time), s.y(b.Value), c)
+		}
+	}
+
+	return img
+}
+
+// drawAreaChart draws series stacked on top of each other, like writeAreaChartSVG. Each column of pixels is filled
+// with values interpolated between points.
+func drawAreaChart(title string, series []chartSeries) image.Image {
+	stacked := stackSeries(series)
+	s := newChartScale(stacked)
+	img := drawChartFrame(title, series, s)
+
+	for i := len(stacked) - 1; i >= 0; i-- {
+		c := parseHexColor(chartColor(series[i].Name, i))
+		for x := chartLeft; x < chartWidth-chartRight; x++ {
+			v, ok := interpolateSeries(stacked[i], s, float64(x))
+			if !ok {
+				continue
+			}
+			fillRect(img, image.Rect(x, int(s.y(v)), x+1, int(s.y(0))), c)
+		}
+	}
+
+	return img
+}
+
+// interpolateSeries returns value of series at horizontal coordinate, it's false outside of series
+func interpolateSeries(ss chartSeries, s chartScale, x float64) (float64, bool) {
+	points := ss.Points
+	j := sort.Search(len(points), func(i int) bool {
+		return s.x(points[i].Time) >= x
+	})
+	switch {
+	case j == len(points):
+		return 0, false
+	case j == 0:
+		return points[0].Value, s.x(points[0].Time)-x < 1
+	}
+
+	a, b := points[j-1], points[j]
+	ax, bx := s.x(a.Time), s.x(b.Time)
+	if bx == ax {
+		return b.Value, true
+	}
+
+	return a.Value + (b.Value-a.Value)*(x-ax)/(bx-ax), true
+}
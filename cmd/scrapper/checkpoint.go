@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// checkpointScrolls is amount of scrolls of member list, after which progress is saved
+const checkpointScrolls = 10
+
+// checkpoint is a state of tool saved to state file, so it can resume unfinished scrapping process, or at least
+// know last snapshot, after crash or restart
+type checkpoint struct {
+	LastCycle time.Time              `json:"last_cycle"`       // time when last scrapping process was finished
+	Snapshot  []User                 `json:"snapshot"`         // users of last finished scrapping process
+	Roster    map[string]rosterEntry `json:"roster,omitempty"` // users, that didn't leave member lists yet
+	Cycle     *cycleProgress         `json:"cycle,omitempty"`
+}
+
+// cycleProgress is a progress of unfinished scrapping process
+type cycleProgress struct {
+	Started time.Time                `json:"started"`
+	Done    []string                 `json:"done"`            // member lists, that are scrapped already
+	Users   []User                   `json:"users"`           // users of scrapped member lists
+	Lists   map[string]*listProgress `json:"lists,omitempty"` // member lists, that are scrapped right now
+}
+
+// listProgress is a scroll position of member list, that is scrapped right now, and users collected so far
+type listProgress struct {
+	ScrollTop float64         `json:"scroll_top"`
+	Users     map[string]User `json:"users"`
+}
+
+// checkpointStore saves checkpoints to state file, all its methods do nothing if it's nil, so state file is
+// optional
+type checkpointStore struct {
+	mu    sync.Mutex
+	path  string
+	state checkpoint
+}
+
+// checkpoints is a store used by whole tool, it's nil if state file isn't supplied
+var checkpoints *checkpointStore
+
+// loadCheckpoints reads state file, it's created with first checkpoint if it doesn't exist. Progress of scrapping
+// process, that was started too long ago, is dropped, as statuses are outdated already.
+func loadCheckpoints(path string) (*checkpointStore, error) {
+	s := &checkpointStore{path: path}
+
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading state file: %w", err)
+	}
+
+	err = json.Unmarshal(data, &s.state)
+	if err != nil {
+		return nil, fmt.Errorf("decoding state file: %w", err)
+	}
+
+	if s.state.Cycle != nil && time.Since(s.state.Cycle.Started) > maxCycleAge() {
+		logger.Warnf("Unfinished scrapping process from %s is too old, it isn't resumed", s.state.Cycle.Started.Format(timeFormat))
+		s.state.Cycle = nil
+	}
+
+	return s, nil
+}
+
+// snapshot returns users of last finished scrapping process and time when it was finished
+func (s *checkpointStore) snapshot() ([]User, time.Time) {
+	if s == nil {
+		return nil, time.Time{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.state.Snapshot, s.state.LastCycle
+}
+
+// roster returns users, that didn't leave member lists, when last scrapping process was finished, it's nil if it
+// wasn't saved
+func (s *checkpointStore) roster() map[string]rosterEntry {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.state.Roster
+}
+
+// resume returns users of member lists, that were scrapped by unfinished scrapping process, and starts new process
+// if there is no unfinished one
+func (s *checkpointStore) resume() []User {
+	if s == nil {
+		return make([]User, 0)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state.Cycle == nil {
+		s.state.Cycle = &cycleProgress{Started: time.Now()}
+		return make([]User, 0)
+	}
+
+	logger.Infof("Resuming scrapping process from %s, %d member lists are scrapped already", s.state.Cycle.Started.Format(timeFormat), len(s.state.Cycle.Done))
+	return append(make([]User, 0, len(s.state.Cycle.Users)), s.state.Cycle.Users...)
+}
+
+// isDone checks if member list was scrapped by unfinished scrapping process
+func (s *checkpointStore) isDone(list string) bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.state.Cycle != nil && containsString(s.state.Cycle.Done, list)
+}
+
+// listProgress returns scroll position of member list and users collected from it, if it was being scrapped, when
+// tool crashed. It's returned only once, so member list is scrapped from top, when it's scrapped again.
+func (s *checkpointStore) listProgress(list string) (float64, map[string]User) {
+	if s == nil {
+		return 0, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state.Cycle == nil || s.state.Cycle.Lists[list] == nil {
+		return 0, nil
+	}
+
+	progress := s.state.Cycle.Lists[list]
+	delete(s.state.Cycle.Lists, list)
+
+	return progress.ScrollTop, progress.Users
+}
+
+// scrolled saves scroll position of member list and users collected from it so far
+func (s *checkpointStore) scrolled(list string, scrollTop float64, users map[string]User) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state.Cycle == nil {
+		return
+	}
+	if s.state.Cycle.Lists == nil {
+		s.state.Cycle.Lists = make(map[string]*listProgress)
+	}
+
+	// users are copied, as member list keeps adding them
+	copied := make(map[string]User, len(users))
+	for k, v := range users {
+		copied[k] = v
+	}
+	s.state.Cycle.Lists[list] = &listProgress{ScrollTop: scrollTop, Users: copied}
+	s.save()
+}
+
+// listDone saves member list as scrapped, with its users
+func (s *checkpointStore) listDone(list string, users []User) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state.Cycle == nil {
+		return
+	}
+	s.state.Cycle.Done = append(s.state.Cycle.Done, list)
+	s.state.Cycle.Users = append(s.state.Cycle.Users, users...)
+	delete(s.state.Cycle.Lists, list)
+	s.save()
+}
+
+// cycleDone saves users of finished scrapping process as last snapshot with roster, and drops its progress
+func (s *checkpointStore) cycleDone(users []User, roster map[string]rosterEntry) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state.LastCycle = time.Now()
+	s.state.Snapshot = users
+	s.state.Roster = roster
+	s.state.Cycle = nil
+	s.save()
+}
+
+// cycleAborted drops progress of scrapping process, that was stopped, as its users are written to output already
+func (s *checkpointStore) cycleAborted() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state.Cycle = nil
+	s.save()
+}
+
+// save writes state to state file atomically, so it isn't corrupted if tool crashes in the middle of writing.
+// Failed checkpoint doesn't stop scrapping, it's only logged.
+func (s *checkpointStore) save() {
+	err := writeFileAtomic(s.path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(s.state)
+	})
+	if err != nil {
+		logger.Errorf("Couldn't save state file: %v", err)
+	}
+}
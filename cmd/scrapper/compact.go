@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// compactUsers rolls samples older than before into one sample of each user in each bucket (eg: 15 minutes), with
+// status, that user had in most samples of bucket, ties are won by status seen last. Compacted sample is the last one
+// with that status, at start of bucket, so compacting is repeatable. Newer samples are kept as they are, and users
+// are sorted by time.
+func compactUsers(users []User, before time.Time, bucket time.Duration) []User {
+	type bucketKey struct {
+		server, channel, user string
+		start                 time.Time
+	}
+	type bucketSamples struct {
+		counts map[string]int
+		last   map[string]int // status -> index of its last sample
+	}
+
+	sorted := append([]User(nil), users...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].StatusTime.Before(sorted[j].StatusTime.Time)
+	})
+
+	compacted := make([]User, 0)
+	buckets := make(map[bucketKey]*bucketSamples)
+	order := make([]bucketKey, 0)
+	for i, user := range sorted {
+		if !user.StatusTime.Before(before) {
+			compacted = append(compacted, user)
+			continue
+		}
+
+		key := bucketKey{user.Server, user.Channel, userKey(user), user.StatusTime.UTC().Truncate(bucket)}
+		b, ok := buckets[key]
+		if !ok {
+			b = &bucketSamples{counts: make(map[string]int), last: make(map[string]int)}
+			buckets[key] = b
+			order = append(order, key)
+		}
+		b.counts[user.Status]++
+		b.last[user.Status] = i
+	}
+
+	rolled := make([]User, 0, len(order))
+	for _, key := range order {
+		b := buckets[key]
+		dominant := -1
+		for status, i := range b.last {
+			if dominant < 0 {
+				dominant = i
+				continue
+			}
+			count, best := b.counts[status], b.counts[sorted[dominant].Status]
+			if count > best || (count == best && i > dominant) {
+				dominant = i
+			}
+		}
+
+		user := sorted[dominant]
+		user.StatusTime = Time{key.start}
+		user.PreviousStatus = ""
+		rolled = append(rolled, user)
+	}
+
+	return append(rolled, compacted...)
+}
+
+// compactCommand rolls old samples into buckets, samples of SQLite database are replaced in place, and samples of
+// files are written to another file
+func compactCommand(args []string) int {
+	flags := pflag.NewFlagSet("compact", pflag.ExitOnError)
+	input := flags.StringP("input", "i", "", "path to SQLite database (sqlite://users.db), that is compacted in place, or file (csv, json or ndjson, optionally .gz), that is compacted into --output")
+	output := flags.StringP("output", "o", "", "path to file or SQLite database (sqlite://users.db), where compacted users are written, it's needed for files")
+	format := flags.String("format", "", "format of output file (csv, json, ndjson, parquet or xlsx), it's detected by extension of output by default")
+	olderThan := flags.String("older-than", "7d", "age of samples (eg: 30d, 72h), that are compacted, newer ones are kept as they are")
+	bucket := flags.Duration("bucket", 15*time.Minute, "length of bucket, samples of user in each bucket are rolled into one with dominant status")
+	flags.Parse(args)
+
+	logger = newLogger(os.Stderr, levelInfo, false)
+
+	inPlace := isSQLiteOutput(*input) && *output == ""
+	if *format == "" {
+		*format = formatOfPath(*output)
+	}
+	if *input == "" || *bucket <= 0 || (!inPlace && *output == "") || (!inPlace && *format == "" && !isSQLiteOutput(*output)) {
+		fmt.Fprintln(os.Stderr, "Usage: scrapper compact --input <sqlite://users.db|file> [--output <file>] [--older-than 7d] [--bucket 15m] [--format <format>]")
+		flags.PrintDefaults()
+		return 1
+	}
+	if *input == *output {
+		logger.Errorf("Couldn't compact %s into itself, omit --output to compact SQLite database in place", *input)
+		return 1
+	}
+
+	age, err := parseAge(*olderThan)
+	if err != nil {
+		logger.Errorf("Couldn't parse --older-than: %v", err)
+		return 1
+	}
+	before := time.Now().Add(-age).UTC().Truncate(*bucket)
+
+	if inPlace {
+		db, err := openSQLite(strings.TrimPrefix(*input, sqliteOutputPrefix))
+		if err != nil {
+			logger.Errorf("Couldn't open database: %v", err)
+			return 1
+		}
+		defer db.Close()
+
+		samples, compacted, err := db.CompactSamples(before, *bucket)
+		if err != nil {
+			logger.Errorf("Couldn't compact samples: %v", err)
+			return 1
+		}
+
+		logger.Infof("Compacted %d samples older than %s into %d", samples, before.Local().Format(timeFormat), compacted)
+		return 0
+	}
+
+	users, err := readUsers(*input)
+	if err != nil {
+		logger.Errorf("Couldn't read users: %v", err)
+		return 1
+	}
+	compacted := compactUsers(users, before, *bucket)
+
+	var writer recordWriter
+	if isSQLiteOutput(*output) {
+		db, err := openSQLite(strings.TrimPrefix(*output, sqliteOutputPrefix))
+		if err != nil {
+			logger.Errorf("Couldn't open output database: %v", err)
+			return 1
+		}
+		defer db.Close()
+
+		writer = db
+	} else {
+		file, err := openOutputFile(*output)
+		if err != nil {
+			logger.Errorf("Couldn't open output file: %v", err)
+			return 1
+		}
+		defer file.Close()
+
+		writer, err = newFileRecordWriter(*format, file)
+		if err != nil {
+			logger.Errorf("Couldn't create output writer: %v", err)
+			return 1
+		}
+	}
+
+	err = writer.Write(compacted)
+	if err != nil {
+		logger.Errorf("Couldn't write users: %v", err)
+		return 1
+	}
+	closeRecordWriter(writer)
+
+	logger.Infof("Compacted %d users into %d, they are written to %s", len(users), len(compacted), *output)
+	return 0
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// commandLineFlags are names of flags set on command line, they override values of config file
+var commandLineFlags = make(map[string]bool)
+
+// envFlags are names of flags set with environment variables, they override values of config file too
+var envFlags = make(map[string]bool)
+
+// envPrefix is a prefix of environment variables, that set flags
+const envPrefix = "DUM_"
+
+// envName returns name of environment variable of flag, eg: DUM_INTERVAL for --interval
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// userValueFlags are flags with names or regular expressions of users, their lists are split by splitUserValues, so
+// commas inside of regular expressions don't split them
+var userValueFlags = []string{"include-users", "exclude-users", "watch"}
+
+// loadEnv sets flags from environment variables, so tool can be configured in containers without arguments. Lists
+// are separated by commas, eg: DUM_D_SERVER_ID=123,456. Flags set on command line aren't changed.
+func loadEnv() error {
+	var err error
+	pflag.VisitAll(func(flag *pflag.Flag) {
+		value, ok := os.LookupEnv(envName(flag.Name))
+		if !ok || commandLineFlags[flag.Name] || err != nil {
+			return
+		}
+
+		var setErr error
+		if sv, ok := flag.Value.(pflag.SliceValue); ok && containsString(userValueFlags, flag.Name) {
+			setErr = sv.Replace(splitUserValues([]string{value}))
+		} else if ok {
+			setErr = sv.Replace(strings.Split(value, ","))
+		} else {
+			setErr = flag.Value.Set(value)
+		}
+		if setErr != nil {
+			err = fmt.Errorf("setting flag %s from %s: %w", flag.Name, envName(flag.Name), setErr)
+			return
+		}
+
+		envFlags[flag.Name] = true
+	})
+
+	return err
+}
+
+// profilesKey is a key of config file, that contains named profiles
+const profilesKey = "profiles"
+
+// loadConfig sets flags from config file (in .json, .yaml or .toml format), its keys are names of flags without
+// dashes, and values are strings, numbers, booleans, or lists for flags that accept several values, eg:
+// {"interval": "5m", "exclude-users": ["/^bot-/"]}. Values of profile supplied with --profile override
+// other values of config file. Flags set on command line or with environment variables aren't changed.
+func loadConfig(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	values, err := decodeConfig(path, data)
+	if err != nil {
+		return fmt.Errorf("parsing config file: %w", err)
+	}
+
+	values, err = applyProfile(values, *configProfile)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range values {
+		if commandLineFlags[name] || envFlags[name] {
+			continue
+		}
+
+		flag := pflag.Lookup(name)
+		if flag == nil || name == "config" || name == "profile" {
+			return fmt.Errorf("unknown flag %s in config file", name)
+		}
+
+		err = setFlag(flag, value)
+		if err != nil {
+			return fmt.Errorf("setting flag %s from config file: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// applyProfile removes profiles from values of config file, and overrides values with ones of profile, so
+// values outside of profiles are shared by all of them, eg:
+// {"d-token": "...", "profiles": {"gaming-guild": {"d-server-id": ["123"], "output": "gaming.csv"}}}
+func applyProfile(values map[string]interface{}, profile string) (map[string]interface{}, error) {
+	profiles, ok := values[profilesKey].(map[string]interface{})
+	if _, exists := values[profilesKey]; exists && !ok {
+		return nil, fmt.Errorf("profiles of config file have to be a map of profile names to their values")
+	}
+	delete(values, profilesKey)
+
+	if profile == "" {
+		return values, nil
+	}
+
+	profileValues, ok := profiles[profile].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("profile %s isn't found in config file", profile)
+	}
+
+	for name, value := range profileValues {
+		values[name] = value
+	}
+
+	return values, nil
+}
+
+// decodeConfig decodes config file in format of its extension, files with other extensions are decoded as json
+func decodeConfig(path string, data []byte) (map[string]interface{}, error) {
+	var values map[string]interface{}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err := yaml.Unmarshal(data, &values)
+		if err != nil {
+			return nil, err
+		}
+	case ".toml":
+		err := toml.Unmarshal(data, &values)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		// numbers are kept as they are written, so big ones aren't formatted in exponent notation
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err := dec.Decode(&values)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+// durationValue is a value of duration flag, that accepts bare number in unit as well, as timing flags were
+// integers before, eg: --interval 5 is the same as --interval 5m
+type durationValue struct {
+	d    *time.Duration
+	unit time.Duration
+}
+
+func (v *durationValue) Set(s string) error {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*v.d = time.Duration(n) * v.unit
+		return nil
+	}
+
+	// days are accepted as well, eg: 90d
+	d, err := parseAge(s)
+	if err != nil {
+		return err
+	}
+	*v.d = d
+	return nil
+}
+
+func (v *durationValue) String() string {
+	return v.d.String()
+}
+
+func (v *durationValue) Type() string {
+	return "duration"
+}
+
+// durationFlag defines duration flag with bare numbers in unit
+func durationFlag(name, shorthand string, value, unit time.Duration, usage string) *time.Duration {
+	d := value
+	pflag.VarP(&durationValue{d: &d, unit: unit}, name, shorthand, usage)
+	return &d
+}
+
+// aliasFlag defines deprecated flag, that sets value of target flag, so renamed flag works with its old name
+func aliasFlag(name, target string) {
+	flag := pflag.Lookup(target)
+	pflag.Var(flag.Value, name, flag.Usage)
+	pflag.CommandLine.MarkDeprecated(name, "use --"+target+" instead")
+}
+
+// setFlag sets value of flag, list replaces all values of flag
+func setFlag(flag *pflag.Flag, value interface{}) error {
+	list, ok := value.([]interface{})
+	if !ok {
+		return flag.Value.Set(fmt.Sprint(value))
+	}
+
+	sv, ok := flag.Value.(pflag.SliceValue)
+	if !ok {
+		return fmt.Errorf("flag %s doesn't accept list", flag.Name)
+	}
+
+	values := make([]string, len(list))
+	for i, v := range list {
+		values[i] = fmt.Sprint(v)
+	}
+
+	return sv.Replace(values)
+}
+
+// reloadableFlags are flags, that are applied without restart, when config file is reloaded, other ones are used
+// only at start
+var reloadableFlags = []string{"interval", "interval-jitter", "include-users", "exclude-users", "watch", "watch-file", "selectors", "output"}
+
+// flagSnapshot is a value of each flag, lists are kept as lists, so they can be restored with all their values
+type flagSnapshot map[string]interface{}
+
+// snapshotFlags saves values of all flags, deprecated aliases share values of their flags, so they are skipped
+func snapshotFlags() flagSnapshot {
+	snapshot := make(flagSnapshot)
+	pflag.VisitAll(func(flag *pflag.Flag) {
+		if flag.Deprecated != "" {
+			return
+		}
+
+		if sv, ok := flag.Value.(pflag.SliceValue); ok {
+			snapshot[flag.Name] = append([]string(nil), sv.GetSlice()...)
+		} else {
+			snapshot[flag.Name] = flag.Value.String()
+		}
+	})
+
+	return snapshot
+}
+
+// changed returns names of flags, whose values differ from snapshot
+func (s flagSnapshot) changed() []string {
+	current := snapshotFlags()
+	names := make([]string, 0)
+	for name, value := range s {
+		if fmt.Sprint(current[name]) != fmt.Sprint(value) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// restore sets flags back to values of snapshot, only changed ones are set, as values of some flags aren't
+// parsed from their text the same way, eg: durations
+func (s flagSnapshot) restore(names ...string) {
+	if len(names) == 0 {
+		names = s.changed()
+	}
+
+	for _, name := range names {
+		flag := pflag.Lookup(name)
+		switch value := s[name].(type) {
+		case []string:
+			flag.Value.(pflag.SliceValue).Replace(value)
+		case string:
+			flag.Value.Set(value)
+		}
+	}
+}
+
+// resetFlags sets flags back to their default values, flags set on command line or with environment variables are
+// kept, as config file doesn't override them
+func resetFlags(names []string) {
+	for _, name := range names {
+		if commandLineFlags[name] || envFlags[name] {
+			continue
+		}
+
+		flag := pflag.Lookup(name)
+		if sv, ok := flag.Value.(pflag.SliceValue); ok {
+			values := []string{}
+			if def := strings.Trim(flag.DefValue, "[]"); def != "" {
+				values = strings.Split(def, ",")
+			}
+			sv.Replace(values)
+		} else {
+			flag.Value.Set(flag.DefValue)
+		}
+	}
+}
+
+// reloadConfig reads config file again and applies settings, that can be changed without restart: interval,
+// filters, selectors and output file. Changes of other flags are reverted, as they are used only at start. Settings
+// are validated like at start, and if config file can't be read, or new settings are invalid, all previous ones are
+// kept.
+func reloadConfig(outputFile *os.File, outputWriter recordWriter) (*os.File, recordWriter) {
+	logger.Infof("Reloading config file %s", *pathToConfigFile)
+	snapshot := snapshotFlags()
+	previousOutput := *pathToOutputFile
+
+	// keys removed from config file don't keep their previous values
+	resetFlags(reloadableFlags)
+
+	err := loadConfig(*pathToConfigFile)
+	if err != nil {
+		logger.Errorf("Couldn't reload config file, previous settings are kept: %v", err)
+		restoreSettings(snapshot)
+		return outputFile, outputWriter
+	}
+
+	for _, name := range snapshot.changed() {
+		if !containsString(reloadableFlags, name) {
+			logger.Warnf("Flag --%s can't be changed without restart, its previous value is kept", name)
+			snapshot.restore(name)
+		}
+	}
+
+	// filters and other settings parsed by validation are updated by it
+	problems := validateConfig()
+	if len(problems) > 0 {
+		for _, p := range problems {
+			logger.Errorf("Config file is invalid: %s (%s)", p.problem, p.hint)
+		}
+		logger.Errorf("Couldn't reload config file, previous settings are kept")
+		restoreSettings(snapshot)
+		return outputFile, outputWriter
+	}
+
+	if *pathToSelectorsFile != "" {
+		err = loadSelectors(*pathToSelectorsFile)
+		if err != nil {
+			logger.Errorf("Couldn't load selectors, previous settings are kept: %v", err)
+			restoreSettings(snapshot)
+			return outputFile, outputWriter
+		}
+	}
+
+	if *pathToOutputFile == previousOutput {
+		return outputFile, outputWriter
+	}
+
+	// only output file can be changed to another file, stdout and databases are opened only at start
+	if !isReopenableOutput(previousOutput) || !isReopenableOutput(*pathToOutputFile) {
+		logger.Warnf("Output can be changed only from one file to another without restart, previous output is kept")
+		*pathToOutputFile = previousOutput
+		return outputFile, outputWriter
+	}
+
+	file, writer, err := openOutputWriter(*outputFormat, *pathToOutputFile)
+	if err != nil {
+		logger.Errorf("Couldn't open output, previous one is kept: %v", err)
+		*pathToOutputFile = previousOutput
+		return outputFile, outputWriter
+	}
+
+	closeRecordWriter(outputWriter)
+	outputFile.Close()
+
+	// digests are built from new output, as users are written there from now on
+	emailDigestInput = *pathToOutputFile
+
+	return file, writer
+}
+
+// restoreSettings restores flags from snapshot, and validates them again, so settings parsed by validation (eg:
+// filters) are restored as well. They were valid, so problems aren't expected.
+func restoreSettings(snapshot flagSnapshot) {
+	snapshot.restore()
+	validateConfig()
+}
+
+// isReopenableOutput checks if output is a file supplied by user, as it can be reopened
+func isReopenableOutput(output string) bool {
+	return output != "" && output != "-" && !isSQLiteOutput(output)
+}
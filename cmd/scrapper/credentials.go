@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// loadCredentials overrides credential flags with environment variables and secret files, so credentials don't
+// have to appear in shell history and process arguments. Secret file takes highest precedence, then environment
+// variable, and flag the lowest one.
+func loadCredentials() error {
+	setFromEnv(discordEmail, "DISCORD_EMAIL")
+	setFromEnv(discordPassword, "DISCORD_PASSWORD")
+	setFromEnv(discordToken, "DISCORD_TOKEN")
+	setFromEnv(discordTOTPSecret, "DISCORD_TOTP_SECRET")
+	setFromEnv(discordBotToken, "DISCORD_BOT_TOKEN")
+	setFromEnv(mqttPassword, "MQTT_PASSWORD")
+	setFromEnv(smtpPassword, "SMTP_PASSWORD")
+
+	if *discordPasswordFile != "" {
+		password, err := readSecretFile(*discordPasswordFile)
+		if err != nil {
+			return err
+		}
+		*discordPassword = password
+	}
+
+	// if email was supplied without password, then password is asked in terminal, instead of passing it in arguments
+	if (*source == "browser" || *source == "hybrid") && *discordEmail != "" && *discordPassword == "" && *discordToken == "" {
+		password, err := promptPassword("Enter Discord password: ")
+		if err != nil {
+			return err
+		}
+		*discordPassword = password
+	}
+
+	return nil
+}
+
+// setFromEnv sets value to environment variable's one, if it isn't empty
+func setFromEnv(value *string, key string) {
+	if v := os.Getenv(key); v != "" {
+		*value = v
+	}
+}
+
+// readSecretFile reads secret from file, like ones mounted by Docker secrets, trailing new line is omitted
+func readSecretFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading secret file: %w", err)
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// promptPassword asks user to type password in terminal, with echo disabled
+func promptPassword(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("password isn't supplied, and stdin isn't a terminal to ask for it")
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+
+	return string(password), nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import "strings"
+
+// statusTracker remembers statuses of users from previous scrapping processes, so only changes are written
+type statusTracker struct {
+	statuses map[string]string // server, channel and user -> status
+}
+
+// newStatusTracker creates tracker, that doesn't know any statuses yet
+func newStatusTracker() *statusTracker {
+	return &statusTracker{statuses: make(map[string]string)}
+}
+
+// changes returns users, whose status changed since previous scrapping process, with their previous status. All
+// users are returned after first scrapping process, with empty previous status. Users that aren't scrapped this
+// time (eg: offline ones, that aren't shown in member list) keep their last status.
+func (t *statusTracker) changes(users []User) []User {
+	changed := make([]User, 0)
+	for _, user := range users {
+		key := user.Server + "\x00" + user.Channel + "\x00" + userKey(user)
+
+		previous, ok := t.statuses[key]
+		t.statuses[key] = user.Status
+		if ok && previous == user.Status {
+			continue
+		}
+
+		user.PreviousStatus = previous
+		changed = append(changed, user)
+	}
+
+	return changed
+}
+
+// dedupeTracker remembers written values of users from previous scrapping processes, so user is written only, when
+// any of them changed. All users are written every few scrapping processes, so output can be read from any full
+// snapshot, eg: after old part of file is removed.
+type dedupeTracker struct {
+	values        map[string]string // server, channel and user -> written values
+	cycles        int               // amount of scrapping processes since start
+	snapshotEvery int               // amount of scrapping processes between full snapshots, 0 means only first one
+}
+
+// newDedupeTracker creates tracker, that writes all users in first scrapping process and every snapshotEvery ones
+func newDedupeTracker(snapshotEvery int) *dedupeTracker {
+	return &dedupeTracker{values: make(map[string]string), snapshotEvery: snapshotEvery}
+}
+
+// dedupeValues returns written values of user, that are compared between scrapping processes, time of status and
+// previous status are skipped, as they are different each time
+func dedupeValues(user User) string {
+	values := make([]string, 0, len(outputColumns))
+	for _, column := range outputColumns {
+		if column != "status_time" && column != "previous_status" {
+			values = append(values, userValue(user, column))
+		}
+	}
+
+	return strings.Join(values, "\x00")
+}
+
+// restore remembers users of last snapshot, so they aren't written again after restart, snapshot is counted as
+// first scrapping process
+func (t *dedupeTracker) restore(users []User) {
+	t.dedupe(users)
+}
+
+// dedupe returns users, whose written values changed since previous scrapping process, or all users, if it's time
+// of full snapshot. Users that aren't scrapped this time (eg: offline ones, that aren't shown in member list) keep
+// their last values.
+func (t *dedupeTracker) dedupe(users []User) []User {
+	full := t.cycles == 0 || (t.snapshotEvery > 0 && t.cycles%t.snapshotEvery == 0)
+	t.cycles++
+
+	changed := make([]User, 0)
+	for _, user := range users {
+		key := user.Server + "\x00" + user.Channel + "\x00" + userKey(user)
+		values := dedupeValues(user)
+
+		previous, ok := t.values[key]
+		t.values[key] = values
+		if !full && ok && previous == values {
+			continue
+		}
+
+		changed = append(changed, user)
+	}
+
+	return changed
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// discordCDN is a host of Discord's images, like avatars
+const discordCDN = "https://cdn.discordapp.com"
+
+// discordUser is a user object of Discord API
+type discordUser struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	GlobalName string `json:"global_name"`
+	Avatar     string `json:"avatar"`
+	Bot        bool   `json:"bot"`
+}
+
+// discordMember is a server member object of Discord API
+type discordMember struct {
+	User     discordUser      `json:"user"`
+	Nick     string           `json:"nick"`
+	Roles    []string         `json:"roles"`
+	JoinedAt string           `json:"joined_at"`
+	Presence *discordPresence `json:"presence"` // only present in member list updates of gateway
+}
+
+// discordPresence is a presence object of Discord API
+type discordPresence struct {
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Status     string            `json:"status"`
+	Activities []discordActivity `json:"activities"`
+}
+
+// discordActivity is an activity object of Discord API, custom status is an activity as well
+type discordActivity struct {
+	Type  int    `json:"type"`
+	Name  string `json:"name"`
+	State string `json:"state"`
+	Emoji *struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"emoji"`
+}
+
+// discordRole is a role object of Discord API
+type discordRole struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+	Hoist    bool   `json:"hoist"` // hoisted roles have their own section in member list
+}
+
+// discordActivityCustom is a type of custom status activity
+const discordActivityCustom = 4
+
+// discordActivityPrefixes are prefixes of activities by their type, the same ones Discord shows in member list
+var discordActivityPrefixes = map[int]string{
+	0: "Playing ",
+	1: "Streaming ",
+	2: "Listening to ",
+	3: "Watching ",
+	5: "Competing in ",
+}
+
+// discordStatuses are statuses of presences, as Discord shows them in member list
+var discordStatuses = map[string]string{
+	"online":  "Online",
+	"idle":    "Idle",
+	"dnd":     "Do Not Disturb",
+	"offline": "Offline",
+}
+
+// toUser converts member to User, the same way as it's scrapped from member list, presence is nil for offline
+// members
+func (m discordMember) toUser(server Server, channel Channel, presence *discordPresence, roleGroup string) User {
+	userType := "user"
+	if m.User.Bot {
+		userType = "bot"
+	}
+
+	// displayed name is nickname, then global display name, and then username
+	username := m.Nick
+	if username == "" {
+		username = m.User.GlobalName
+	}
+	if username == "" {
+		username = m.User.Username
+	}
+
+	var avatarURL string
+	if m.User.Avatar != "" {
+		avatarURL = fmt.Sprintf("%s/avatars/%s/%s.webp?size=80", discordCDN, m.User.ID, m.User.Avatar)
+	}
+
+	var joinedAt string
+	if t, err := time.Parse(time.RFC3339, m.JoinedAt); err == nil {
+		joinedAt = t.Format(timeFormat)
+	}
+
+	status := "Offline"
+	var customStatus, activity string
+	if presence != nil {
+		if s, ok := discordStatuses[presence.Status]; ok {
+			status = s
+		}
+		customStatus, activity = presenceActivities(presence.Activities)
+	}
+
+	return User{
+		Server:         server.String(),
+		Channel:        channel.String(),
+		ID:             m.User.ID,
+		Username:       username,
+		Nickname:       m.Nick,
+		GlobalUsername: m.User.Username,
+		Status:         status,
+		Type:           userType,
+		RoleGroup:      roleGroup,
+		CustomStatus:   customStatus,
+		Activity:       activity,
+		AvatarURL:      avatarURL,
+		JoinedAt:       joinedAt,
+		StatusTime:     Time{time.Now()},
+	}
+}
+
+// presenceActivities returns custom status and first rich presence activity of presence, custom emojis are
+// written as ':name:', like in member list
+func presenceActivities(activities []discordActivity) (customStatus, activity string) {
+	for _, a := range activities {
+		if a.Type == discordActivityCustom {
+			if customStatus != "" {
+				continue
+			}
+
+			var emoji string
+			if a.Emoji != nil {
+				emoji = a.Emoji.Name
+				if a.Emoji.ID != "" {
+					emoji = ":" + emoji + ":"
+				}
+			}
+			customStatus = strings.TrimSpace(emoji + " " + a.State)
+			continue
+		}
+
+		if prefix, ok := discordActivityPrefixes[a.Type]; ok && activity == "" {
+			activity = prefix + a.Name
+		}
+	}
+
+	return customStatus, activity
+}
+
+// roleGroup returns role section of member list, where member is listed: highest hoisted role of member,
+// or Online/Offline if member doesn't have one, offline members are always listed in Offline section
+func (m discordMember) roleGroup(roles []discordRole, status string) string {
+	if status == "Offline" {
+		return "Offline"
+	}
+
+	hoisted := make([]discordRole, 0)
+	for _, role := range roles {
+		if role.Hoist && containsString(m.Roles, role.ID) {
+			hoisted = append(hoisted, role)
+		}
+	}
+
+	if len(hoisted) == 0 {
+		return "Online"
+	}
+
+	sort.Slice(hoisted, func(i, j int) bool {
+		return hoisted[i].Position > hoisted[j].Position
+	})
+
+	return hoisted[0].Name
+}
+
+// containsString checks if values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
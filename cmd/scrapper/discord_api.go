@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// discordAPIURL is a base URL of Discord REST API
+const discordAPIURL = "https://discord.com/api/v10"
+
+// discordAPIMaxRetries is maximum amount of retries of request, that was rate limited
+const discordAPIMaxRetries = 5
+
+// rateLimitBucket is a state of Discord's rate limit bucket, requests are delayed when it's exhausted
+type rateLimitBucket struct {
+	remaining int
+	reset     time.Time
+}
+
+// discordAPI is a client of Discord REST API, authorized with bot token. It follows rate limits: Discord groups
+// routes into buckets (their hashes are sent in X-RateLimit-Bucket header), each bucket is limited separately
+// per major parameter (eg: guild ID), and there is a global limit as well.
+type discordAPI struct {
+	ctx    context.Context // requests are canceled with it
+	client *http.Client
+	token  string
+
+	mu           sync.Mutex
+	routeBuckets map[string]string           // route -> bucket hash
+	buckets      map[string]*rateLimitBucket // bucket hash + major parameter -> bucket
+	globalReset  time.Time                   // time when global rate limit ends
+}
+
+// newDiscordAPI creates client of Discord REST API, authorized with bot token, its requests are canceled with ctx
+func newDiscordAPI(ctx context.Context, token string) *discordAPI {
+	return &discordAPI{
+		ctx:          ctx,
+		client:       &http.Client{Timeout: 30 * time.Second},
+		token:        token,
+		routeBuckets: make(map[string]string),
+		buckets:      make(map[string]*rateLimitBucket),
+	}
+}
+
+// bucketKey returns key of route's bucket, route is used itself, until Discord tells its bucket hash
+func (a *discordAPI) bucketKey(route, major string) string {
+	if hash, ok := a.routeBuckets[route]; ok {
+		return hash + ":" + major
+	}
+
+	return route + ":" + major
+}
+
+// wait sleeps until request to route can be sent without being rate limited, it returns false if client's context
+// is canceled before that
+func (a *discordAPI) wait(route, major string) bool {
+	a.mu.Lock()
+	until := a.globalReset
+	if bucket, ok := a.buckets[a.bucketKey(route, major)]; ok && bucket.remaining <= 0 && bucket.reset.After(until) {
+		until = bucket.reset
+	}
+	a.mu.Unlock()
+
+	if d := time.Until(until); d > 0 {
+		logger.Debugf("Waiting %v for Discord API rate limit to reset", d.Round(time.Millisecond))
+		return sleepContext(a.ctx, d)
+	}
+
+	return true
+}
+
+// update updates route's bucket from rate limit headers of response
+func (a *discordAPI) update(route, major string, header http.Header) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if hash := header.Get("X-RateLimit-Bucket"); hash != "" {
+		a.routeBuckets[route] = hash
+	}
+
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	resetAfter, err := strconv.ParseFloat(header.Get("X-RateLimit-Reset-After"), 64)
+	if err != nil {
+		return
+	}
+
+	a.buckets[a.bucketKey(route, major)] = &rateLimitBucket{
+		remaining: remaining,
+		reset:     time.Now().Add(time.Duration(resetAfter * float64(time.Second))),
+	}
+}
+
+// limited marks route's bucket, or all routes if limit is global, as exhausted for retryAfter seconds
+func (a *discordAPI) limited(route, major string, retryAfter float64, global bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	reset := time.Now().Add(time.Duration(retryAfter * float64(time.Second)))
+	if global {
+		a.globalReset = reset
+		return
+	}
+
+	a.buckets[a.bucketKey(route, major)] = &rateLimitBucket{remaining: 0, reset: reset}
+}
+
+// get sends GET request to path of route, and decodes json response into v. Route is a path template, like
+// '/guilds/{guild.id}/members', and major is its major parameter, they identify rate limit bucket.
+func (a *discordAPI) get(route, major, path string, v interface{}) error {
+	for attempt := 0; attempt <= discordAPIMaxRetries; attempt++ {
+		if !a.wait(route, major) {
+			return a.ctx.Err()
+		}
+
+		req, err := http.NewRequest(http.MethodGet, discordAPIURL+path, nil)
+		if err != nil {
+			return fmt.Errorf("creating api request: %w", err)
+		}
+		req = req.WithContext(a.ctx)
+		req.Header.Set("Authorization", "Bot "+a.token)
+		req.Header.Set("User-Agent", "DiscordBot (https://github.com/bejaneps/discord-user-monitor, 1.0)")
+
+		resp, err := a.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("sending api request: %w", err)
+		}
+
+		a.update(route, major, resp.Header)
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			var limit struct {
+				RetryAfter float64 `json:"retry_after"`
+				Global     bool    `json:"global"`
+			}
+			err = json.NewDecoder(resp.Body).Decode(&limit)
+			resp.Body.Close()
+			if err != nil {
+				return fmt.Errorf("decoding rate limit response: %w", err)
+			}
+
+			logger.Warnf("Discord API rate limited %s, retrying after %.2f seconds", route, limit.RetryAfter)
+			a.limited(route, major, limit.RetryAfter, limit.Global)
+			continue
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			resp.Body.Close()
+			return fmt.Errorf("discord api responded with %s to %s", resp.Status, path)
+		}
+
+		err = json.NewDecoder(resp.Body).Decode(v)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("decoding api response: %w", err)
+		}
+
+		return nil
+	}
+
+	return errors.New("discord api rate limit retries are exceeded")
+}
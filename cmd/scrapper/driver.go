@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// Driver is a browser automation backend, tool controls browser only through it, so backends are interchangeable.
+// Selector strategies are selenium's ones (selenium.ByCSSSelector, selenium.ByXPATH), and keys are selenium's key
+// codes (selenium.EnterKey, selenium.EscapeKey), backends translate them if needed.
+type Driver interface {
+	Get(url string) error
+	CurrentURL() (string, error)
+	// ExecuteScript runs script in page, script accesses args through 'arguments', elements can be passed as args
+	ExecuteScript(script string, args []interface{}) (interface{}, error)
+	FindElement(by, value string) (Element, error)
+	FindElements(by, value string) ([]Element, error)
+	// PressKey presses and releases key in currently focused element of page
+	PressKey(key string) error
+	Close() error
+}
+
+// Element is a page element found by Driver
+type Element interface {
+	FindElement(by, value string) (Element, error)
+	FindElements(by, value string) ([]Element, error)
+	Click() error
+	SendKeys(keys string) error
+	GetAttribute(name string) (string, error)
+}
+
+// newDriver creates driver of backend specified by user for worker, its calls fail when ctx is canceled, so
+// scrapping stops in the middle, when tool is stopped
+func newDriver(ctx context.Context, worker int) (Driver, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	proxy, err := browserProxy(worker)
+	if err != nil {
+		return nil, err
+	}
+
+	var driver Driver
+	switch *backend {
+	case "selenium":
+		driver, err = newSeleniumDriver(proxy)
+	case "chromedp":
+		driver, err = newChromedpDriver(ctx, proxy)
+	default:
+		return nil, fmt.Errorf("unknown backend %s", *backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return contextDriver{Driver: driver, ctx: ctx}, nil
+}
+
+// browserProxy returns proxy of worker's browser, proxies supplied by user are split between workers in turn, so
+// each worker keeps the same proxy, when its browser is started again. It's nil if user didn't supply proxies.
+func browserProxy(worker int) (*url.URL, error) {
+	if len(*proxies) == 0 {
+		return nil, nil
+	}
+
+	return parseProxy((*proxies)[worker%len(*proxies)])
+}
+
+// parseProxy parses proxy url, browsers accept only http and socks5 proxies without authentication in their
+// settings
+func parseProxy(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("parsing proxy: %w", err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "socks5" {
+		return nil, fmt.Errorf("proxy %s isn't http or socks5 one", proxy)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy %s doesn't have host", proxy)
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("proxy %s has credentials, browsers don't support proxy authentication", u.Redacted())
+	}
+
+	return u, nil
+}
+
+// contextDriver checks context before each call of driver, and waits while scrapping is paused, so browser isn't
+// used by tool then. Browser can be closed only with Close, so it isn't checked there. Calls of selenium, that are
+// in progress, aren't interrupted, while chromedp ones are, as its browser is started with the same context.
+type contextDriver struct {
+	Driver
+	ctx context.Context
+}
+
+func (d contextDriver) Get(url string) error {
+	if err := scrapPause.wait(d.ctx); err != nil {
+		return err
+	}
+
+	return d.Driver.Get(url)
+}
+
+func (d contextDriver) CurrentURL() (string, error) {
+	if err := scrapPause.wait(d.ctx); err != nil {
+		return "", err
+	}
+
+	return d.Driver.CurrentURL()
+}
+
+func (d contextDriver) ExecuteScript(script string, args []interface{}) (interface{}, error) {
+	if err := scrapPause.wait(d.ctx); err != nil {
+		return nil, err
+	}
+
+	return d.Driver.ExecuteScript(script, args)
+}
+
+func (d contextDriver) FindElement(by, value string) (Element, error) {
+	if err := scrapPause.wait(d.ctx); err != nil {
+		return nil, err
+	}
+
+	return d.Driver.FindElement(by, value)
+}
+
+func (d contextDriver) FindElements(by, value string) ([]Element, error) {
+	if err := scrapPause.wait(d.ctx); err != nil {
+		return nil, err
+	}
+
+	return d.Driver.FindElements(by, value)
+}
+
+func (d contextDriver) PressKey(key string) error {
+	if err := scrapPause.wait(d.ctx); err != nil {
+		return err
+	}
+
+	return d.Driver.PressKey(key)
+}
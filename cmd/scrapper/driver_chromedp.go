@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/cdproto/input"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/kb"
+	"github.com/tebeka/selenium"
+)
+
+// findScript finds element(s) inside of 'this' by CSS selector or XPath
+const findScript = `function(by, value, all) {
+	if (by === "xpath") {
+		var result = document.evaluate(value, this, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null);
+		var nodes = [];
+		for (var i = 0; i < result.snapshotLength; i++) {
+			nodes.push(result.snapshotItem(i));
+		}
+		return all ? nodes : (nodes[0] || null);
+	}
+
+	return all ? Array.from(this.querySelectorAll(value)) : this.querySelector(value);
+}`
+
+// clickPointScript scrolls element into view and returns its center, where mouse is clicked
+const clickPointScript = `function() {
+	this.scrollIntoView({block: "center"});
+	var rect = this.getBoundingClientRect();
+	return {x: rect.left + rect.width / 2, y: rect.top + rect.height / 2};
+}`
+
+// attributeScript returns element's property if it's a string (like selenium does, eg: resolved 'src'),
+// otherwise attribute
+const attributeScript = `function(name) {
+	var value = this[name];
+	if (typeof value === "string") {
+		return value;
+	}
+	return this.getAttribute(name);
+}`
+
+// chromedpDriver is a Driver that controls local Chrome over DevTools protocol, so selenium server isn't needed
+type chromedpDriver struct {
+	ctx    context.Context
+	cancel func()
+}
+
+// newChromedpDriver launches Chrome with options built from user supplied flags, browser is killed when ctx is
+// canceled, and it connects through proxy, if it isn't nil
+func newChromedpDriver(ctx context.Context, proxy *url.URL) (Driver, error) {
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", *headless),
+		chromedp.WindowSize(1920, 1080),
+	)
+
+	// profile directory is used the same way as with selenium, so session is kept between runs
+	if *browserProfileDir != "" {
+		dir, err := filepath.Abs(*browserProfileDir)
+		if err != nil {
+			return nil, fmt.Errorf("resolving browser profile directory: %w", err)
+		}
+		opts = append(opts, chromedp.UserDataDir(dir))
+	}
+
+	if proxy != nil {
+		opts = append(opts, chromedp.ProxyServer(proxy.String()))
+	}
+
+	if *browserBinary != "" {
+		opts = append(opts, chromedp.ExecPath(*browserBinary))
+	}
+	for _, arg := range *browserArgs {
+		opts = append(opts, chromeFlag(arg))
+	}
+
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, opts...)
+	browserCtx, cancelCtx := chromedp.NewContext(allocCtx)
+	cancel := func() {
+		cancelCtx()
+		cancelAlloc()
+	}
+
+	// running empty action starts browser
+	err := chromedp.Run(browserCtx)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("starting browser: %w", err)
+	}
+
+	return &chromedpDriver{ctx: browserCtx, cancel: cancel}, nil
+}
+
+func (d *chromedpDriver) Get(url string) error {
+	return chromedp.Run(d.ctx, chromedp.Navigate(url))
+}
+
+func (d *chromedpDriver) CurrentURL() (string, error) {
+	var url string
+	err := chromedp.Run(d.ctx, chromedp.Location(&url))
+	return url, err
+}
+
+func (d *chromedpDriver) ExecuteScript(script string, args []interface{}) (interface{}, error) {
+	document, err := d.document()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := d.callFunction(document, "function() {\n"+script+"\n}", args, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// undefined and null are both returned as nil, like selenium does
+	var value interface{}
+	if len(res.Value) > 0 {
+		err = json.Unmarshal(res.Value, &value)
+		if err != nil {
+			return nil, fmt.Errorf("decoding script result: %w", err)
+		}
+	}
+
+	return value, nil
+}
+
+func (d *chromedpDriver) FindElement(by, value string) (Element, error) {
+	document, err := d.document()
+	if err != nil {
+		return nil, err
+	}
+
+	return d.findElement(document, by, value)
+}
+
+func (d *chromedpDriver) FindElements(by, value string) ([]Element, error) {
+	document, err := d.document()
+	if err != nil {
+		return nil, err
+	}
+
+	return d.findElements(document, by, value)
+}
+
+func (d *chromedpDriver) PressKey(key string) error {
+	return chromedp.Run(d.ctx, chromedp.KeyEvent(translateKeys(key)))
+}
+
+func (d *chromedpDriver) Close() error {
+	d.cancel()
+	return nil
+}
+
+// document returns handle of page's document, functions are called on it, when they aren't called on element
+func (d *chromedpDriver) document() (runtime.RemoteObjectID, error) {
+	var res *runtime.RemoteObject
+	err := chromedp.Run(d.ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var exc *runtime.ExceptionDetails
+		var err error
+		res, exc, err = runtime.Evaluate("document").Do(ctx)
+		if err != nil {
+			return err
+		}
+		if exc != nil {
+			return exc
+		}
+		return nil
+	}))
+	if err != nil {
+		return "", fmt.Errorf("getting document: %w", err)
+	}
+
+	return res.ObjectID, nil
+}
+
+// callFunction calls function on object, elements in args are passed by their handles, and other values as json,
+// if byValue is false, then result is returned as handle
+func (d *chromedpDriver) callFunction(objectID runtime.RemoteObjectID, function string, args []interface{}, byValue bool) (*runtime.RemoteObject, error) {
+	callArgs := make([]*runtime.CallArgument, len(args))
+	for i, arg := range args {
+		if elem, ok := arg.(chromedpElement); ok {
+			callArgs[i] = &runtime.CallArgument{ObjectID: elem.objectID}
+			continue
+		}
+
+		value, err := json.Marshal(arg)
+		if err != nil {
+			return nil, fmt.Errorf("encoding script argument: %w", err)
+		}
+		callArgs[i] = &runtime.CallArgument{Value: value}
+	}
+
+	var res *runtime.RemoteObject
+	err := chromedp.Run(d.ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var exc *runtime.ExceptionDetails
+		var err error
+		res, exc, err = runtime.CallFunctionOn(function).
+			WithObjectID(objectID).
+			WithArguments(callArgs).
+			WithReturnByValue(byValue).
+			WithAwaitPromise(true).
+			Do(ctx)
+		if err != nil {
+			return err
+		}
+		if exc != nil {
+			return exc
+		}
+		return nil
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("calling script: %w", err)
+	}
+
+	return res, nil
+}
+
+// findElement finds first element matching selector inside of object
+func (d *chromedpDriver) findElement(objectID runtime.RemoteObjectID, by, value string) (Element, error) {
+	res, err := d.callFunction(objectID, findScript, []interface{}{by, value, false}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.ObjectID == "" {
+		return nil, fmt.Errorf("no such element: %s", value)
+	}
+
+	return chromedpElement{d: d, objectID: res.ObjectID}, nil
+}
+
+// findElements finds all elements matching selector inside of object, array's items are taken by their indexes
+func (d *chromedpDriver) findElements(objectID runtime.RemoteObjectID, by, value string) ([]Element, error) {
+	res, err := d.callFunction(objectID, findScript, []interface{}{by, value, true}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var props []*runtime.PropertyDescriptor
+	err = chromedp.Run(d.ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		props, _, _, _, err = runtime.GetProperties(res.ObjectID).WithOwnProperties(true).Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("getting found elements: %w", err)
+	}
+
+	elems := make([]Element, 0, len(props))
+	for _, prop := range props {
+		if _, err := strconv.Atoi(prop.Name); err != nil || prop.Value == nil || prop.Value.ObjectID == "" {
+			continue
+		}
+		elems = append(elems, chromedpElement{d: d, objectID: prop.Value.ObjectID})
+	}
+
+	return elems, nil
+}
+
+// chromedpElement is an Element found by chromedpDriver, it's referenced by its handle in page
+type chromedpElement struct {
+	d        *chromedpDriver
+	objectID runtime.RemoteObjectID
+}
+
+func (e chromedpElement) FindElement(by, value string) (Element, error) {
+	return e.d.findElement(e.objectID, by, value)
+}
+
+func (e chromedpElement) FindElements(by, value string) ([]Element, error) {
+	return e.d.findElements(e.objectID, by, value)
+}
+
+// Click clicks with mouse in the center of element, like user does, as some of Discord's handlers ignore
+// clicks done from scripts
+func (e chromedpElement) Click() error {
+	res, err := e.d.callFunction(e.objectID, clickPointScript, nil, true)
+	if err != nil {
+		return err
+	}
+
+	var point struct {
+		X float64 `json:"x"`
+		Y float64 `json:"y"`
+	}
+	err = json.Unmarshal(res.Value, &point)
+	if err != nil {
+		return fmt.Errorf("decoding element position: %w", err)
+	}
+
+	return chromedp.Run(e.d.ctx,
+		input.DispatchMouseEvent(input.MousePressed, point.X, point.Y).WithButton(input.Left).WithClickCount(1),
+		input.DispatchMouseEvent(input.MouseReleased, point.X, point.Y).WithButton(input.Left).WithClickCount(1),
+	)
+}
+
+func (e chromedpElement) SendKeys(keys string) error {
+	_, err := e.d.callFunction(e.objectID, "function() { this.focus(); }", nil, true)
+	if err != nil {
+		return err
+	}
+
+	return chromedp.Run(e.d.ctx, chromedp.KeyEvent(translateKeys(keys)))
+}
+
+func (e chromedpElement) GetAttribute(name string) (string, error) {
+	res, err := e.d.callFunction(e.objectID, attributeScript, []interface{}{name}, true)
+	if err != nil {
+		return "", err
+	}
+
+	var value *string
+	if len(res.Value) > 0 {
+		err = json.Unmarshal(res.Value, &value)
+		if err != nil {
+			return "", fmt.Errorf("decoding attribute: %w", err)
+		}
+	}
+
+	if value == nil {
+		return "", fmt.Errorf("element doesn't have %s attribute", name)
+	}
+
+	return *value, nil
+}
+
+// translateKeys replaces selenium's key codes with chromedp's ones
+func translateKeys(keys string) string {
+	return strings.NewReplacer(
+		selenium.EnterKey, kb.Enter,
+		selenium.EscapeKey, kb.Escape,
+	).Replace(keys)
+}
+
+// chromeFlag converts command line argument of Chrome (eg: --lang=en) to allocator option
+func chromeFlag(arg string) chromedp.ExecAllocatorOption {
+	arg = strings.TrimLeft(arg, "-")
+	i := strings.Index(arg, "=")
+	if i < 0 {
+		return chromedp.Flag(arg, true)
+	}
+
+	return chromedp.Flag(arg[:i], arg[i+1:])
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/tebeka/selenium"
+	"github.com/tebeka/selenium/chrome"
+	"github.com/tebeka/selenium/firefox"
+)
+
+// seleniumDriver is a Driver backed by selenium server, or by driver started by tool
+type seleniumDriver struct {
+	wd      selenium.WebDriver
+	service *selenium.Service // driver started by tool, it's stopped with browser
+}
+
+// seleniumServerURL returns URL of selenium server supplied by user, selenium port is kept for old setups, where
+// only local selenium server was supported
+func seleniumServerURL() string {
+	if *seleniumRemoteURL != "" {
+		return *seleniumRemoteURL
+	}
+
+	return fmt.Sprintf("http://localhost:%d/wd/hub", *seleniumPort)
+}
+
+// newSeleniumDriver creates new selenium web driver, with capabilities built from user supplied flags
+func newSeleniumDriver(proxy *url.URL) (Driver, error) {
+	caps, err := browserCapabilities(proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	seleniumURL := seleniumServerURL()
+
+	// driver is started for each browser, so broken driver is replaced together with browser
+	var service *selenium.Service
+	if webdriverPath != "" {
+		service, seleniumURL, err = startWebdriver()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	wd, err := selenium.NewRemote(caps, seleniumURL)
+	if err != nil {
+		if service != nil {
+			service.Stop()
+		}
+		return nil, err
+	}
+
+	return seleniumDriver{wd: wd, service: service}, nil
+}
+
+func (d seleniumDriver) Get(url string) error {
+	return d.wd.Get(url)
+}
+
+func (d seleniumDriver) CurrentURL() (string, error) {
+	return d.wd.CurrentURL()
+}
+
+func (d seleniumDriver) ExecuteScript(script string, args []interface{}) (interface{}, error) {
+	// selenium serializes only its own elements, so wrapped ones are unwrapped
+	unwrapped := make([]interface{}, len(args))
+	for i, arg := range args {
+		if elem, ok := arg.(seleniumElement); ok {
+			arg = elem.we
+		}
+		unwrapped[i] = arg
+	}
+
+	return d.wd.ExecuteScript(script, unwrapped)
+}
+
+func (d seleniumDriver) FindElement(by, value string) (Element, error) {
+	return wrapSeleniumElement(d.wd.FindElement(by, value))
+}
+
+func (d seleniumDriver) FindElements(by, value string) ([]Element, error) {
+	return wrapSeleniumElements(d.wd.FindElements(by, value))
+}
+
+func (d seleniumDriver) PressKey(key string) error {
+	err := d.wd.KeyDown(key)
+	if err != nil {
+		return err
+	}
+
+	return d.wd.KeyUp(key)
+}
+
+// Close ends session, so browser is closed, and session isn't left open on Selenium server (eg: Grid, where it would
+// take slot until timeout). Driver started by tool is stopped as well, as it can't be used for anything else.
+func (d seleniumDriver) Close() error {
+	err := d.wd.Quit()
+	if d.service != nil {
+		return d.service.Stop()
+	}
+
+	return err
+}
+
+// seleniumElement is an Element found by seleniumDriver
+type seleniumElement struct {
+	we selenium.WebElement
+}
+
+func wrapSeleniumElement(we selenium.WebElement, err error) (Element, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return seleniumElement{we: we}, nil
+}
+
+func wrapSeleniumElements(wes []selenium.WebElement, err error) ([]Element, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	elems := make([]Element, len(wes))
+	for i, we := range wes {
+		elems[i] = seleniumElement{we: we}
+	}
+
+	return elems, nil
+}
+
+func (e seleniumElement) FindElement(by, value string) (Element, error) {
+	return wrapSeleniumElement(e.we.FindElement(by, value))
+}
+
+func (e seleniumElement) FindElements(by, value string) ([]Element, error) {
+	return wrapSeleniumElements(e.we.FindElements(by, value))
+}
+
+func (e seleniumElement) Click() error {
+	return e.we.Click()
+}
+
+func (e seleniumElement) SendKeys(keys string) error {
+	return e.we.SendKeys(keys)
+}
+
+func (e seleniumElement) GetAttribute(name string) (string, error) {
+	return e.we.GetAttribute(name)
+}
+
+// browserCapabilities builds selenium capabilities for browser specified by user, browser connects through proxy,
+// if it isn't nil
+func browserCapabilities(proxy *url.URL) (selenium.Capabilities, error) {
+	caps := selenium.Capabilities{"browserName": *seleniumBrowser}
+
+	// the same proxy is used for http and https traffic of browser, or socks5 proxy for all of it
+	if proxy != nil {
+		if proxy.Scheme == "socks5" {
+			caps.AddProxy(selenium.Proxy{Type: selenium.Manual, SOCKS: proxy.Host, SOCKSVersion: 5})
+		} else {
+			caps.AddProxy(selenium.Proxy{Type: selenium.Manual, HTTP: proxy.Host, SSL: proxy.Host})
+		}
+	}
+
+	args := append([]string(nil), *browserArgs...)
+
+	// profile directory is passed as browser argument, instead of uploading it, so browser writes session back to it
+	if *browserProfileDir != "" {
+		dir, err := filepath.Abs(*browserProfileDir)
+		if err != nil {
+			return nil, fmt.Errorf("resolving browser profile directory: %w", err)
+		}
+
+		switch *seleniumBrowser {
+		case "firefox":
+			args = append(args, "-profile", dir)
+		case "chrome":
+			args = append(args, "--user-data-dir="+dir)
+		default:
+			return nil, fmt.Errorf("browser profile directory isn't supported for %s browser", *seleniumBrowser)
+		}
+	}
+
+	// headless browser doesn't need display, so tool can run on servers without X server or Xvfb
+	// window size is set, as default headless one is too small for member list to be shown
+	if *headless {
+		switch *seleniumBrowser {
+		case "firefox":
+			args = append(args, "-headless", "--width=1920", "--height=1080")
+		case "chrome":
+			args = append(args, "--headless", "--disable-gpu", "--no-sandbox", "--window-size=1920,1080")
+		default:
+			return nil, fmt.Errorf("headless mode isn't supported for %s browser", *seleniumBrowser)
+		}
+	}
+
+	if len(args) > 0 || *browserBinary != "" {
+		switch *seleniumBrowser {
+		case "firefox":
+			caps.AddFirefox(firefox.Capabilities{Binary: *browserBinary, Args: args})
+		case "chrome":
+			caps.AddChrome(chrome.Capabilities{Path: *browserBinary, Args: args})
+		default:
+			return nil, fmt.Errorf("browser binary and arguments aren't supported for %s browser", *seleniumBrowser)
+		}
+	}
+
+	// capabilities supplied by user are set last, so they replace ones built from other flags
+	for _, capability := range *capabilities {
+		key, value, err := parseCapability(capability)
+		if err != nil {
+			return nil, err
+		}
+		caps[key] = value
+	}
+
+	return caps, nil
+}
+
+// parseCapability parses capability in key=value format, value is decoded as json if it's valid json (number,
+// boolean, object and etc), otherwise it's a string, eg: 'selenoid:options={"enableVNC":true}'
+func parseCapability(capability string) (string, interface{}, error) {
+	i := strings.Index(capability, "=")
+	if i <= 0 {
+		return "", nil, fmt.Errorf("capability %s isn't in key=value format", capability)
+	}
+
+	key, raw := capability[:i], capability[i+1:]
+
+	var value interface{}
+	err := json.Unmarshal([]byte(raw), &value)
+	if err != nil {
+		value = raw
+	}
+
+	return key, value, nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/tebeka/selenium"
+)
+
+// geckodriverVersion is a version of geckodriver, that is downloaded, when it isn't installed
+const geckodriverVersion = "v0.35.0"
+
+// webdriverPath is a path to geckodriver or chromedriver, that tool starts itself, it's empty if selenium server
+// is used
+var webdriverPath string
+
+// webdriverName returns name of driver executable of browser supplied by user
+func webdriverName() (string, error) {
+	var name string
+	switch *seleniumBrowser {
+	case "firefox":
+		name = "geckodriver"
+	case "chrome":
+		name = "chromedriver"
+	default:
+		return "", fmt.Errorf("driver of %s browser can't be started by tool", *seleniumBrowser)
+	}
+
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+
+	return name, nil
+}
+
+// resolveWebdriver returns path to driver supplied by user. With 'auto' driver is searched in PATH, and geckodriver
+// is downloaded, if it isn't found, chromedriver isn't downloaded, as it has to match installed version of Chrome.
+func resolveWebdriver(driver string) (string, error) {
+	if driver != "auto" {
+		_, err := os.Stat(driver)
+		if err != nil {
+			return "", fmt.Errorf("checking driver: %w", err)
+		}
+
+		return driver, nil
+	}
+
+	name, err := webdriverName()
+	if err != nil {
+		return "", err
+	}
+
+	path, err := exec.LookPath(name)
+	if err == nil {
+		return path, nil
+	}
+
+	if *seleniumBrowser != "firefox" {
+		return "", fmt.Errorf("%s isn't found in PATH, install version matching Chrome or supply path to it", name)
+	}
+
+	return downloadGeckodriver(name)
+}
+
+// downloadGeckodriver downloads geckodriver release into cache directory of user, it's downloaded only once
+func downloadGeckodriver(name string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("getting cache directory: %w", err)
+	}
+
+	dir := filepath.Join(cacheDir, "discord-user-monitor", "geckodriver-"+geckodriverVersion)
+	path := filepath.Join(dir, name)
+	_, err = os.Stat(path)
+	if err == nil {
+		return path, nil
+	}
+
+	platforms := map[string]string{
+		"linux/amd64":   "linux64.tar.gz",
+		"linux/arm64":   "linux-aarch64.tar.gz",
+		"darwin/amd64":  "macos.tar.gz",
+		"darwin/arm64":  "macos-aarch64.tar.gz",
+		"windows/amd64": "win64.zip",
+		"windows/arm64": "win-aarch64.zip",
+	}
+	platform, ok := platforms[runtime.GOOS+"/"+runtime.GOARCH]
+	if !ok {
+		return "", fmt.Errorf("geckodriver isn't released for %s/%s, install it and supply path to it", runtime.GOOS, runtime.GOARCH)
+	}
+
+	err = os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return "", fmt.Errorf("creating geckodriver directory: %w", err)
+	}
+
+	archive := filepath.Join(dir, "geckodriver-"+platform)
+	url := fmt.Sprintf("https://github.com/mozilla/geckodriver/releases/download/%s/geckodriver-%s-%s", geckodriverVersion, geckodriverVersion, platform)
+	logger.Infof("Downloading geckodriver from %s", url)
+
+	err = downloadFile(&http.Client{Timeout: 5 * time.Minute}, url, archive)
+	if err != nil {
+		return "", fmt.Errorf("downloading geckodriver: %w", err)
+	}
+	defer os.Remove(archive)
+
+	if filepath.Ext(archive) == ".zip" {
+		err = extractZipFile(archive, name, path)
+	} else {
+		err = extractTarGzFile(archive, name, path)
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("extracting geckodriver: %w", err)
+	}
+
+	return path, nil
+}
+
+// extractTarGzFile extracts file with name from .tar.gz archive to executable file at dst
+func extractTarGzFile(archive, name, dst string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("%s isn't found in archive", name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if filepath.Base(hdr.Name) == name {
+			return writeExecutable(dst, tr)
+		}
+	}
+}
+
+// extractZipFile extracts file with name from .zip archive to executable file at dst
+func extractZipFile(archive, name, dst string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, file := range zr.File {
+		if filepath.Base(file.Name) != name {
+			continue
+		}
+
+		r, err := file.Open()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		return writeExecutable(dst, r)
+	}
+
+	return fmt.Errorf("%s isn't found in archive", name)
+}
+
+// writeExecutable writes r to file at path, that can be executed
+func writeExecutable(path string, r io.Reader) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, r)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// startWebdriver starts driver on free port, and returns url of its webdriver endpoint. Each browser has its own
+// driver, as geckodriver supports only one session at a time.
+func startWebdriver() (*selenium.Service, string, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, "", fmt.Errorf("finding free port for driver: %w", err)
+	}
+
+	// output of driver is written to log only if user wants to see it, as drivers log each command
+	var output io.Writer = ioutil.Discard
+	if *webdriverLog {
+		output = logger.Writer()
+	}
+
+	var service *selenium.Service
+	var url string
+	switch *seleniumBrowser {
+	case "firefox":
+		service, err = selenium.NewGeckoDriverService(webdriverPath, port, selenium.Output(output))
+		url = fmt.Sprintf("http://localhost:%d", port)
+	case "chrome":
+		service, err = selenium.NewChromeDriverService(webdriverPath, port, selenium.Output(output))
+		url = fmt.Sprintf("http://localhost:%d/wd/hub", port)
+	default:
+		err = errors.New("unknown browser")
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("starting driver: %w", err)
+	}
+
+	return service, url, nil
+}
+
+// freePort returns port, that isn't used by other processes now
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
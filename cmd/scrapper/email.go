@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// emailRecipient is a recipient of emails with its own settings, eg: 'ops@example.com alerts=all digest=08:00'
+type emailRecipient struct {
+	address    string
+	alerts     string   // notifications, that are sent immediately: all, failures or none
+	digest     string   // time of day (15:04), when digest of last 24 hours is sent, empty means it isn't sent
+	attach     []string // attachments of digest: csv and html
+	lastDigest time.Time
+}
+
+// emailRecipients are recipients of emails supplied with --email
+var emailRecipients []*emailRecipient
+
+// emailDigestInput is a path to output, where users of digests are read from, it's known after output is opened
+var emailDigestInput string
+
+// parseEmailRecipient parses recipient of emails, address is followed by space separated settings: alerts (all,
+// failures or none, default failures), digest (time of day, like 08:00) and attach (csv and html, default html).
+// First digest is sent at its time after start.
+func parseEmailRecipient(text string, now time.Time) (*emailRecipient, error) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.Contains(fields[0], "@") {
+		return nil, fmt.Errorf("email recipient %q doesn't start with address", text)
+	}
+
+	r := &emailRecipient{address: fields[0], alerts: "failures", attach: []string{"html"}, lastDigest: now}
+	for _, field := range fields[1:] {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("setting %q of email recipient %q isn't in key=value format", field, text)
+		}
+
+		key, value := strings.ToLower(parts[0]), parts[1]
+		switch key {
+		case "alerts":
+			if value != "all" && value != "failures" && value != "none" {
+				return nil, fmt.Errorf("alerts of email recipient %q are %s, they can be all, failures or none", text, value)
+			}
+			r.alerts = value
+		case "digest":
+			if value == "none" {
+				r.digest = ""
+				continue
+			}
+			if _, err := time.Parse("15:04", value); err != nil {
+				return nil, fmt.Errorf("digest of email recipient %q isn't time of day, like 08:00", text)
+			}
+			r.digest = value
+		case "attach":
+			r.attach = nil
+			for _, attachment := range strings.Split(value, ",") {
+				if attachment != "csv" && attachment != "html" && attachment != "none" {
+					return nil, fmt.Errorf("attachment %s of email recipient %q is unknown, known ones are csv and html", attachment, text)
+				}
+				if attachment != "none" {
+					r.attach = append(r.attach, attachment)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unknown setting %s of email recipient %q, known ones are alerts, digest and attach", key, text)
+		}
+	}
+
+	return r, nil
+}
+
+// parseEmailRecipients parses recipients supplied with --email
+func parseEmailRecipients(texts []string, now time.Time) ([]*emailRecipient, error) {
+	recipients := make([]*emailRecipient, 0, len(texts))
+	for _, text := range texts {
+		r, err := parseEmailRecipient(text, now)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, r)
+	}
+
+	return recipients, nil
+}
+
+// digestDue checks if digest has to be sent to recipient at now, it's sent once a day at its time
+func (r *emailRecipient) digestDue(now time.Time) bool {
+	if r.digest == "" {
+		return false
+	}
+
+	at, _ := time.Parse("15:04", r.digest)
+	scheduled := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+	if now.Before(scheduled) {
+		scheduled = scheduled.AddDate(0, 0, -1)
+	}
+
+	return r.lastDigest.Before(scheduled)
+}
+
+// emailAttachment is a file attached to email
+type emailAttachment struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+// sendEmailAlerts sends notification to recipients, that want it immediately, errors are logged
+func sendEmailAlerts(event, message string) {
+	for _, r := range emailRecipients {
+		if r.alerts == "none" || (r.alerts == "failures" && !containsString(failureEvents, event)) {
+			continue
+		}
+
+		body := fmt.Sprintf("%s\n\nEvent: %s\nTime: %s\n", message, event, time.Now().Format(timeFormat))
+		err := sendEmail(r.address, "Discord User Monitor: "+event, body, nil)
+		if err != nil {
+			logger.Errorf("Couldn't send notification to %s: %v", r.address, err)
+		}
+	}
+}
+
+// sendEmailDigests sends digests of last 24 hours to recipients, whose time of digest has come, users are read from
+// output, errors are logged
+func sendEmailDigests(now time.Time) {
+	var users []User
+	loaded := false
+	for _, r := range emailRecipients {
+		if !r.digestDue(now) {
+			continue
+		}
+		r.lastDigest = now
+
+		from := now.Add(-24 * time.Hour)
+		if !loaded {
+			stored, err := readUsers(emailDigestInput)
+			if err != nil {
+				logger.Errorf("Couldn't read users of digest: %v", err)
+				return
+			}
+			users = filterTimeRange(stored, from, now)
+			loaded = true
+		}
+
+		body, attachments, err := buildDigest(users, from, now, r.attach)
+		if err != nil {
+			logger.Errorf("Couldn't build digest: %v", err)
+			return
+		}
+
+		err = sendEmail(r.address, "Discord User Monitor: digest of "+now.Format("2006-01-02"), body, attachments)
+		if err != nil {
+			logger.Errorf("Couldn't send digest to %s: %v", r.address, err)
+			continue
+		}
+		logger.Infof("Digest is sent to %s", r.address)
+	}
+}
+
+// buildDigest builds text of digest with daily summary of each server, and its attachments: csv of samples and html
+// report
+func buildDigest(users []User, from, to time.Time, attach []string) (string, []emailAttachment, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Activity from %s to %s: %d samples\n\n", from.Format(timeFormat), to.Format(timeFormat), len(users))
+	for _, r := range analyzeDaily(users, time.Local) {
+		fmt.Fprintf(&b, "%s %s: peak online %d at %s, unique active %d, members %d (%+d)\n", r.Server, r.Date, r.PeakOnline,
+			r.PeakTime.Local().Format("15:04"), r.UniqueActive, r.Members, r.Growth)
+	}
+
+	attachments := make([]emailAttachment, 0, len(attach))
+	if len(users) == 0 {
+		return b.String(), attachments, nil
+	}
+
+	for _, attachment := range attach {
+		var data bytes.Buffer
+		switch attachment {
+		case "csv":
+			err := writeUsersCSV(&data, users)
+			if err != nil {
+				return "", nil, err
+			}
+			attachments = append(attachments, emailAttachment{name: "users-" + to.Format("2006-01-02") + ".csv", contentType: "text/csv", data: data.Bytes()})
+		case "html":
+			page, err := buildReportPage("Discord activity digest", users, 20, time.Local)
+			if err != nil {
+				return "", nil, err
+			}
+			err = reportTemplate.Execute(&data, page)
+			if err != nil {
+				return "", nil, fmt.Errorf("writing report: %w", err)
+			}
+			attachments = append(attachments, emailAttachment{name: "report-" + to.Format("2006-01-02") + ".html", contentType: "text/html", data: data.Bytes()})
+		}
+	}
+
+	return b.String(), attachments, nil
+}
+
+// writeUsersCSV writes users as csv with all columns, so it can be read by export and analyze subcommands
+func writeUsersCSV(w io.Writer, users []User) error {
+	cw := csv.NewWriter(w)
+	cw.Write(userColumns)
+	for _, user := range users {
+		cw.Write(userValues(user, userColumns))
+	}
+	cw.Flush()
+
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("writing csv: %w", err)
+	}
+	return nil
+}
+
+// buildEmail builds MIME message with text body and attachments, they are encoded in base64
+func buildEmail(from, to, subject, body string, attachments []emailAttachment) ([]byte, error) {
+	var msg bytes.Buffer
+	mw := multipart.NewWriter(&msg)
+
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n",
+		from, to, mime.QEncoding.Encode("utf-8", subject), time.Now().Format(time.RFC1123Z), mw.Boundary())
+
+	parts := append([]emailAttachment{{contentType: "text/plain; charset=utf-8", data: []byte(body)}}, attachments...)
+	for _, part := range parts {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "base64")
+		if part.name != "" {
+			header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": part.name}))
+		}
+
+		pw, err := mw.CreatePart(header)
+		if err != nil {
+			return nil, fmt.Errorf("creating part of email: %w", err)
+		}
+
+		// lines of base64 are limited to 76 characters by MIME
+		encoded := base64.StdEncoding.EncodeToString(part.data)
+		for len(encoded) > 76 {
+			fmt.Fprintf(pw, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(pw, "%s\r\n", encoded)
+	}
+
+	err := mw.Close()
+	if err != nil {
+		return nil, fmt.Errorf("closing email: %w", err)
+	}
+
+	return msg.Bytes(), nil
+}
+
+// sendEmail sends email to address with SMTP server, connection is upgraded with STARTTLS, if server supports it,
+// and port 465 uses implicit TLS
+func sendEmail(to, subject, body string, attachments []emailAttachment) error {
+	msg, err := buildEmail(*smtpFrom, to, subject, body, attachments)
+	if err != nil {
+		return err
+	}
+
+	host, port, err := net.SplitHostPort(*smtpAddr)
+	if err != nil {
+		return fmt.Errorf("parsing SMTP address: %w", err)
+	}
+
+	var auth smtp.Auth
+	if *smtpUsername != "" {
+		auth = smtp.PlainAuth("", *smtpUsername, *smtpPassword, host)
+	}
+
+	if port != "465" {
+		return smtp.SendMail(*smtpAddr, auth, *smtpFrom, []string{to}, msg)
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", *smtpAddr, &tls.Config{ServerName: host})
+	if err != nil {
+		return fmt.Errorf("connecting to SMTP server: %w", err)
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("connecting to SMTP server: %w", err)
+	}
+	defer client.Close()
+
+	if auth != nil {
+		if err = client.Auth(auth); err != nil {
+			return fmt.Errorf("authenticating to SMTP server: %w", err)
+		}
+	}
+	if err = client.Mail(*smtpFrom); err != nil {
+		return fmt.Errorf("sending sender: %w", err)
+	}
+	if err = client.Rcpt(to); err != nil {
+		return fmt.Errorf("sending recipient: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("sending email: %w", err)
+	}
+	if _, err = w.Write(msg); err != nil {
+		return fmt.Errorf("sending email: %w", err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("sending email: %w", err)
+	}
+
+	return client.Quit()
+}
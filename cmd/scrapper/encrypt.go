@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+)
+
+// outputRecipients are recipients of encrypted output files, either public keys or passphrase
+var outputRecipients []age.Recipient
+
+// loadOutputRecipients parses recipients supplied by user, they are either age public keys (age1...) or paths to
+// files with public keys. If none are supplied, output is encrypted with passphrase, that is read from
+// OUTPUT_PASSPHRASE environment variable or passphrase file, or it's asked in terminal.
+func loadOutputRecipients() error {
+	for _, recipient := range *encryptRecipients {
+		if strings.HasPrefix(recipient, "age1") {
+			r, err := age.ParseX25519Recipient(recipient)
+			if err != nil {
+				return fmt.Errorf("parsing recipient: %w", err)
+			}
+			outputRecipients = append(outputRecipients, r)
+			continue
+		}
+
+		file, err := os.Open(recipient)
+		if err != nil {
+			return fmt.Errorf("opening recipients file: %w", err)
+		}
+		recipients, err := age.ParseRecipients(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("parsing recipients file: %w", err)
+		}
+		outputRecipients = append(outputRecipients, recipients...)
+	}
+
+	if len(outputRecipients) > 0 {
+		return nil
+	}
+
+	passphrase := os.Getenv("OUTPUT_PASSPHRASE")
+	if *encryptPassphraseFile != "" {
+		var err error
+		passphrase, err = readSecretFile(*encryptPassphraseFile)
+		if err != nil {
+			return err
+		}
+	}
+	if passphrase == "" {
+		var err error
+		passphrase, err = promptPassword("Enter output passphrase: ")
+		if err != nil {
+			return err
+		}
+	}
+
+	r, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return fmt.Errorf("creating passphrase recipient: %w", err)
+	}
+	outputRecipients = append(outputRecipients, r)
+
+	return nil
+}
+
+// encryptedRecordWriter writes users of each cycle into their own encrypted file, age file is one stream, that can't
+// be appended to, so each file is finished at once, and users of earlier cycles aren't lost, if tool crashes. Files
+// are named after output file with time of cycle, like users-2006-01-02T15-04-05.000.csv.age.
+type encryptedRecordWriter struct {
+	format   string
+	path     string
+	compress bool
+}
+
+// newEncryptedRecordWriter creates writer of output format, that is encrypted for outputRecipients
+func newEncryptedRecordWriter(format, path string, compress bool) *encryptedRecordWriter {
+	return &encryptedRecordWriter{format: format, path: path, compress: compress}
+}
+
+func (w *encryptedRecordWriter) Write(users []User) error {
+	// cycle without users doesn't need its own file
+	if len(users) == 0 {
+		return nil
+	}
+
+	// file is written into temporary file first, so it's never left half-written
+	return writeFileAtomic(encryptedCyclePath(w.path, time.Now()), func(dst io.Writer) error {
+		enc, err := age.Encrypt(dst, outputRecipients...)
+		if err != nil {
+			return fmt.Errorf("encrypting output file: %w", err)
+		}
+
+		fw, err := newRecordWriter(w.format, enc, w.compress)
+		if err != nil {
+			return err
+		}
+
+		err = fw.Write(users)
+		if err != nil {
+			return err
+		}
+
+		if c, ok := fw.(io.Closer); ok {
+			err = c.Close()
+			if err != nil {
+				return err
+			}
+		}
+
+		return enc.Close()
+	})
+}
+
+// encryptedCyclePath returns path of encrypted file of cycle, time is put before extensions of output file, and .age
+// extension is added, if output file doesn't have it
+func encryptedCyclePath(path string, t time.Time) string {
+	dir, name := filepath.Split(strings.TrimSuffix(path, ".age"))
+
+	ext := ""
+	if i := strings.Index(name, "."); i > 0 {
+		name, ext = name[:i], name[i:]
+	}
+
+	return filepath.Join(dir, name+"-"+t.Format(backupTimeFormat)+ext+".age")
+}
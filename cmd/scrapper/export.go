@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// exportCommand converts users stored in one output file (or SQLite database) into another format, eg: csv of
+// several months into parquet, that is analyzed by other tools
+func exportCommand(args []string) int {
+	if len(args) > 0 && args[0] == "merge" {
+		return exportMergeCommand(args[1:])
+	}
+
+	flags := pflag.NewFlagSet("export", pflag.ExitOnError)
+	input := flags.StringP("input", "i", "", "path to file (csv, json or ndjson, optionally .gz) or SQLite database (sqlite://users.db), where users are read from")
+	output := flags.StringP("output", "o", "", "path to file or SQLite database (sqlite://users.db), where users are written, they are appended if it exists")
+	format := flags.String("format", "csv", "format of output file (csv, json, ndjson, parquet or xlsx)")
+	exportColumns := flags.StringSlice("columns", nil, "comma separated columns of output, in their order, all columns by default")
+	exportTimeFormat := flags.String("time-format", "rfc3339", "format of status_time column (rfc3339, rfc3339nano, unix, unix-ms, or Go layout)")
+	exportTimezone := flags.String("timezone", "UTC", "timezone of status_time column (UTC, Local, or IANA name)")
+	flags.Parse(args)
+
+	logger = newLogger(os.Stderr, levelInfo, false)
+
+	if *input == "" || *output == "" {
+		fmt.Fprintln(os.Stderr, "Usage: scrapper export --input <file> --output <file> [--format <format>] [--columns <columns>]")
+		flags.PrintDefaults()
+		return 1
+	}
+
+	var err error
+	outputColumns, err = parseColumns(*exportColumns)
+	if err != nil {
+		logger.Errorf("Couldn't parse columns: %v", err)
+		return 1
+	}
+
+	// input is read before format is changed, so it's parsed in format of default output
+	users, err := readUsers(*input)
+	if err != nil {
+		logger.Errorf("Couldn't read users: %v", err)
+		return 1
+	}
+
+	err = initTimeFormat(*exportTimeFormat, *exportTimezone)
+	if err != nil {
+		logger.Errorf("Couldn't parse time format: %v", err)
+		return 1
+	}
+
+	var writer recordWriter
+	if isSQLiteOutput(*output) {
+		db, err := openSQLite(strings.TrimPrefix(*output, sqliteOutputPrefix))
+		if err != nil {
+			logger.Errorf("Couldn't open output database: %v", err)
+			return 1
+		}
+		defer db.Close()
+
+		writer = db
+	} else {
+		file, err := openOutputFile(*output)
+		if err != nil {
+			logger.Errorf("Couldn't open output file: %v", err)
+			return 1
+		}
+		defer file.Close()
+
+		writer, err = newFileRecordWriter(*format, file)
+		if err != nil {
+			logger.Errorf("Couldn't create output writer: %v", err)
+			return 1
+		}
+	}
+
+	err = writer.Write(users)
+	if err != nil {
+		logger.Errorf("Couldn't write users: %v", err)
+		return 1
+	}
+	closeRecordWriter(writer)
+
+	logger.Infof("Exported %d users to %s", len(users), *output)
+	return 0
+}
+
+// formatOfPath returns format of output file detected by its extension, eg: users.parquet or users.csv.gz, empty
+// format is returned for unknown extension
+func formatOfPath(path string) string {
+	name := strings.TrimSuffix(strings.TrimSuffix(path, ".age"), ".gz")
+	switch ext := strings.ToLower(filepath.Ext(name)); ext {
+	case ".csv", ".json", ".ndjson", ".parquet", ".xlsx":
+		return strings.TrimPrefix(ext, ".")
+	default:
+		return ""
+	}
+}
+
+// mergeUsers returns users of all inputs sorted by time, the same sample of user (server, channel, user and time)
+// found in several inputs is kept only once, from first of them
+func mergeUsers(inputs [][]User) []User {
+	seen := make(map[string]bool)
+	merged := make([]User, 0)
+	for _, users := range inputs {
+		for _, user := range users {
+			key := strings.Join([]string{user.Server, user.Channel, userKey(user), user.StatusTime.UTC().Format(time.RFC3339Nano)}, "\x00")
+			if seen[key] {
+				continue
+			}
+
+			seen[key] = true
+			merged = append(merged, user)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].StatusTime.Before(merged[j].StatusTime.Time)
+	})
+
+	return merged
+}
+
+// exportMergeCommand merges users stored in several output files (or SQLite databases) into one, eg: files of
+// several instances or runs, overlapping samples are written once
+func exportMergeCommand(args []string) int {
+	flags := pflag.NewFlagSet("export merge", pflag.ExitOnError)
+	output := flags.StringP("output", "o", "", "path to file or SQLite database (sqlite://users.db), where merged users are written, they are appended if it exists")
+	format := flags.String("format", "", "format of output file (csv, json, ndjson, parquet or xlsx), it's detected by extension of output by default")
+	exportColumns := flags.StringSlice("columns", nil, "comma separated columns of output, in their order, all columns by default")
+	exportTimeFormat := flags.String("time-format", "rfc3339", "format of status_time column (rfc3339, rfc3339nano, unix, unix-ms, or Go layout)")
+	exportTimezone := flags.String("timezone", "UTC", "timezone of status_time column (UTC, Local, or IANA name)")
+
+	// --out is shorter name of output, like in 'export merge a.csv b.csv --out combined.parquet'
+	flags.SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "out" {
+			name = "output"
+		}
+		return pflag.NormalizedName(name)
+	})
+	flags.Parse(args)
+
+	logger = newLogger(os.Stderr, levelInfo, false)
+
+	inputs := flags.Args()
+	if *format == "" {
+		*format = formatOfPath(*output)
+	}
+	if len(inputs) == 0 || *output == "" || (*format == "" && !isSQLiteOutput(*output)) {
+		fmt.Fprintln(os.Stderr, "Usage: scrapper export merge <file> [<file>...] --output <file> [--format <format>] [--columns <columns>]")
+		flags.PrintDefaults()
+		return 1
+	}
+	for _, input := range inputs {
+		if input == *output {
+			logger.Errorf("Couldn't merge %s into itself, supply another output", input)
+			return 1
+		}
+	}
+
+	var err error
+	outputColumns, err = parseColumns(*exportColumns)
+	if err != nil {
+		logger.Errorf("Couldn't parse columns: %v", err)
+		return 1
+	}
+
+	// inputs are read before format is changed, so they are parsed in format of default output
+	stored := make([][]User, 0, len(inputs))
+	total := 0
+	for _, input := range inputs {
+		users, err := readUsers(input)
+		if err != nil {
+			logger.Errorf("Couldn't read users from %s: %v", input, err)
+			return 1
+		}
+
+		stored = append(stored, users)
+		total += len(users)
+	}
+	users := mergeUsers(stored)
+
+	err = initTimeFormat(*exportTimeFormat, *exportTimezone)
+	if err != nil {
+		logger.Errorf("Couldn't parse time format: %v", err)
+		return 1
+	}
+
+	var writer recordWriter
+	if isSQLiteOutput(*output) {
+		db, err := openSQLite(strings.TrimPrefix(*output, sqliteOutputPrefix))
+		if err != nil {
+			logger.Errorf("Couldn't open output database: %v", err)
+			return 1
+		}
+		defer db.Close()
+
+		writer = db
+	} else {
+		file, err := openOutputFile(*output)
+		if err != nil {
+			logger.Errorf("Couldn't open output file: %v", err)
+			return 1
+		}
+		defer file.Close()
+
+		writer, err = newFileRecordWriter(*format, file)
+		if err != nil {
+			logger.Errorf("Couldn't create output writer: %v", err)
+			return 1
+		}
+	}
+
+	err = writer.Write(users)
+	if err != nil {
+		logger.Errorf("Couldn't write users: %v", err)
+		return 1
+	}
+	closeRecordWriter(writer)
+
+	logger.Infof("Merged %d users from %d inputs into %s, %d overlapping ones are skipped", len(users), len(inputs), *output, total-len(users))
+	return 0
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// userPattern matches user either by name or by regular expression
+type userPattern struct {
+	name   string
+	regexp *regexp.Regexp
+}
+
+// match checks if any of user's names or ID matches pattern, names are compared case insensitively
+func (p userPattern) match(user User) bool {
+	for _, value := range []string{user.Username, user.Nickname, user.GlobalUsername, user.ID} {
+		if value == "" {
+			continue
+		}
+
+		if p.regexp != nil {
+			if p.regexp.MatchString(value) {
+				return true
+			}
+		} else if strings.EqualFold(p.name, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	includeUserPatterns []userPattern
+	excludeUserPatterns []userPattern
+	watchPatterns       []userPattern // users monitored in watchlist mode
+)
+
+// initFilters compiles user patterns supplied by user
+func initFilters() error {
+	var err error
+
+	includeUserPatterns, err = compileUserPatterns(splitUserValues(*includeUsers))
+	if err != nil {
+		return err
+	}
+
+	excludeUserPatterns, err = compileUserPatterns(splitUserValues(*excludeUsers))
+	if err != nil {
+		return err
+	}
+
+	watched := splitUserValues(*watchUsers)
+	if *pathToWatchFile != "" {
+		fromFile, err := readWatchFile(*pathToWatchFile)
+		if err != nil {
+			return err
+		}
+		watched = append(watched, fromFile...)
+	}
+
+	watchPatterns, err = compileUserPatterns(watched)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// splitUserValues splits comma separated values of user flags, commas inside of regular expressions (eg:
+// '/^a{1,3}$/') don't split them, so they are joined back, if they were split already
+func splitUserValues(values []string) []string {
+	parts := strings.Split(strings.Join(values, ","), ",")
+	result := make([]string, 0, len(parts))
+	for i := 0; i < len(parts); i++ {
+		part := parts[i]
+		for isUnclosedPattern(part) && i+1 < len(parts) {
+			i++
+			part += "," + parts[i]
+		}
+		result = append(result, part)
+	}
+
+	return result
+}
+
+// isUnclosedPattern checks if value starts regular expression, but its closing slash is in next values
+func isUnclosedPattern(value string) bool {
+	value = strings.TrimSpace(value)
+	return strings.HasPrefix(value, "/") && (len(value) == 1 || !strings.HasSuffix(value, "/"))
+}
+
+// compileUserPatterns compiles values into patterns, values wrapped in slashes (eg: '/^bot-.*/') are regular
+// expressions, all others are names
+func compileUserPatterns(values []string) ([]userPattern, error) {
+	patterns := make([]userPattern, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		if len(v) > 1 && strings.HasPrefix(v, "/") && strings.HasSuffix(v, "/") {
+			re, err := regexp.Compile(v[1 : len(v)-1])
+			if err != nil {
+				return nil, fmt.Errorf("compiling user pattern %s: %w", v, err)
+			}
+			patterns = append(patterns, userPattern{regexp: re})
+			continue
+		}
+
+		patterns = append(patterns, userPattern{name: v})
+	}
+
+	return patterns, nil
+}
+
+// readWatchFile reads watchlist file, it contains one user per line, empty lines and lines starting with '#' are skipped
+func readWatchFile(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading watchlist file: %w", err)
+	}
+
+	users := make([]string, 0)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		users = append(users, line)
+	}
+
+	return users, nil
+}
+
+// allWatchedFound checks if every watched user is present in users
+func allWatchedFound(users map[string]User) bool {
+	for _, p := range watchPatterns {
+		found := false
+		for _, user := range users {
+			if p.match(user) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchAny checks if user matches any of patterns
+func matchAny(patterns []userPattern, user User) bool {
+	for _, p := range patterns {
+		if p.match(user) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keepUser checks if user passes filters supplied by user, users that don't pass aren't added to output
+func keepUser(user User) bool {
+	if *skipBots && user.Type == "bot" {
+		return false
+	}
+
+	if len(watchPatterns) > 0 && !matchAny(watchPatterns, user) {
+		return false
+	}
+
+	if len(includeUserPatterns) > 0 && !matchAny(includeUserPatterns, user) {
+		return false
+	}
+
+	if matchAny(excludeUserPatterns, user) {
+		return false
+	}
+
+	// role sections are compared case insensitively, as Discord shows them in upper case
+	if len(*includeRoles) > 0 && !containsFold(*includeRoles, user.RoleGroup) {
+		return false
+	}
+
+	if containsFold(*excludeRoles, user.RoleGroup) {
+		return false
+	}
+
+	return true
+}
+
+// containsFold checks if values contains s, ignoring case
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,641 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// discordGatewayURL is an address of Discord gateway, payloads are sent as plain json without compression
+const discordGatewayURL = "wss://gateway.discord.gg/?v=10&encoding=json"
+
+// gatewayReadTimeout is maximum time to wait for next payload from gateway, Discord sends heartbeat ACKs more
+// often, so connection is considered broken after it
+const gatewayReadTimeout = 60 * time.Second
+
+// gatewayListTimeout is maximum time to wait for members, after which collected ones are returned
+const gatewayListTimeout = 15 * time.Second
+
+// gateway opcodes
+const (
+	gatewayOpDispatch            = 0
+	gatewayOpHeartbeat           = 1
+	gatewayOpIdentify            = 2
+	gatewayOpReconnect           = 7
+	gatewayOpRequestGuildMembers = 8
+	gatewayOpInvalidSession      = 9
+	gatewayOpHello               = 10
+	gatewayOpHeartbeatACK        = 11
+	gatewayOpGuildSubscriptions  = 14 // subscribes user account to member list updates, like sidebar does
+)
+
+// gateway intents needed by bot accounts to receive members and their presences, GUILD_MEMBERS and GUILD_PRESENCES
+// are privileged, they have to be enabled in bot's settings of Discord Developer Portal
+const (
+	intentGuilds         = 1 << 0
+	intentGuildMembers   = 1 << 1
+	intentGuildPresences = 1 << 8
+)
+
+// gatewayPayload is a message sent and received over gateway
+type gatewayPayload struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+	S  int64           `json:"s,omitempty"`
+	T  string          `json:"t,omitempty"`
+}
+
+// gatewayGuild is a guild object received over gateway, user accounts receive name in properties
+type gatewayGuild struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Unavailable bool   `json:"unavailable"`
+	Properties  struct {
+		Name string `json:"name"`
+	} `json:"properties"`
+	Roles     []discordRole     `json:"roles"`
+	Presences []discordPresence `json:"presences"` // presences of online members, bots receive them with presence intent
+	Channels  []struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		Type     int    `json:"type"`
+		Position int    `json:"position"`
+	} `json:"channels"`
+}
+
+// name returns name of guild, wherever it's located
+func (g gatewayGuild) name() string {
+	if g.Name != "" {
+		return g.Name
+	}
+
+	return g.Properties.Name
+}
+
+// gatewayConn is a connection to Discord gateway, payloads are read by one goroutine, while heartbeats are sent
+// from another one
+type gatewayConn struct {
+	seq  int64 // sequence number of last received dispatch, it's sent with heartbeats, it goes first to be aligned for atomic
+	conn net.Conn
+	r    io.Reader
+	mu   sync.Mutex // guards writes to conn
+	done chan struct{}
+
+	userID string // ID of logged in account, it's received in READY event
+}
+
+// dialGateway connects to Discord gateway and identifies with token, bot tokens identify with intents needed to
+// receive members and presences. Connection is closed when ctx is canceled.
+func dialGateway(ctx context.Context, token string, bot bool) (*gatewayConn, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	conn, br, _, err := ws.Dial(dialCtx, discordGatewayURL)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to gateway: %w", err)
+	}
+
+	c := &gatewayConn{conn: conn, r: conn, done: make(chan struct{})}
+	if br != nil { // some of data could be already read during handshake
+		c.r = io.MultiReader(br, conn)
+	}
+
+	// events are waited for without deadline, so connection is closed to interrupt reading, when tool is stopped
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-c.done:
+		}
+	}()
+
+	payload, err := c.read()
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if payload.Op != gatewayOpHello {
+		c.Close()
+		return nil, fmt.Errorf("expected hello from gateway, got opcode %d", payload.Op)
+	}
+
+	var hello struct {
+		HeartbeatInterval int `json:"heartbeat_interval"`
+	}
+	err = json.Unmarshal(payload.D, &hello)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("decoding hello: %w", err)
+	}
+	go c.heartbeat(time.Duration(hello.HeartbeatInterval) * time.Millisecond)
+
+	identify := map[string]interface{}{
+		"token": token,
+		"properties": map[string]string{
+			"os":      runtime.GOOS,
+			"browser": "discord-user-monitor",
+			"device":  "discord-user-monitor",
+		},
+	}
+	if bot {
+		identify["intents"] = intentGuilds | intentGuildMembers | intentGuildPresences
+	}
+
+	err = c.send(gatewayOpIdentify, identify)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Read reads from connection, it's used by wsutil together with Write
+func (c *gatewayConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// Write writes to connection, it's used by wsutil to answer control frames
+func (c *gatewayConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.conn.Write(p)
+}
+
+// send sends payload with opcode and data to gateway
+func (c *gatewayConn) send(op int, d interface{}) error {
+	data, err := json.Marshal(map[string]interface{}{"op": op, "d": d})
+	if err != nil {
+		return fmt.Errorf("encoding gateway payload: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err = wsutil.WriteClientText(c.conn, data)
+	if err != nil {
+		return fmt.Errorf("sending gateway payload: %w", err)
+	}
+
+	return nil
+}
+
+// read reads next payload from gateway
+func (c *gatewayConn) read() (gatewayPayload, error) {
+	var payload gatewayPayload
+
+	err := c.conn.SetReadDeadline(time.Now().Add(gatewayReadTimeout))
+	if err != nil {
+		return payload, err
+	}
+
+	data, _, err := wsutil.ReadServerData(c)
+	if err != nil {
+		var closed wsutil.ClosedError
+		if errors.As(err, &closed) {
+			return payload, fmt.Errorf("gateway closed connection: %d %s", closed.Code, closed.Reason)
+		}
+		return payload, fmt.Errorf("reading gateway payload: %w", err)
+	}
+
+	err = json.Unmarshal(data, &payload)
+	if err != nil {
+		return payload, fmt.Errorf("decoding gateway payload: %w", err)
+	}
+
+	if payload.S != 0 {
+		atomic.StoreInt64(&c.seq, payload.S)
+	}
+
+	return payload, nil
+}
+
+// nextEvent reads payloads until dispatched event is received, other payloads are handled in place
+func (c *gatewayConn) nextEvent() (string, json.RawMessage, error) {
+	for {
+		payload, err := c.read()
+		if err != nil {
+			return "", nil, err
+		}
+
+		switch payload.Op {
+		case gatewayOpDispatch:
+			return payload.T, payload.D, nil
+		case gatewayOpHeartbeat: // gateway asks to send heartbeat right now
+			err = c.sendHeartbeat()
+			if err != nil {
+				return "", nil, err
+			}
+		case gatewayOpReconnect:
+			return "", nil, errors.New("gateway asked to reconnect")
+		case gatewayOpInvalidSession:
+			return "", nil, errors.New("gateway invalidated session, check if token is correct")
+		}
+	}
+}
+
+// heartbeat sends heartbeats every interval, until connection is closed
+func (c *gatewayConn) heartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			err := c.sendHeartbeat()
+			if err != nil {
+				logger.Warnf("Sending heartbeat to gateway: %v", err)
+				return
+			}
+		}
+	}
+}
+
+// sendHeartbeat sends heartbeat with last received sequence number
+func (c *gatewayConn) sendHeartbeat() error {
+	var seq interface{}
+	if s := atomic.LoadInt64(&c.seq); s != 0 {
+		seq = s
+	}
+
+	return c.send(gatewayOpHeartbeat, seq)
+}
+
+// Close stops heartbeats and closes connection
+func (c *gatewayConn) Close() error {
+	close(c.done)
+	return c.conn.Close()
+}
+
+// waitGuilds waits until gateway sends guilds of account, user accounts receive them in READY event, and bots
+// receive them one by one in GUILD_CREATE events after it
+func (c *gatewayConn) waitGuilds() ([]gatewayGuild, error) {
+	var ready struct {
+		User   discordUser    `json:"user"`
+		Guilds []gatewayGuild `json:"guilds"`
+	}
+
+	for {
+		event, data, err := c.nextEvent()
+		if err != nil {
+			return nil, err
+		}
+
+		if event == "READY" {
+			err = json.Unmarshal(data, &ready)
+			if err != nil {
+				return nil, fmt.Errorf("decoding ready event: %w", err)
+			}
+			break
+		}
+	}
+	c.userID = ready.User.ID
+
+	guilds := make([]gatewayGuild, 0, len(ready.Guilds))
+	pending := 0
+	for _, guild := range ready.Guilds {
+		if guild.Unavailable {
+			pending++
+			continue
+		}
+		guilds = append(guilds, guild)
+	}
+
+	for pending > 0 {
+		event, data, err := c.nextEvent()
+		if err != nil {
+			return nil, err
+		}
+		if event != "GUILD_CREATE" {
+			continue
+		}
+
+		var guild gatewayGuild
+		err = json.Unmarshal(data, &guild)
+		if err != nil {
+			return nil, fmt.Errorf("decoding guild: %w", err)
+		}
+		guilds = append(guilds, guild)
+		pending--
+	}
+
+	return guilds, nil
+}
+
+// findGuild finds guild of server either by ID or by name
+func findGuild(guilds []gatewayGuild, server Server) (gatewayGuild, bool) {
+	for _, guild := range guilds {
+		if server.ID != "" && guild.ID == server.ID {
+			return guild, true
+		}
+		if server.ID == "" && strings.EqualFold(guild.name(), server.Name) {
+			return guild, true
+		}
+	}
+
+	return gatewayGuild{}, false
+}
+
+// requestGuildMembers requests all members of guild with their presences, gateway sends them in chunks, it's
+// available only for bots
+func (c *gatewayConn) requestGuildMembers(guild gatewayGuild, server Server) (map[string]User, error) {
+	err := c.send(gatewayOpRequestGuildMembers, map[string]interface{}{
+		"guild_id":  guild.ID,
+		"query":     "",
+		"limit":     0,
+		"presences": true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	users := make(map[string]User)
+	for {
+		event, data, err := c.nextEvent()
+		if err != nil {
+			return nil, err
+		}
+		if event != "GUILD_MEMBERS_CHUNK" {
+			continue
+		}
+
+		var chunk struct {
+			GuildID    string            `json:"guild_id"`
+			Members    []discordMember   `json:"members"`
+			Presences  []discordPresence `json:"presences"`
+			ChunkIndex int               `json:"chunk_index"`
+			ChunkCount int               `json:"chunk_count"`
+		}
+		err = json.Unmarshal(data, &chunk)
+		if err != nil {
+			return nil, fmt.Errorf("decoding members chunk: %w", err)
+		}
+		if chunk.GuildID != guild.ID {
+			continue
+		}
+
+		presences := make(map[string]*discordPresence, len(chunk.Presences))
+		for i := range chunk.Presences {
+			presences[chunk.Presences[i].User.ID] = &chunk.Presences[i]
+		}
+
+		for _, member := range chunk.Members {
+			if member.User.ID == c.userID {
+				continue
+			}
+
+			user := member.toUser(server, Channel{}, presences[member.User.ID], "")
+			user.RoleGroup = member.roleGroup(guild.Roles, user.Status)
+			users[user.ID] = user
+		}
+
+		if chunk.ChunkIndex+1 >= chunk.ChunkCount {
+			return users, nil
+		}
+	}
+}
+
+// gatewayListItem is an item of member list, either role section header or member
+type gatewayListItem struct {
+	Group *struct {
+		ID    string `json:"id"`
+		Count int    `json:"count"`
+	} `json:"group"`
+	Member *discordMember `json:"member"`
+}
+
+// requestMemberList subscribes to member list of guild's channel and pages through it, 100 items at a time, the
+// same way as sidebar does when it's scrolled, it's available only for user accounts
+func (c *gatewayConn) requestMemberList(guild gatewayGuild, server Server, channel Channel) (map[string]User, error) {
+	channelID := guildChannelID(guild, channel)
+	if channelID == "" {
+		return nil, fmt.Errorf("channel %s isn't found", channel)
+	}
+
+	roleNames := make(map[string]string, len(guild.Roles))
+	for _, role := range guild.Roles {
+		roleNames[role.ID] = role.Name
+	}
+
+	users := make(map[string]User)
+	for start := 0; ; start += 200 {
+		// first range has to be always requested, others are subscribed to as list is scrolled
+		ranges := [][2]int{{0, 99}, {start + 100, start + 199}}
+		if start > 0 {
+			ranges = [][2]int{{0, 99}, {start, start + 99}, {start + 100, start + 199}}
+		}
+
+		err := c.send(gatewayOpGuildSubscriptions, map[string]interface{}{
+			"guild_id":   guild.ID,
+			"typing":     true,
+			"activities": true,
+			"threads":    false,
+			"channels":   map[string]interface{}{channelID: ranges},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		total, err := c.readMemberListSync(guild, server, channel, roleNames, users)
+		if err != nil {
+			return nil, err
+		}
+
+		if start+200 >= total {
+			return users, nil
+		}
+	}
+}
+
+// readMemberListSync reads member list update with synced ranges, and adds members from it to users, it returns
+// total amount of items (role section headers and members) in list
+func (c *gatewayConn) readMemberListSync(guild gatewayGuild, server Server, channel Channel, roleNames map[string]string, users map[string]User) (int, error) {
+	deadline := time.Now().Add(gatewayListTimeout)
+	for time.Now().Before(deadline) {
+		event, data, err := c.nextEvent()
+		if err != nil {
+			return 0, err
+		}
+		if event != "GUILD_MEMBER_LIST_UPDATE" {
+			continue
+		}
+
+		var update struct {
+			GuildID string `json:"guild_id"`
+			Groups  []struct {
+				ID    string `json:"id"`
+				Count int    `json:"count"`
+			} `json:"groups"`
+			Ops []struct {
+				Op    string            `json:"op"`
+				Items []gatewayListItem `json:"items"`
+			} `json:"ops"`
+		}
+		err = json.Unmarshal(data, &update)
+		if err != nil {
+			return 0, fmt.Errorf("decoding member list update: %w", err)
+		}
+		if update.GuildID != guild.ID {
+			continue
+		}
+
+		synced := false
+		for _, op := range update.Ops {
+			if op.Op != "SYNC" {
+				continue
+			}
+			synced = true
+
+			// members follow header of their role section
+			var group string
+			for _, item := range op.Items {
+				if item.Group != nil {
+					group = listGroupName(item.Group.ID, roleNames)
+					continue
+				}
+				if item.Member == nil || item.Member.User.ID == c.userID {
+					continue
+				}
+
+				user := item.Member.toUser(server, channel, item.Member.Presence, group)
+				if user.RoleGroup == "" {
+					user.RoleGroup = item.Member.roleGroup(guild.Roles, user.Status)
+				}
+				users[user.ID] = user
+			}
+		}
+
+		if synced {
+			total := 0
+			for _, g := range update.Groups {
+				total += g.Count + 1 // header and its members
+			}
+			return total, nil
+		}
+	}
+
+	return 0, errors.New("member list wasn't received in time")
+}
+
+// listGroupName returns name of member list's group, it's either role ID or online/offline
+func listGroupName(id string, roleNames map[string]string) string {
+	if name, ok := roleNames[id]; ok {
+		return name
+	}
+	if status, ok := discordStatuses[id]; ok {
+		return status
+	}
+
+	return id
+}
+
+// guildChannelID returns ID of guild's channel supplied by user, or ID of the topmost text channel if user didn't
+// supply any
+func guildChannelID(guild gatewayGuild, channel Channel) string {
+	if channel.ID != "" {
+		return channel.ID
+	}
+
+	var id string
+	position := -1
+	for _, ch := range guild.Channels {
+		if ch.Type != 0 { // only text channels
+			continue
+		}
+
+		if channel.Name != "" {
+			if strings.EqualFold(ch.Name, channel.Name) {
+				return ch.ID
+			}
+			continue
+		}
+
+		if position == -1 || ch.Position < position {
+			id = ch.ID
+			position = ch.Position
+		}
+	}
+
+	return id
+}
+
+// scrapGateway collects members and presences of servers supplied by user from Discord gateway, without browser.
+// Bot token is used if it's supplied, otherwise user token. If ctx is canceled, users received so far are returned.
+func scrapGateway(ctx context.Context) []User {
+	token, bot := *discordToken, false
+	if *discordBotToken != "" {
+		token, bot = *discordBotToken, true
+	}
+
+	conn, err := dialGateway(ctx, token, bot)
+	if err != nil {
+		logger.Errorf("Connecting to Discord gateway: %v", err)
+		metrics.incError("scrap")
+		return nil
+	}
+	defer conn.Close()
+
+	guilds, err := conn.waitGuilds()
+	if err != nil {
+		logger.Errorf("Receiving servers from Discord gateway: %v", err)
+		metrics.incError("scrap")
+		return nil
+	}
+	logger.Infof("Connected to Discord gateway !")
+
+	users := make([]User, 0)
+	for _, server := range servers() {
+		guild, ok := findGuild(guilds, server)
+		if !ok {
+			logger.With("server", server).Errorf("Server isn't found among servers of account")
+			metrics.incError("scrap")
+			continue
+		}
+
+		// bots receive whole roster at once, while user accounts receive member list of some channel
+		if bot {
+			usernameStatuses, err := conn.requestGuildMembers(guild, server)
+			if ctx.Err() != nil {
+				return users
+			}
+			if err != nil {
+				logger.With("server", server).Errorf("Requesting members: %v", err)
+				metrics.incError("scrap")
+				continue
+			}
+
+			users = collectUsers(users, usernameStatuses)
+			continue
+		}
+
+		for _, channel := range channels() {
+			usernameStatuses, err := conn.requestMemberList(guild, server, channel)
+			if ctx.Err() != nil {
+				return users
+			}
+			if err != nil {
+				logger.With("server", server, "channel", channel).Errorf("Requesting member list: %v", err)
+				metrics.incError("scrap")
+				continue
+			}
+
+			users = collectUsers(users, usernameStatuses)
+		}
+	}
+
+	return users
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bejaneps/discord-user-monitor/api/presencepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcSubscriberBuffer is an amount of scrapping processes, whose messages are queued for subscriber, subscriber,
+// that doesn't read them in time, is disconnected, so it doesn't slow down others
+const grpcSubscriberBuffer = 16
+
+// grpcChangeTypes are types of changes by values of ChangeType enum of api/presence.proto
+var grpcChangeTypes = map[presencepb.ChangeType]string{
+	presencepb.ChangeType_CHANGE_TYPE_STATUS:      changeStatus,
+	presencepb.ChangeType_CHANGE_TYPE_APPEARED:    changeAppeared,
+	presencepb.ChangeType_CHANGE_TYPE_DISAPPEARED: changeDisappeared,
+	presencepb.ChangeType_CHANGE_TYPE_JOINED:      changeJoined,
+	presencepb.ChangeType_CHANGE_TYPE_LEFT:        changeLeft,
+}
+
+// presenceSubscriber is a client of StreamSamples or StreamChanges with its filter and queue of messages, each item
+// of queue has messages of one scrapping process
+type presenceSubscriber struct {
+	changes  bool // subscriber gets change events instead of samples
+	servers  []string
+	users    []string
+	types    []string
+	messages chan []proto.Message
+}
+
+// matches checks if user of sample or event matches filter of subscriber, empty lists match everything
+func (s *presenceSubscriber) matches(user User, changeType string) bool {
+	if len(s.servers) > 0 && !containsString(s.servers, user.Server) {
+		return false
+	}
+	if s.changes && len(s.types) > 0 && !containsString(s.types, changeType) {
+		return false
+	}
+	if len(s.users) == 0 {
+		return true
+	}
+
+	for _, value := range s.users {
+		if isUser(user, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// presenceServer serves PresenceService of api/presence.proto with gRPC, it's a sink of users and a writer of
+// changes, so subscribers get the same samples and changes as webhook, MQTT and Redis. Subscribers get only events
+// found after they subscribed.
+type presenceServer struct {
+	presencepb.UnimplementedPresenceServiceServer
+
+	mu          sync.Mutex
+	subscribers map[*presenceSubscriber]bool
+}
+
+// newPresenceServer creates server without subscribers
+func newPresenceServer() *presenceServer {
+	return &presenceServer{subscribers: make(map[*presenceSubscriber]bool)}
+}
+
+// Write streams users of scrapping process to subscribers of samples
+func (s *presenceServer) Write(users []User) error {
+	s.broadcast(false, func(sub *presenceSubscriber) []proto.Message {
+		messages := make([]proto.Message, 0)
+		for _, user := range users {
+			if sub.matches(user, "") {
+				messages = append(messages, presenceSample(user))
+			}
+		}
+		return messages
+	})
+
+	return nil
+}
+
+// WriteChanges streams changes of users to subscribers of changes
+func (s *presenceServer) WriteChanges(events []ChangeEvent) error {
+	s.broadcast(true, func(sub *presenceSubscriber) []proto.Message {
+		messages := make([]proto.Message, 0)
+		for _, event := range events {
+			if sub.matches(User{Server: event.Server, ID: event.ID, Username: event.Username}, event.Type) {
+				messages = append(messages, presenceChangeEvent(event))
+			}
+		}
+		return messages
+	})
+
+	return nil
+}
+
+// broadcast queues messages built by build for each subscriber of samples or changes, subscribers with full queue
+// are disconnected
+func (s *presenceServer) broadcast(changes bool, build func(sub *presenceSubscriber) []proto.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sub := range s.subscribers {
+		if sub.changes != changes {
+			continue
+		}
+
+		messages := build(sub)
+		if len(messages) == 0 {
+			continue
+		}
+
+		select {
+		case sub.messages <- messages:
+		default:
+			logger.Warnf("gRPC subscriber is too slow, disconnecting it")
+			s.remove(sub)
+		}
+	}
+}
+
+// remove unsubscribes subscriber, queue is closed, so its stream is finished, s.mu has to be locked
+func (s *presenceServer) remove(sub *presenceSubscriber) {
+	if !s.subscribers[sub] {
+		return
+	}
+
+	delete(s.subscribers, sub)
+	close(sub.messages)
+}
+
+// StreamSamples streams samples of each scrapping process, that match filter, until client cancels call
+func (s *presenceServer) StreamSamples(req *presencepb.SubscribeRequest, stream presencepb.PresenceService_StreamSamplesServer) error {
+	return s.subscribe(req, false, stream)
+}
+
+// StreamChanges streams changes of users, that match filter, until client cancels call
+func (s *presenceServer) StreamChanges(req *presencepb.SubscribeRequest, stream presencepb.PresenceService_StreamChangesServer) error {
+	return s.subscribe(req, true, stream)
+}
+
+// subscribe adds subscriber with filter of request, and sends its messages to stream, until client cancels call
+func (s *presenceServer) subscribe(req *presencepb.SubscribeRequest, changes bool, stream grpc.ServerStream) error {
+	sub := &presenceSubscriber{
+		changes:  changes,
+		servers:  req.GetServers(),
+		users:    req.GetUsers(),
+		messages: make(chan []proto.Message, grpcSubscriberBuffer),
+	}
+	for _, t := range req.GetChangeTypes() {
+		changeType, ok := grpcChangeTypes[t]
+		if !ok {
+			return status.Errorf(codes.InvalidArgument, "unknown change type %s", t)
+		}
+		sub.types = append(sub.types, changeType)
+	}
+
+	s.mu.Lock()
+	s.subscribers[sub] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.remove(sub)
+		s.mu.Unlock()
+	}()
+
+	// headers are sent at once, so client knows, that stream is open, as there are long intervals between
+	// scrapping processes
+	err := stream.SendHeader(nil)
+	if err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	method, _ := grpc.Method(ctx)
+	client := "unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		client = p.Addr.String()
+	}
+	logger.Infof("gRPC client %s subscribed to %s", client, method)
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Infof("gRPC client %s unsubscribed from %s", client, method)
+			return ctx.Err()
+		case messages, ok := <-sub.messages:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "subscriber didn't read messages in time")
+			}
+
+			for _, message := range messages {
+				err = stream.SendMsg(message)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// servePresence serves PresenceService with gRPC without TLS, so it's meant for internal networks
+func servePresence(addr string, s *presenceServer) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.Errorf("Serving gRPC: %v", err)
+		return
+	}
+
+	server := grpc.NewServer()
+	presencepb.RegisterPresenceServiceServer(server, s)
+
+	logger.Infof("Serving gRPC PresenceService on %s", addr)
+	err = server.Serve(listener)
+	if err != nil {
+		logger.Errorf("Serving gRPC: %v", err)
+	}
+}
+
+// presenceSample converts user into PresenceSample message
+func presenceSample(user User) *presencepb.PresenceSample {
+	return &presencepb.PresenceSample{
+		Server:         user.Server,
+		Channel:        user.Channel,
+		Id:             user.ID,
+		Username:       user.Username,
+		Nickname:       user.Nickname,
+		GlobalUsername: user.GlobalUsername,
+		Status:         user.Status,
+		Type:           user.Type,
+		RoleGroup:      user.RoleGroup,
+		CustomStatus:   user.CustomStatus,
+		Activity:       user.Activity,
+		AvatarUrl:      user.AvatarURL,
+		JoinedAt:       user.JoinedAt,
+		StatusTime:     protoTimestamp(user.StatusTime.Time),
+		PreviousStatus: user.PreviousStatus,
+	}
+}
+
+// presenceChangeEvent converts event into ChangeEvent message
+func presenceChangeEvent(event ChangeEvent) *presencepb.ChangeEvent {
+	message := &presencepb.ChangeEvent{
+		Server:   event.Server,
+		Channel:  event.Channel,
+		Id:       event.ID,
+		Username: event.Username,
+		From:     event.From,
+		To:       event.To,
+		Time:     protoTimestamp(event.Time),
+	}
+	for t, changeType := range grpcChangeTypes {
+		if changeType == event.Type {
+			message.Type = t
+		}
+	}
+
+	return message
+}
+
+// protoTimestamp converts time into google.protobuf.Timestamp, zero time isn't set
+func protoTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+
+	return timestamppb.New(t)
+}
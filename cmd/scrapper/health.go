@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// healthState keeps outcome of last scrapping process and amount of online members, they are served as json, so
+// orchestration and uptime monitors can supervise tool
+type healthState struct {
+	mu sync.Mutex
+
+	started     time.Time
+	lastCycle   time.Time // time when last scrapping process finished
+	lastSuccess time.Time // time when last scrapping process without errors finished
+	lastErrors  int       // amount of errors of last scrapping process
+	cycles      int
+
+	members map[string]int // server -> amount of members
+	online  map[string]int // server -> amount of members, that aren't offline
+}
+
+// health is a state used by whole tool
+var health = &healthState{started: time.Now()}
+
+// Write replaces members counts of previous scrapping process, so state can be used as a sink
+func (h *healthState) Write(users []User) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.members = make(map[string]int)
+	h.online = make(map[string]int)
+	for _, user := range serverMembers(users) {
+		h.members[user.Server]++
+		if user.Status != "Offline" {
+			h.online[user.Server]++
+		}
+	}
+
+	return nil
+}
+
+// observeCycle records outcome of finished scrapping process, it failed if it had errors
+func (h *healthState) observeCycle(errors int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastCycle = time.Now()
+	h.lastErrors = errors
+	if errors == 0 {
+		h.lastSuccess = h.lastCycle
+	}
+	h.cycles++
+}
+
+// maxCycleAge is time after which tool is unhealthy, if scrapping process isn't finished during it, it's enough for
+// several intervals, and some time is added, as scrapping itself takes time too
+func maxCycleAge() time.Duration {
+	return 3*(*scrappingInterval+*intervalJitter) + 10*time.Minute
+}
+
+// serveHealthz responds with outcome of last scrapping process, status code is 503 if it failed, or scrapping is
+// stuck, so monitors can check only status code
+func (h *healthState) serveHealthz(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// first scrapping process is measured from start of tool
+	since := h.started
+	if h.cycles > 0 {
+		since = h.lastCycle
+	}
+	age := time.Since(since)
+
+	status, code := "ok", http.StatusOK
+	switch {
+	case scrapPause.paused():
+		status = "paused"
+	case age > maxCycleAge():
+		status, code = "stuck", http.StatusServiceUnavailable
+	case h.cycles == 0:
+		status = "starting"
+	case h.lastErrors > 0:
+		status, code = "failing", http.StatusServiceUnavailable
+	}
+
+	resp := struct {
+		Status                string     `json:"status"`
+		Cycles                int        `json:"cycles"`
+		LastCycle             *time.Time `json:"last_cycle"`
+		LastSuccess           *time.Time `json:"last_success"`
+		LastCycleErrors       int        `json:"last_cycle_errors"`
+		SecondsSinceLastCycle float64    `json:"seconds_since_last_cycle"`
+	}{
+		Status:                status,
+		Cycles:                h.cycles,
+		LastCycle:             optionalTime(h.lastCycle),
+		LastSuccess:           optionalTime(h.lastSuccess),
+		LastCycleErrors:       h.lastErrors,
+		SecondsSinceLastCycle: age.Seconds(),
+	}
+
+	writeJSON(w, code, resp)
+}
+
+// serveStatus responds with amount of members, and online ones, scrapped in last scrapping process
+func (h *healthState) serveStatus(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	type serverStatus struct {
+		Members       int `json:"members"`
+		MembersOnline int `json:"members_online"`
+	}
+
+	resp := struct {
+		Members       int                     `json:"members"`
+		MembersOnline int                     `json:"members_online"`
+		Servers       map[string]serverStatus `json:"servers"`
+		LastCycle     *time.Time              `json:"last_cycle"`
+	}{
+		Servers:   make(map[string]serverStatus),
+		LastCycle: optionalTime(h.lastCycle),
+	}
+	for server, members := range h.members {
+		resp.Members += members
+		resp.MembersOnline += h.online[server]
+		resp.Servers[server] = serverStatus{Members: members, MembersOnline: h.online[server]}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// optionalTime returns nil for zero time, so it's encoded as null
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+// writeJSON writes v as json response with status code
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// serveHealth serves health on /healthz path of addr, and members counts on /status path
+func serveHealth(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", health.serveHealthz)
+	mux.HandleFunc("/status", health.serveStatus)
+
+	logger.Infof("Serving health on %s/healthz and %s/status", addr, addr)
+	err := http.ListenAndServe(addr, mux)
+	if err != nil {
+		logger.Errorf("Serving health: %v", err)
+	}
+}
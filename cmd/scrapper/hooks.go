@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// hookEnvPrefix is a prefix of environment variables of hooks, it differs from prefix of flags, so scrapper run by
+// hook doesn't read them as flags
+const hookEnvPrefix = "HOOK_"
+
+// runHook runs command of hook with shell, event is written to its stdin as json, and env is added to its
+// environment with HOOK_ prefix, besides HOOK_EVENT with name of event and HOOK_JSON with event. Output of command is
+// logged, and it's killed after --hook-timeout, so scrapping isn't blocked by it. Errors are logged, so scrapping
+// isn't stopped by them, and they aren't counted as errors of scrapping process, so --on-error hook isn't run for
+// them.
+func runHook(command, event string, payload interface{}, env map[string]string) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.Errorf("Couldn't encode event of %s hook: %v", event, err)
+		return
+	}
+
+	cmd := hookCommand(command)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(), hookEnvPrefix+"EVENT="+event, hookEnvPrefix+"JSON="+string(body))
+	for key, value := range env {
+		cmd.Env = append(cmd.Env, hookEnvPrefix+key+"="+value)
+	}
+
+	hookLogger := logger.With("hook", event)
+	cmd.Stdout = hookLogger.Writer()
+	cmd.Stderr = hookLogger.Writer()
+
+	err = cmd.Start()
+	if err != nil {
+		hookLogger.Errorf("Couldn't run %s hook: %v", event, err)
+		return
+	}
+
+	// timer, that can't be stopped anymore, killed hook
+	timer := time.AfterFunc(*hookTimeout, func() {
+		killHook(cmd)
+	})
+	err = cmd.Wait()
+
+	if !timer.Stop() {
+		hookLogger.Errorf("Couldn't run %s hook: it didn't finish in %s", event, *hookTimeout)
+	} else if err != nil {
+		hookLogger.Errorf("Couldn't run %s hook: %v", event, err)
+	}
+}
+
+// changeHook runs --on-change command for each change event
+type changeHook struct {
+	command string
+}
+
+func (h changeHook) WriteChanges(events []ChangeEvent) error {
+	for _, event := range events {
+		runHook(h.command, "change", event, map[string]string{
+			"TYPE":     event.Type,
+			"SERVER":   event.Server,
+			"CHANNEL":  event.Channel,
+			"USER_ID":  event.ID,
+			"USERNAME": event.Username,
+			"FROM":     event.From,
+			"TO":       event.To,
+			"TIME":     event.Time.UTC().Format(time.RFC3339),
+		})
+	}
+
+	return nil
+}
+
+// runErrorHook runs --on-error command for notification about failure, eg: captcha or errors of scrapping process
+func runErrorHook(n Notification) {
+	runHook(*onError, "error", n, map[string]string{
+		"TYPE":    n.Event,
+		"MESSAGE": n.Message,
+		"TIME":    n.Time.UTC().Format(time.RFC3339),
+	})
+}
+
+// cycleEvent is an event of finished scrapping process, passed to --on-cycle-complete hook
+type cycleEvent struct {
+	Cycle    int            `json:"cycle"`
+	Users    int            `json:"users"`
+	Online   int            `json:"online"`
+	Changes  map[string]int `json:"changes"`
+	Errors   int            `json:"errors"`
+	Duration float64        `json:"duration"` // seconds
+	Time     time.Time      `json:"time"`
+}
+
+// runCycleHook runs --on-cycle-complete command with outcome of scrapping process
+func runCycleHook(s cycleSummary) {
+	event := cycleEvent{
+		Cycle:    s.Cycle,
+		Users:    len(s.Users),
+		Changes:  make(map[string]int),
+		Errors:   s.Errors,
+		Duration: s.Duration.Seconds(),
+		Time:     time.Now().UTC(),
+	}
+	for _, user := range s.Users {
+		if user.Status != "Offline" {
+			event.Online++
+		}
+	}
+	for t, count := range s.Changes {
+		event.Changes[t] = count
+	}
+
+	runHook(*onCycleComplete, "cycle_complete", event, map[string]string{
+		"CYCLE":    strconv.Itoa(event.Cycle),
+		"USERS":    strconv.Itoa(event.Users),
+		"ONLINE":   strconv.Itoa(event.Online),
+		"ERRORS":   strconv.Itoa(event.Errors),
+		"DURATION": fmt.Sprintf("%.0f", event.Duration),
+		"TIME":     event.Time.Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,13 @@
+package main
+
+import "os/exec"
+
+// hookCommand creates command of hook run with cmd
+func hookCommand(command string) *exec.Cmd {
+	return exec.Command("cmd", "/C", command)
+}
+
+// killHook kills process of hook, programs started by it keep running, as Windows doesn't have process groups
+func killHook(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
@@ -0,0 +1,58 @@
+package main
+
+import "context"
+
+// mergeRoster fetches full roster of servers from Discord API and enriches it with live presences scrapped from
+// member list, members are merged on user ID. Scrapped users, whose ID isn't found in roster, are kept as they are,
+// as well as all scrapped users, if ctx is canceled.
+func mergeRoster(ctx context.Context, scrapped []User) []User {
+	api := newDiscordAPI(ctx, *discordBotToken)
+
+	byServer := make(map[string][]User)
+	for _, user := range scrapped {
+		byServer[user.Server] = append(byServer[user.Server], user)
+	}
+
+	users := make([]User, 0, len(scrapped))
+	for _, server := range servers() {
+		logger.With("server", server).Infof("Fetching roster from Discord API...")
+		roster, _, _, err := fetchRoster(api, server)
+		if ctx.Err() != nil {
+			users = append(users, byServer[server.String()]...)
+			continue
+		}
+		if err != nil {
+			logger.With("server", server).Warnf("Fetching roster, only scrapped users are written: %v", err)
+			metrics.incError("scrap")
+			users = append(users, byServer[server.String()]...)
+			continue
+		}
+
+		merged := 0
+		for _, user := range byServer[server.String()] {
+			member, ok := roster[user.ID]
+			if user.ID == "" || !ok {
+				users = append(users, user)
+				continue
+			}
+
+			// roster contains identity of member, and member list contains its presence
+			member.Channel = user.Channel
+			member.Status = user.Status
+			member.RoleGroup = user.RoleGroup
+			member.CustomStatus = user.CustomStatus
+			member.Activity = user.Activity
+			member.StatusTime = user.StatusTime
+			if member.AvatarURL == "" {
+				member.AvatarURL = user.AvatarURL
+			}
+			roster[user.ID] = member
+			merged++
+		}
+		logger.With("server", server, "users", len(roster)).Infof("Merged presences of %d users into roster of %d members", merged, len(roster))
+
+		users = collectUsers(users, roster)
+	}
+
+	return users
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readUsers reads users stored by tool in output file (csv, json or ndjson, compressed with gzip or not) or in
+// SQLite database, so they can be exported and analyzed. Format of file is detected by its extension.
+func readUsers(path string) ([]User, error) {
+	if isSQLiteOutput(path) {
+		return readSQLiteUsers(strings.TrimPrefix(path, sqliteOutputPrefix))
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening input file: %w", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	name := path
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing input file: %w", err)
+		}
+		defer gz.Close()
+
+		r = gz
+		name = strings.TrimSuffix(name, ".gz")
+	}
+
+	switch filepath.Ext(name) {
+	case ".csv":
+		return readCSVUsers(r)
+	case ".json", ".ndjson":
+		return readJSONUsers(r)
+	default:
+		return nil, fmt.Errorf("users can't be read from %s file, only csv, json, ndjson and sqlite are supported", filepath.Ext(name))
+	}
+}
+
+// readCSVUsers reads users from csv rows, columns are found by header, which is repeated, if columns of output
+// were changed between runs
+func readCSVUsers(r io.Reader) ([]User, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	users := make([]User, 0)
+	var header []string
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return users, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading csv row: %w", err)
+		}
+
+		if header == nil || isHeaderRow(row) {
+			header = row
+			continue
+		}
+
+		var user User
+		for i, value := range row {
+			if i < len(header) {
+				setUserValue(&user, header[i], value)
+			}
+		}
+		users = append(users, user)
+	}
+}
+
+// isHeaderRow checks if all values of csv row are names of columns
+func isHeaderRow(row []string) bool {
+	for _, value := range row {
+		if !containsString(userColumns, value) {
+			return false
+		}
+	}
+
+	return len(row) > 0
+}
+
+// readJSONUsers reads users from json output, which has array of users on each line, and from ndjson output,
+// which has user on each line
+func readJSONUsers(r io.Reader) ([]User, error) {
+	users := make([]User, 0)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 256*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var records []map[string]string
+		if strings.HasPrefix(line, "[") {
+			err := json.Unmarshal([]byte(line), &records)
+			if err != nil {
+				return nil, fmt.Errorf("decoding json line: %w", err)
+			}
+		} else {
+			var record map[string]string
+			err := json.Unmarshal([]byte(line), &record)
+			if err != nil {
+				return nil, fmt.Errorf("decoding json line: %w", err)
+			}
+			records = append(records, record)
+		}
+
+		for _, record := range records {
+			var user User
+			for column, value := range record {
+				setUserValue(&user, column, value)
+			}
+			users = append(users, user)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading json lines: %w", err)
+	}
+
+	return users, nil
+}
+
+// readSQLiteUsers reads presence samples with their users from SQLite database, in order they were sampled
+func readSQLiteUsers(path string) ([]User, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("opening sqlite database: %w", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(sqliteSamplesQuery + ` ORDER BY s.sampled_at`)
+	if err != nil {
+		return nil, fmt.Errorf("querying presence samples: %w", err)
+	}
+	defer rows.Close()
+
+	return scanStoredUsers(rows)
+}
+
+// sqliteSamplesQuery selects presence samples with their users, in order of columns of scanStoredUsers
+const sqliteSamplesQuery = `SELECT s.server, s.channel, s.user_id, u.username, u.nickname, u.global_username, s.status, u.type,
+	s.role_group, s.custom_status, s.activity, u.avatar_url, u.joined_at, s.sampled_at
+FROM presence_samples s JOIN users u ON u.server = s.server AND u.id = s.user_id`
+
+// scanStoredUsers reads users from rows of sqliteSamplesQuery, or the same query of PostgreSQL database, time of
+// sample is read as text
+func scanStoredUsers(rows *sql.Rows) ([]User, error) {
+	users := make([]User, 0)
+	for rows.Next() {
+		var user User
+		var id, sampledAt string
+		err := rows.Scan(&user.Server, &user.Channel, &id, &user.Username, &user.Nickname, &user.GlobalUsername, &user.Status, &user.Type,
+			&user.RoleGroup, &user.CustomStatus, &user.Activity, &user.AvatarURL, &user.JoinedAt, &sampledAt)
+		if err != nil {
+			return nil, fmt.Errorf("reading presence sample: %w", err)
+		}
+
+		// user without ID is stored by username
+		if id != user.Username {
+			user.ID = id
+		}
+		setUserValue(&user, "status_time", sampledAt)
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading presence samples: %w", err)
+	}
+
+	return users, nil
+}
+
+// setUserValue sets user's column to value, it's opposite of userValue. Time is parsed in any format, that was
+// written by tool, see parseStatusTime.
+func setUserValue(user *User, column, value string) {
+	switch column {
+	case "server":
+		user.Server = value
+	case "channel":
+		user.Channel = value
+	case "id":
+		user.ID = value
+	case "username":
+		user.Username = value
+	case "nickname":
+		user.Nickname = value
+	case "global_username":
+		user.GlobalUsername = value
+	case "status":
+		user.Status = value
+	case "type":
+		user.Type = value
+	case "role_group":
+		user.RoleGroup = value
+	case "custom_status":
+		user.CustomStatus = value
+	case "activity":
+		user.Activity = value
+	case "avatar_url":
+		user.AvatarURL = value
+	case "joined_at":
+		user.JoinedAt = value
+	case "status_time":
+		t, _ := parseStatusTime(value)
+		user.StatusTime = Time{t}
+	case "previous_status":
+		user.PreviousStatus = value
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import "fmt"
+
+const (
+	discordLoginPath = "/login"
+	discordAppPath   = "/channels/@me"
+)
+
+// discordInstances maps Discord web client instances to their hosts
+var discordInstances = map[string]string{
+	"stable": "discord.com",
+	"ptb":    "ptb.discord.com",
+	"canary": "canary.discord.com",
+}
+
+// discordPage returns full URL of page with path on Discord instance specified by user
+func discordPage(path string) string {
+	return fmt.Sprintf("https://%s%s", discordInstances[*discordInstance], path)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// discordPlace is a server or channel visible to account
+type discordPlace struct {
+	ID   string
+	Name string
+}
+
+// gatewayTextChannelTypes are types of channels, that have member list: text and announcement ones
+var gatewayTextChannelTypes = map[int]bool{0: true, 5: true}
+
+// listServersCommand prints IDs and names of servers of account, so user can find value of --d-server-id
+func listServersCommand(args []string) int {
+	return listCommand(args, false)
+}
+
+// listChannelsCommand prints IDs and names of text channels of server supplied with --d-server-id or
+// --d-server-name, so user can find value of --d-channel-id
+func listChannelsCommand(args []string) int {
+	return listCommand(args, true)
+}
+
+// listCommand logs in with the same flags as scrape subcommand, and prints servers of account, or channels of its
+// server. Gateway is used, if token is supplied, as it's faster, otherwise browser.
+func listCommand(args []string, listChannels bool) int {
+	os.Args = append([]string{os.Args[0]}, args...)
+	parseFlags()
+
+	level, err := parseLogLevel(*logLevelName)
+	if err != nil {
+		log.Printf("Couldn't parse log level: %v\n", err)
+		return 1
+	}
+	logger = newLogger(os.Stderr, level, *logFormat == "json")
+
+	if listChannels && len(servers()) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: scrapper list-channels --d-server-id <id> | --d-server-name <name> [flags]")
+		return 1
+	}
+
+	var places []discordPlace
+	if *discordToken != "" || *discordBotToken != "" {
+		places, err = listGatewayPlaces(listChannels)
+	} else {
+		places, err = listBrowserPlaces(listChannels)
+	}
+	if err != nil {
+		logger.Errorf("Couldn't list servers or channels: %v", err)
+		return 1
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME")
+	for _, place := range places {
+		fmt.Fprintf(tw, "%s\t%s\n", place.ID, place.Name)
+	}
+	tw.Flush()
+
+	return 0
+}
+
+// listGatewayPlaces receives servers of account from Discord gateway, and channels of server supplied by user
+func listGatewayPlaces(listChannels bool) ([]discordPlace, error) {
+	token, bot := *discordToken, false
+	if *discordBotToken != "" {
+		token, bot = *discordBotToken, true
+	}
+
+	conn, err := dialGateway(context.Background(), token, bot)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	guilds, err := conn.waitGuilds()
+	if err != nil {
+		return nil, err
+	}
+
+	places := make([]discordPlace, 0)
+	if !listChannels {
+		for _, guild := range guilds {
+			places = append(places, discordPlace{ID: guild.ID, Name: guild.name()})
+		}
+		return places, nil
+	}
+
+	server := servers()[0]
+	guild, ok := findGuild(guilds, server)
+	if !ok {
+		return nil, fmt.Errorf("server %s isn't found among servers of account", server)
+	}
+
+	for _, channel := range guild.Channels {
+		if gatewayTextChannelTypes[channel.Type] {
+			places = append(places, discordPlace{ID: channel.ID, Name: channel.Name})
+		}
+	}
+
+	return places, nil
+}
+
+// listBrowserPlaces logs in browser, and collects server links from left server bar, or channel links of server
+// supplied by user. Servers in collapsed folders aren't shown by Discord, so they aren't listed.
+func listBrowserPlaces(listChannels bool) ([]discordPlace, error) {
+	if *webdriver != "" && *backend == "selenium" {
+		var err error
+		webdriverPath, err = resolveWebdriver(*webdriver)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	driver := prepareDriver(context.Background(), nil, 0)
+	if driver == nil {
+		return nil, fmt.Errorf("browser can't be started or logged in")
+	}
+	defer driver.Close()
+
+	chain, prefix := selectors.ServerLinks, "guildsnav___"
+	if listChannels {
+		err := openServer(driver, servers()[0])
+		if err != nil {
+			return nil, err
+		}
+		chain, prefix = selectors.ChannelLinks, "channels___"
+	}
+
+	links, err := findElements(driver, chain)
+	if err != nil {
+		return nil, fmt.Errorf("finding links: %w", err)
+	}
+
+	places := make([]discordPlace, 0, len(links))
+	for _, link := range links {
+		id, err := link.GetAttribute("data-list-item-id")
+		if err != nil {
+			continue
+		}
+		name, err := link.GetAttribute("aria-label")
+		if err != nil {
+			continue
+		}
+
+		// other items of server bar (home, add server and etc) don't have numeric IDs
+		id = strings.TrimPrefix(id, prefix)
+		if strings.Trim(id, "0123456789") != "" {
+			continue
+		}
+
+		// aria-label of channel looks like: 'general (text channel)'
+		if i := strings.LastIndex(name, " ("); listChannels && i > 0 {
+			name = name[:i]
+		}
+
+		places = append(places, discordPlace{ID: id, Name: strings.TrimSpace(name)})
+	}
+
+	return places, nil
+}
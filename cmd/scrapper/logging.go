@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logLevel is a severity of log message, messages below level supplied with --log-level aren't written
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// logLevelNames are names of levels, as they are supplied by user and written to log
+var logLevelNames = map[logLevel]string{
+	levelDebug: "debug",
+	levelInfo:  "info",
+	levelWarn:  "warn",
+	levelError: "error",
+}
+
+// parseLogLevel returns level by its name
+func parseLogLevel(name string) (logLevel, error) {
+	for level, levelName := range logLevelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+
+	return levelInfo, fmt.Errorf("unknown log level %s, it has to be debug, info, warn or error", name)
+}
+
+// logOutput is shared by logger and its children with fields, so lines of different goroutines aren't mixed
+type logOutput struct {
+	mu         sync.Mutex
+	w          io.Writer
+	level      logLevel
+	jsonFormat bool
+}
+
+// logField is a key and value added to each message of logger, eg: server=123 or cycle=5
+type logField struct {
+	key   string
+	value interface{}
+}
+
+// Logger writes leveled messages with fields, either as text lines or as json objects, so logs can be shipped to
+// Loki or ELK and filtered by server or scrapping process
+type Logger struct {
+	out    *logOutput
+	fields []logField
+}
+
+// newLogger creates logger writing messages of level and above to w, in json format or as text lines
+func newLogger(w io.Writer, level logLevel, jsonFormat bool) *Logger {
+	return &Logger{out: &logOutput{w: w, level: level, jsonFormat: jsonFormat}}
+}
+
+// With returns logger, which adds fields to each message, fields are supplied as key and value pairs, eg:
+// logger.With("server", server, "users", len(users))
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	fields := make([]logField, len(l.fields), len(l.fields)+len(keyvals)/2)
+	copy(fields, l.fields)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		fields = append(fields, logField{key: fmt.Sprint(keyvals[i]), value: keyvals[i+1]})
+	}
+
+	return &Logger{out: l.out, fields: fields}
+}
+
+// Debugf writes details, that are needed only to find out why tool doesn't work as expected
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.log(levelDebug, format, args...)
+}
+
+// Infof writes progress of tool
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.log(levelInfo, format, args...)
+}
+
+// Warnf writes problems, that tool recovers from, like retries or incomplete member lists
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.log(levelWarn, format, args...)
+}
+
+// Errorf writes failures, that lose data of scrapping process or stop tool
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.log(levelError, format, args...)
+}
+
+// Writer returns writer, whose each line is written as info message, it's used for output of other programs
+func (l *Logger) Writer() io.Writer {
+	return &logLineWriter{logger: l}
+}
+
+// log formats message and writes it with fields in format of logger
+func (l *Logger) log(level logLevel, format string, args ...interface{}) {
+	if level < l.out.level {
+		return
+	}
+
+	now := time.Now()
+	msg := strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")
+
+	var buf bytes.Buffer
+	if l.out.jsonFormat {
+		buf.WriteString(`{"time":`)
+		writeJSONValue(&buf, now.Format(time.RFC3339Nano))
+		buf.WriteString(`,"level":`)
+		writeJSONValue(&buf, logLevelNames[level])
+		buf.WriteString(`,"msg":`)
+		writeJSONValue(&buf, msg)
+		for _, field := range l.fields {
+			buf.WriteByte(',')
+			writeJSONValue(&buf, field.key)
+			buf.WriteByte(':')
+			writeJSONValue(&buf, field.value)
+		}
+		buf.WriteString("}\n")
+	} else {
+		// text lines look like lines of standard logger, so old logs and new ones are read the same way
+		fmt.Fprintf(&buf, "%s %-5s %s", now.Format("2006/01/02 15:04:05"), strings.ToUpper(logLevelNames[level]), msg)
+		for _, field := range l.fields {
+			fmt.Fprintf(&buf, " %s=%s", field.key, quoteLogValue(fmt.Sprint(field.value)))
+		}
+		buf.WriteByte('\n')
+	}
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.w.Write(buf.Bytes())
+}
+
+// writeJSONValue writes value in json format, values, that can't be encoded, are written as strings
+func writeJSONValue(buf *bytes.Buffer, value interface{}) {
+	// servers and channels are written as they are shown in text lines, not as their structs
+	switch v := value.(type) {
+	case fmt.Stringer:
+		value = v.String()
+	case error:
+		value = v.Error()
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(data)
+}
+
+// quoteLogValue quotes value of text line, if it has spaces or quotes, so fields can be parsed back
+func quoteLogValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// logLineWriter writes each complete line as info message
+type logLineWriter struct {
+	logger *Logger
+	buf    []byte
+	mu     sync.Mutex
+}
+
+func (w *logLineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := strings.TrimRight(string(w.buf[:i]), "\r")
+		if line != "" {
+			w.logger.Infof("%s", line)
+		}
+		w.buf = w.buf[i+1:]
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const loginRetryBackoff = 5 * time.Second // first delay between login retries, it doubles after each retry
+
+// loginWithRetry performs login and retries it with exponential backoff if it fails, as slow page loads
+// are a common reason of failed login, it isn't retried when tool is stopped, and backoff is interrupted then
+func loginWithRetry(ctx context.Context, driver Driver) error {
+	backoff := loginRetryBackoff
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = login(driver)
+		if err == nil || attempt >= *loginMaxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		logger.Warnf("Logging in: %v, retrying in %s", err, backoff)
+		if !sleepContext(ctx, backoff) {
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// login authenticates in Discord either with a token, if it was supplied, or with email and password
+func login(driver Driver) error {
+	// browser profile may already contain logged in session from previous run
+	if *browserProfileDir != "" {
+		loggedIn, err := isLoggedIn(driver)
+		if err != nil {
+			return err
+		}
+
+		if loggedIn {
+			return nil
+		}
+	}
+
+	if *discordToken != "" {
+		return loginWithToken(driver)
+	}
+
+	if *discordEmail == "" || *discordPassword == "" {
+		return errors.New("browser profile doesn't contain Discord session, and no credentials were supplied")
+	}
+
+	return loginWithCredentials(driver)
+}
+
+// isLoggedIn opens Discord app and checks if it stays there, Discord redirects to login page if session is absent or expired
+func isLoggedIn(driver Driver) (bool, error) {
+	err := driver.Get(discordPage(discordAppPath))
+	if err != nil {
+		return false, fmt.Errorf("navigating to Discord app page: %w", err)
+	}
+
+	time.Sleep(*discordLoadTime)
+
+	currentURL, err := driver.CurrentURL()
+	if err != nil {
+		return false, fmt.Errorf("getting current url: %w", err)
+	}
+
+	return !strings.Contains(currentURL, discordLoginPath), nil
+}
+
+// reloadSession opens Discord app again in browser of previous scrapping process, so member lists are scrolled to
+// top, and logs in again if session has expired since then
+func reloadSession(ctx context.Context, driver Driver) error {
+	loggedIn, err := isLoggedIn(driver)
+	if err != nil {
+		return err
+	}
+
+	if loggedIn {
+		return nil
+	}
+
+	logger.Warnf("Session has expired, logging in again")
+
+	err = loginWithRetry(ctx, driver)
+	if err != nil {
+		return fmt.Errorf("logging in again: %w", err)
+	}
+	time.Sleep(*discordLoadTime) // wait for page to load
+
+	return nil
+}
+
+// prepareDriver returns driver of worker with logged in Discord session, driver of previous scrapping process is
+// reused, so browser isn't started and logged in each time. If its session is lost, it's closed and new one is
+// created. If browser can't be started or login fails, nil is returned, and browser is started again in next
+// scrapping process.
+func prepareDriver(ctx context.Context, driver Driver, worker int) Driver {
+	if driver != nil {
+		err := reloadSession(ctx, driver)
+		if err == nil {
+			return driver
+		}
+
+		logger.Warnf("Reusing browser session: %v, starting new browser", err)
+		driver.Close()
+	}
+
+	// create new selenium web driver
+	driver, err := newDriver(ctx, worker)
+	if ctx.Err() != nil {
+		if driver != nil {
+			driver.Close()
+		}
+		return nil
+	}
+	if err != nil {
+		logger.Errorf("Creating new browser session: %v", err)
+		metrics.incError("browser")
+		return nil
+	}
+
+	// perform login
+	err = loginWithRetry(ctx, driver)
+	if ctx.Err() != nil {
+		driver.Close()
+		return nil
+	}
+	if err != nil {
+		logger.Errorf("Logging in: %v", err)
+		metrics.incError("login")
+
+		driver.Close()
+		return nil
+	}
+
+	logger.Infof("Logged in successfully !")
+	time.Sleep(*discordLoadTime) // wait for page to load
+
+	return driver
+}
+
+// ensureSession checks if Discord redirected to login page because session has expired, if so, then it logs in
+// again and opens member list, so scrapping can be resumed
+func ensureSession(ctx context.Context, driver Driver, server Server, channel Channel) error {
+	currentURL, err := driver.CurrentURL()
+	if err != nil {
+		return fmt.Errorf("getting current url: %w", err)
+	}
+
+	if !strings.Contains(currentURL, discordLoginPath) {
+		return nil
+	}
+
+	logger.Warnf("Session has expired, logging in again")
+
+	err = loginWithRetry(ctx, driver)
+	if err != nil {
+		return fmt.Errorf("logging in again: %w", err)
+	}
+	time.Sleep(*discordLoadTime) // wait for page to load
+
+	err = openMemberList(driver, server, channel)
+	if err != nil {
+		return fmt.Errorf("opening member list again: %w", err)
+	}
+
+	logger.Infof("Logged in again, resuming scrapping")
+
+	return nil
+}
+
+// loginWithToken injects Discord auth token into localStorage and then opens Discord app, so login form is skipped
+func loginWithToken(driver Driver) error {
+	// localStorage is only accessible on Discord's origin, so login page has to be opened first
+	err := driver.Get(discordPage(discordLoginPath))
+	if err != nil {
+		return fmt.Errorf("navigating to Discord login page: %w", err)
+	}
+
+	// Discord removes window.localStorage once app is loaded, so token is written using a fresh iframe's one
+	script := `var frame = document.createElement("iframe");
+document.body.appendChild(frame);
+frame.contentWindow.localStorage.setItem("token", JSON.stringify(arguments[0]));
+frame.remove();`
+	_, err = driver.ExecuteScript(script, []interface{}{*discordToken})
+	if err != nil {
+		return fmt.Errorf("injecting token: %w", err)
+	}
+
+	err = driver.Get(discordPage(discordAppPath))
+	if err != nil {
+		return fmt.Errorf("navigating to Discord app page: %w", err)
+	}
+
+	return nil
+}
+
+// loginWithCredentials fills Discord login form with email and password, and submits it
+func loginWithCredentials(driver Driver) error {
+	// navigate to discord login page
+	err := driver.Get(discordPage(discordLoginPath))
+	if err != nil {
+		return fmt.Errorf("navigating to Discord login page: %w", err)
+	}
+
+	time.Sleep(*discordLoadTime)
+
+	// fill email field
+	emailField, err := findElement(driver, selectors.EmailField)
+	if err != nil {
+		return fmt.Errorf("finding email field: %w", err)
+	}
+
+	err = emailField.SendKeys(*discordEmail)
+	if err != nil {
+		return fmt.Errorf("filling email field: %w", err)
+	}
+
+	// fill password field
+	passwordField, err := findElement(driver, selectors.PasswordField)
+	if err != nil {
+		return fmt.Errorf("finding password field: %w", err)
+	}
+
+	err = passwordField.SendKeys(*discordPassword)
+	if err != nil {
+		return fmt.Errorf("filling password field: %w", err)
+	}
+
+	// click submit button
+	submitBtn, err := findElement(driver, selectors.SubmitButton)
+	if err != nil {
+		return fmt.Errorf("finding submit button: %w", err)
+	}
+
+	err = submitBtn.Click()
+	if err != nil {
+		return fmt.Errorf("clicking submit button: %w", err)
+	}
+
+	err = handleCaptcha(driver)
+	if err != nil {
+		return err
+	}
+
+	if *discordTwoFactor || *discordTOTPSecret != "" {
+		err = handleTwoFactor(driver)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
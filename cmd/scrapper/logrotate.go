@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is a format of time in names of rotated log files, eg: scrapper-2006-01-02T15-04-05.000.log
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// rotatingFile is a log file, that is rotated, when it grows bigger than maximum size, or it's older than rotation
+// interval. Rotated files are renamed with time of rotation, and removed when they are older than maximum age, or
+// there are more of them than maximum amount of backups.
+type rotatingFile struct {
+	mu   sync.Mutex
+	path string
+
+	maxSize    int64
+	interval   time.Duration
+	maxAge     time.Duration
+	maxBackups int
+
+	file    *os.File
+	size    int64
+	created time.Time
+}
+
+// openRotatingFile opens log file for appending, so logs of previous runs are kept, and removes old backups
+func openRotatingFile(path string, maxSize int64, interval, maxAge time.Duration, maxBackups int) (*rotatingFile, error) {
+	f := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		interval:   interval,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+	}
+
+	err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	f.removeBackups()
+
+	return f, nil
+}
+
+// open opens log file, size of existing file is kept, so it's rotated by size after restart as well
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("getting info of log file: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.created = time.Now()
+
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.needsRotation(int64(len(p))) {
+		// log isn't lost, if file can't be rotated, it's written to current file
+		err := f.rotate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Couldn't rotate log file: %v\n", err)
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+
+	return n, err
+}
+
+// needsRotation checks if file is too big for next write, or it's too old
+func (f *rotatingFile) needsRotation(size int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.maxSize > 0 && f.size+size > f.maxSize {
+		return true
+	}
+
+	return f.interval > 0 && time.Since(f.created) >= f.interval
+}
+
+// rotate renames current file to backup, opens new file and removes old backups
+func (f *rotatingFile) rotate() error {
+	// file is closed before renaming, as opened files can't be renamed on Windows
+	err := f.file.Close()
+	if err != nil {
+		return fmt.Errorf("closing log file: %w", err)
+	}
+
+	ext := filepath.Ext(f.path)
+	backup := strings.TrimSuffix(f.path, ext) + "-" + time.Now().Format(backupTimeFormat) + ext
+	renameErr := os.Rename(f.path, backup)
+
+	err = f.open()
+	if err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return fmt.Errorf("renaming log file: %w", renameErr)
+	}
+
+	f.removeBackups()
+	return nil
+}
+
+// removeBackups removes backups, that are older than maximum age, or exceed maximum amount of backups
+func (f *rotatingFile) removeBackups() {
+	if f.maxAge <= 0 && f.maxBackups <= 0 {
+		return
+	}
+
+	ext := filepath.Ext(f.path)
+	prefix := filepath.Base(strings.TrimSuffix(f.path, ext)) + "-"
+	entries, err := ioutil.ReadDir(filepath.Dir(f.path))
+	if err != nil {
+		return
+	}
+
+	// time of rotation is taken from name, so backups are ordered even if they were copied
+	type backup struct {
+		path    string
+		rotated time.Time
+	}
+	backups := make([]backup, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+
+		rotated, err := time.ParseInLocation(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext), time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(filepath.Dir(f.path), name), rotated: rotated})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].rotated.After(backups[j].rotated)
+	})
+
+	for i, b := range backups {
+		if (f.maxBackups > 0 && i >= f.maxBackups) || (f.maxAge > 0 && time.Since(b.rotated) > f.maxAge) {
+			os.Remove(b.path)
+		}
+	}
+}
+
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
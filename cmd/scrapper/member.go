@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// findUserPropsJS defines JS function that walks React fibers of member row, until it finds props with Discord user
+const findUserPropsJS = `function findUserProps(row) {
+	for (var node = row; node; node = node.parentElement) {
+		var key = Object.keys(node).find(function(k) { return k.indexOf("__reactFiber") === 0 || k.indexOf("__reactInternalInstance") === 0; });
+		if (!key) {
+			continue;
+		}
+
+		for (var fiber = node[key]; fiber; fiber = fiber.return) {
+			var props = fiber.memoizedProps;
+			if (props && props.user && props.user.id) {
+				return props;
+			}
+		}
+		return null;
+	}
+	return null;
+}
+`
+
+// memberIDScript looks for snowflake ID of user in member row: in data-list-item-id attribute, in avatar URL
+// and in React props of the row, which are the only places where ID is present
+const memberIDScript = findUserPropsJS + `var row = arguments[0];
+
+var item = row.closest("[data-list-item-id]");
+if (item) {
+	var match = item.getAttribute("data-list-item-id").match(/(\d{15,21})$/);
+	if (match) {
+		return match[1];
+	}
+}
+
+var avatar = row.querySelector("img[src*='/avatars/']");
+if (avatar) {
+	var match = avatar.getAttribute("src").match(/\/avatars\/(\d{15,21})\//);
+	if (match) {
+		return match[1];
+	}
+}
+
+var props = findUserProps(row);
+return props ? props.user.id : "";`
+
+// memberID returns snowflake ID of user in member row, or empty string if it isn't found
+func memberID(driver Driver, layout Element) string {
+	id, err := driver.ExecuteScript(memberIDScript, []interface{}{layout})
+	if err != nil {
+		return ""
+	}
+
+	s, _ := id.(string)
+	return s
+}
+
+// memberRoleGroupScript returns text of the closest role section header, that precedes member row in the list
+const memberRoleGroupScript = `var row = arguments[0];
+var headers = document.querySelectorAll(arguments[1]);
+
+var group = "";
+for (var i = 0; i < headers.length; i++) {
+	if (!(headers[i].compareDocumentPosition(row) & Node.DOCUMENT_POSITION_FOLLOWING)) {
+		break;
+	}
+	group = headers[i].textContent;
+}
+
+return group;`
+
+// roleGroupCount matches members count at the end of role section header, eg: 'Admins — 3'
+var roleGroupCount = regexp.MustCompile(`\s*[—–-]\s*(\d+)$`)
+
+// memberRoleGroup returns name of role section in member list (eg: 'Admins', 'Online'), where member row is
+func memberRoleGroup(driver Driver, layout Element) string {
+	header, err := driver.ExecuteScript(memberRoleGroupScript, []interface{}{layout, selectors.MemberGroupHeader.css()})
+	if err != nil {
+		return ""
+	}
+
+	s, _ := header.(string)
+	return strings.TrimSpace(roleGroupCount.ReplaceAllString(s, ""))
+}
+
+// memberSubTextScript returns text under username in member row, which is either custom status or activity,
+// emojis are images there, so they are replaced with their alt text (unicode emoji or :name: for custom ones)
+const memberSubTextScript = `var sub = arguments[0].querySelector(arguments[1]);
+if (!sub) {
+	return "";
+}
+
+var clone = sub.cloneNode(true);
+clone.querySelectorAll("img[alt]").forEach(function(img) {
+	img.replaceWith(img.getAttribute("alt") + " ");
+});
+
+return clone.textContent.replace(/\s+/g, " ").trim();`
+
+// activityPrefixes are prefixes of rich presence activities, shown in place of custom status
+var activityPrefixes = []string{"Playing ", "Listening to ", "Watching ", "Streaming ", "Competing in "}
+
+// memberSubText returns text under username in member row, or empty string if there is none
+func memberSubText(driver Driver, layout Element) string {
+	text, err := driver.ExecuteScript(memberSubTextScript, []interface{}{layout, selectors.MemberSubText.css()})
+	if err != nil {
+		return ""
+	}
+
+	s, _ := text.(string)
+	return s
+}
+
+// isActivity checks if text under username is rich presence activity, instead of custom status
+func isActivity(subText string) bool {
+	for _, prefix := range activityPrefixes {
+		if strings.HasPrefix(subText, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// memberNamesScript returns server nickname and global username of user in member row, from React props
+const memberNamesScript = findUserPropsJS + `var props = findUserProps(arguments[0]);
+if (!props) {
+	return ["", ""];
+}
+
+return [props.nick || "", props.user.username || ""];`
+
+// memberNames returns server nickname (empty if user doesn't have one) and global username of user in member row
+func memberNames(driver Driver, layout Element) (nickname, globalUsername string) {
+	names, err := driver.ExecuteScript(memberNamesScript, []interface{}{layout})
+	if err != nil {
+		return "", ""
+	}
+
+	values, _ := names.([]interface{})
+	if len(values) != 2 {
+		return "", ""
+	}
+
+	nickname, _ = values[0].(string)
+	globalUsername, _ = values[1].(string)
+	return nickname, globalUsername
+}
+
+// memberAvatarURL returns URL of user's avatar image in member row, or empty string if it isn't found
+func memberAvatarURL(layout Element) string {
+	avatar, err := findElement(layout, selectors.MemberAvatarImage)
+	if err != nil {
+		return ""
+	}
+
+	src, err := avatar.GetAttribute("src")
+	if err != nil {
+		return ""
+	}
+
+	return src
+}
+
+// memberGroupHeadersScript returns texts of all rendered role section headers of member list
+const memberGroupHeadersScript = `return Array.from(document.querySelectorAll(arguments[0])).map(function(h) { return h.textContent; });`
+
+// memberGroupCounts adds members counts of rendered role section headers (eg: 'Online — 52') to counts,
+// keyed by role section name
+func memberGroupCounts(driver Driver, counts map[string]int) error {
+	headers, err := driver.ExecuteScript(memberGroupHeadersScript, []interface{}{selectors.MemberGroupHeader.css()})
+	if err != nil {
+		return fmt.Errorf("getting role section headers: %w", err)
+	}
+
+	values, _ := headers.([]interface{})
+	for _, v := range values {
+		header, _ := v.(string)
+
+		match := roleGroupCount.FindStringSubmatchIndex(header)
+		if match == nil {
+			continue
+		}
+
+		count, err := strconv.Atoi(header[match[2]:match[3]])
+		if err != nil {
+			continue
+		}
+
+		counts[strings.TrimSpace(header[:match[0]])] = count
+	}
+
+	return nil
+}
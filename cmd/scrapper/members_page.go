@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tebeka/selenium"
+)
+
+// membersPageRowsScript returns data of all rendered rows in Server Settings → Members page,
+// each row is [id, displayed name, username, join date, avatar url]
+const membersPageRowsScript = findUserPropsJS + `var rows = document.querySelectorAll(arguments[0]);
+var nameSelector = arguments[1], usernameSelector = arguments[2], joinedAtSelector = arguments[3];
+
+function text(row, selector) {
+	var e = row.querySelector(selector);
+	if (!e) {
+		return "";
+	}
+	return (e.getAttribute("title") || e.textContent || "").trim();
+}
+
+return Array.from(rows).map(function(row) {
+	var id = "";
+	var props = findUserProps(row);
+	if (props) {
+		id = props.user.id;
+	}
+
+	var avatarURL = "";
+	var avatar = row.querySelector("img[src*='/avatars/']");
+	if (avatar) {
+		avatarURL = avatar.getAttribute("src");
+		if (!id) {
+			var match = avatarURL.match(/\/avatars\/(\d{15,21})\//);
+			if (match) {
+				id = match[1];
+			}
+		}
+	}
+
+	return [
+		id,
+		text(row, nameSelector),
+		text(row, usernameSelector),
+		text(row, joinedAtSelector),
+		avatarURL,
+	];
+});`
+
+// membersPageScrollScript scrolls members page for some amount of pixels and returns new scroll position
+const membersPageScrollScript = `var row = document.querySelector(arguments[0]);
+if (!row) {
+	return -1;
+}
+
+var scroller = row.closest(arguments[1]);
+if (!scroller) {
+	return -1;
+}
+
+scroller.scrollTop += 700;
+return scroller.scrollTop;`
+
+// openMembersPage opens server, and then its Server Settings → Members page, it's only available for accounts
+// with permission to manage server
+func openMembersPage(driver Driver, server Server) error {
+	err := openServer(driver, server)
+	if err != nil {
+		return err
+	}
+
+	// server name in top left corner opens server menu
+	serverHeader, err := findElement(driver, selectors.ServerHeader)
+	if err != nil {
+		return fmt.Errorf("finding server header: %w", err)
+	}
+
+	err = serverHeader.Click()
+	if err != nil {
+		return fmt.Errorf("clicking server header: %w", err)
+	}
+
+	time.Sleep(time.Second) // wait until menu is shown
+
+	settingsItem, err := findElement(driver, selectors.ServerSettingsItem)
+	if err != nil {
+		return fmt.Errorf("finding server settings menu item (account may not have permission): %w", err)
+	}
+
+	err = settingsItem.Click()
+	if err != nil {
+		return fmt.Errorf("clicking server settings menu item: %w", err)
+	}
+
+	time.Sleep(2 * time.Second) // wait until settings are loaded
+
+	membersTab, err := findElement(driver, selectors.MembersTab)
+	if err != nil {
+		return fmt.Errorf("finding members tab: %w", err)
+	}
+
+	err = membersTab.Click()
+	if err != nil {
+		return fmt.Errorf("clicking members tab: %w", err)
+	}
+
+	time.Sleep(2 * time.Second) // wait until members are loaded
+
+	return nil
+}
+
+// scrapServerMembersPage opens members page of server, collects all its members and closes settings
+func scrapServerMembersPage(ctx context.Context, driver Driver, server Server) (map[string]User, error) {
+	err := retry(ctx, "Opening members page", func() error {
+		err := openMembersPage(driver, server)
+		if err != nil {
+			// menu or settings may be left opened, and they cover server links
+			closeSettings(driver)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer closeSettings(driver)
+
+	logger.With("server", server).Infof("Scrapping members page in progress...")
+	return scrapMembersPage(driver, server)
+}
+
+// closeSettings closes opened settings page, so other servers can be opened
+func closeSettings(driver Driver) error {
+	err := driver.PressKey(selenium.EscapeKey)
+	if err != nil {
+		return fmt.Errorf("closing settings: %w", err)
+	}
+
+	return nil
+}
+
+// scrapMembersPage collects all members of server from opened Server Settings → Members page, unlike member bar,
+// it contains all members including offline ones, and their join dates, but it doesn't show statuses. Members
+// collected before error are returned with it.
+func scrapMembersPage(driver Driver, server Server) (map[string]User, error) {
+	users := make(map[string]User)
+
+	var lastScrollTop interface{}
+	for i := 0; i < *discordServerMaxScrolls; i++ {
+		rows, err := driver.ExecuteScript(membersPageRowsScript, []interface{}{selectors.MembersPageRow.css(), selectors.MembersPageName.css(), selectors.MembersPageUsername.css(), selectors.MembersPageJoinedAt.css()})
+		if err != nil {
+			return users, fmt.Errorf("getting member rows: %w", err)
+		}
+
+		rowsSlice, _ := rows.([]interface{})
+		for _, row := range rowsSlice {
+			values, _ := row.([]interface{})
+			if len(values) != 5 {
+				continue
+			}
+
+			var fields [5]string
+			for j, v := range values {
+				fields[j], _ = v.(string)
+			}
+
+			id, username, globalUsername, joinedAt, avatarURL := fields[0], fields[1], fields[2], fields[3], fields[4]
+			if username == "" {
+				continue
+			}
+
+			// if user supplied his/her username then omit it from output
+			if *discordUsername != "" && (strings.EqualFold(*discordUsername, username) || strings.EqualFold(*discordUsername, globalUsername)) {
+				continue
+			}
+
+			key := id
+			if key == "" {
+				key = username
+			}
+
+			users[key] = User{
+				Server:         server.String(),
+				ID:             id,
+				Username:       username,
+				GlobalUsername: globalUsername,
+				Type:           "user",
+				JoinedAt:       joinedAt,
+				AvatarURL:      avatarURL,
+				StatusTime:     Time{time.Now()},
+			}
+		}
+
+		scrollTop, err := driver.ExecuteScript(membersPageScrollScript, []interface{}{selectors.MembersPageRow.css(), selectors.MembersPageScroller.css()})
+		if err != nil {
+			return users, fmt.Errorf("scrolling members page: %w", err)
+		}
+
+		// end of the list is reached
+		if scrollTop == lastScrollTop {
+			break
+		}
+		lastScrollTop = scrollTop
+
+		time.Sleep(*discordServerScrollRefreshTime)
+	}
+
+	return users, nil
+}
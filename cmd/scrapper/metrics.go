@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricsRegistry keeps state of last scrapping process, it's exposed in Prometheus text format, so existing
+// Prometheus/Grafana stacks can consume it without parsing output files
+type metricsRegistry struct {
+	mu sync.Mutex
+
+	users          []User
+	scrapeDuration time.Duration
+	lastScrape     time.Time
+	scrapes        int
+	errors         map[string]int // stage -> amount of errors
+}
+
+// metrics is a registry used by whole tool
+var metrics = &metricsRegistry{errors: make(map[string]int)}
+
+// Write replaces users of previous scrapping process, so registry can be used as a sink
+func (m *metricsRegistry) Write(users []User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.users = serverMembers(users)
+	return nil
+}
+
+// serverMembers keeps only first occurrence of each user of each server, as member lists of several channels of
+// server list the same users, and they would be counted several times
+func serverMembers(users []User) []User {
+	seen := make(map[[2]string]bool, len(users))
+	members := make([]User, 0, len(users))
+	for _, user := range users {
+		key := [2]string{user.Server, userKey(user)}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		members = append(members, user)
+	}
+
+	return members
+}
+
+// observeScrape records duration of finished scrapping process
+func (m *metricsRegistry) observeScrape(duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.scrapeDuration = duration
+	m.lastScrape = time.Now()
+	m.scrapes++
+}
+
+// incError counts error that happened during stage (eg: login, scrap, output)
+func (m *metricsRegistry) incError(stage string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.errors[stage]++
+}
+
+// totalErrors returns amount of errors of all stages
+func (m *metricsRegistry) totalErrors() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	total := 0
+	for _, count := range m.errors {
+		total += count
+	}
+
+	return total
+}
+
+// ServeHTTP writes metrics in Prometheus text format
+func (m *metricsRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	total := make(map[string]int)
+	online := make(map[string]int)
+	for _, user := range m.users {
+		total[user.Server]++
+		if user.Status != "Offline" {
+			online[user.Server]++
+		}
+	}
+
+	writeMetricHeader(w, "discord_members", "gauge", "Amount of scrapped members of server.")
+	for _, server := range sortedKeys(total) {
+		fmt.Fprintf(w, "discord_members{server=%s} %d\n", metricLabel(server), total[server])
+	}
+
+	writeMetricHeader(w, "discord_members_online", "gauge", "Amount of scrapped members of server, that aren't offline.")
+	for _, server := range sortedKeys(total) {
+		fmt.Fprintf(w, "discord_members_online{server=%s} %d\n", metricLabel(server), online[server])
+	}
+
+	writeMetricHeader(w, "discord_member_status", "gauge", "Status of member, it's always 1, status is in label.")
+	for _, user := range m.users {
+		fmt.Fprintf(w, "discord_member_status{server=%s,id=%s,user=%s,status=%s} 1\n",
+			metricLabel(user.Server), metricLabel(user.ID), metricLabel(user.Username), metricLabel(user.Status))
+	}
+
+	writeMetricHeader(w, "discord_scrape_duration_seconds", "gauge", "Duration of last scrapping process.")
+	fmt.Fprintf(w, "discord_scrape_duration_seconds %g\n", m.scrapeDuration.Seconds())
+
+	writeMetricHeader(w, "discord_last_scrape_timestamp_seconds", "gauge", "Time when last scrapping process finished.")
+	fmt.Fprintf(w, "discord_last_scrape_timestamp_seconds %d\n", m.lastScrape.Unix())
+
+	writeMetricHeader(w, "discord_scrapes_total", "counter", "Amount of finished scrapping processes.")
+	fmt.Fprintf(w, "discord_scrapes_total %d\n", m.scrapes)
+
+	writeMetricHeader(w, "discord_scrape_errors_total", "counter", "Amount of errors by stage of scrapping process.")
+	for _, stage := range sortedKeys(m.errors) {
+		fmt.Fprintf(w, "discord_scrape_errors_total{stage=%s} %d\n", metricLabel(stage), m.errors[stage])
+	}
+}
+
+// writeMetricHeader writes help and type lines of metric
+func writeMetricHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// metricLabel quotes label value, escaping backslashes, quotes and new lines
+func metricLabel(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+// sortedKeys returns keys of map in sorted order, so metrics are written in stable order
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// serveMetrics serves metrics on /metrics path of addr
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+
+	logger.Infof("Serving metrics on %s/metrics", addr)
+	err := http.ListenAndServe(addr, mux)
+	if err != nil {
+		logger.Errorf("Serving metrics: %v", err)
+	}
+}
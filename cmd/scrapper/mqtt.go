@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MQTT 3.1.1 packet types, they are in upper 4 bits of first byte of packet
+const (
+	mqttConnect    = 0x10
+	mqttConnack    = 0x20
+	mqttPublish    = 0x30
+	mqttDisconnect = 0xE0
+)
+
+// mqttWriter publishes statuses of users, that changed since previous scrapping process, to MQTT broker. Each
+// status is published to its own topic '<prefix>/<server>/<user>/status', so home automation tools can subscribe
+// to particular users. Broker is connected only for publishing, as there are long intervals between scrapping
+// processes.
+type mqttWriter struct {
+	broker   *url.URL
+	clientID string
+	username string
+	password string
+	prefix   string
+	retain   bool
+
+	statuses *statusTracker
+}
+
+// newMQTTWriter creates writer, that publishes to broker, it's address is like 'tcp://host:1883' or
+// 'ssl://host:8883'
+func newMQTTWriter(broker, clientID, username, password, prefix string, retain bool) (*mqttWriter, error) {
+	if !strings.Contains(broker, "://") {
+		broker = "tcp://" + broker
+	}
+
+	u, err := url.Parse(broker)
+	if err != nil {
+		return nil, fmt.Errorf("parsing mqtt broker address: %w", err)
+	}
+
+	switch u.Scheme {
+	case "tcp", "mqtt", "ssl", "tls", "mqtts":
+	default:
+		return nil, fmt.Errorf("unknown mqtt broker scheme %s", u.Scheme)
+	}
+
+	return &mqttWriter{
+		broker:   u,
+		clientID: clientID,
+		username: username,
+		password: password,
+		prefix:   strings.TrimSuffix(prefix, "/"),
+		retain:   retain,
+		statuses: newStatusTracker(),
+	}, nil
+}
+
+// Write publishes statuses of users, that changed since previous scrapping process, all statuses are published
+// after first one. If publishing fails, all statuses are published again next time, so changes aren't lost.
+func (w *mqttWriter) Write(users []User) (err error) {
+	changed := w.statuses.changes(users)
+	if len(changed) == 0 {
+		return nil
+	}
+
+	defer func() {
+		if err != nil {
+			w.statuses = newStatusTracker()
+		}
+	}()
+
+	conn, err := w.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	buf := bufio.NewWriter(conn)
+	for _, user := range changed {
+		topic := w.prefix + "/" + mqttTopicLevel(user.Server) + "/" + mqttTopicLevel(userKey(user)) + "/status"
+		err = writeMQTTPacket(buf, w.publishHeader(), mqttString(topic), []byte(user.Status))
+		if err != nil {
+			return fmt.Errorf("publishing to mqtt broker: %w", err)
+		}
+	}
+
+	err = writeMQTTPacket(buf, mqttDisconnect)
+	if err != nil {
+		return fmt.Errorf("disconnecting from mqtt broker: %w", err)
+	}
+
+	err = buf.Flush()
+	if err != nil {
+		return fmt.Errorf("publishing to mqtt broker: %w", err)
+	}
+
+	logger.Debugf("Published %d status changes to MQTT broker", len(changed))
+	return nil
+}
+
+// publishHeader returns first byte of publish packet, messages are published with QoS 0
+func (w *mqttWriter) publishHeader() byte {
+	if w.retain {
+		return mqttPublish | 0x01
+	}
+
+	return mqttPublish
+}
+
+// connect opens connection to broker and sends connect packet, it returns when broker accepts it
+func (w *mqttWriter) connect() (net.Conn, error) {
+	addr := w.broker.Host
+	tlsEnabled := w.broker.Scheme == "ssl" || w.broker.Scheme == "tls" || w.broker.Scheme == "mqtts"
+	if w.broker.Port() == "" {
+		if tlsEnabled {
+			addr += ":8883"
+		} else {
+			addr += ":1883"
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if tlsEnabled {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: w.broker.Hostname()})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connecting to mqtt broker: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	// clean session, as nothing is subscribed
+	flags := byte(0x02)
+	payload := mqttString(w.clientID)
+	if w.username != "" {
+		flags |= 0x80
+		payload = append(payload, mqttString(w.username)...)
+	}
+	if w.password != "" {
+		flags |= 0x40
+		payload = append(payload, mqttString(w.password)...)
+	}
+
+	// protocol name, level 4 (3.1.1), flags and keep alive in seconds
+	header := append(mqttString("MQTT"), 4, flags, 0, 60)
+	err = writeMQTTPacket(conn, mqttConnect, header, payload)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("sending connect packet to mqtt broker: %w", err)
+	}
+
+	var connack [4]byte
+	_, err = io.ReadFull(conn, connack[:])
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("reading connack packet from mqtt broker: %w", err)
+	}
+	if connack[0] != mqttConnack {
+		conn.Close()
+		return nil, errors.New("mqtt broker didn't respond with connack packet")
+	}
+	if connack[3] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("mqtt broker refused connection with code %d", connack[3])
+	}
+
+	return conn, nil
+}
+
+// writeMQTTPacket writes packet with header byte, its remaining length and parts
+func writeMQTTPacket(w io.Writer, header byte, parts ...[]byte) error {
+	var body bytes.Buffer
+	for _, part := range parts {
+		body.Write(part)
+	}
+
+	packet := []byte{header}
+
+	// remaining length is encoded with 7 bits per byte, highest bit tells that there are more bytes
+	length := body.Len()
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if length == 0 {
+			break
+		}
+	}
+
+	_, err := w.Write(append(packet, body.Bytes()...))
+	return err
+}
+
+// mqttString encodes string with its 2 bytes length before it
+func mqttString(s string) []byte {
+	return append([]byte{byte(len(s) >> 8), byte(len(s))}, s...)
+}
+
+// mqttTopicLevel replaces characters, that have special meaning in topics, so server or user name is one level
+func mqttTopicLevel(s string) string {
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(s)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// failureEvents are events of notifications about failures, they are sent to recipients with alerts=failures, and
+// passed to --on-error hook
+var failureEvents = []string{"captcha", "failure"}
+
+// Notification is an event that needs operator's attention
+type Notification struct {
+	Event   string    `json:"event"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// notify logs notification and sends it to webhook, Slack and email recipients, if user supplied them, failures
+// are passed to --on-error hook as well
+func notify(event, message string) {
+	logger.Infof("Notification (%s): %s", event, message)
+
+	if *notifyWebhookURL != "" {
+		err := sendWebhook(*notifyWebhookURL, Notification{
+			Event:   event,
+			Message: message,
+			Time:    time.Now(),
+		})
+		if err != nil {
+			logger.Errorf("Couldn't send notification to webhook: %v", err)
+		}
+	}
+
+	if *slackWebhookURL != "" {
+		err := sendWebhook(*slackWebhookURL, slackNotification(event, message))
+		if err != nil {
+			logger.Errorf("Couldn't send notification to Slack: %v", err)
+		}
+	}
+
+	sendEmailAlerts(event, message)
+
+	if *onError != "" && containsString(failureEvents, event) {
+		runErrorHook(Notification{Event: event, Message: message, Time: time.Now()})
+	}
+}
+
+// sendWebhook posts v as json to url
+func sendWebhook(url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding webhook body: %w", err)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("posting webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// openOutputFile opens existing output file for appending, or creates it if it doesn't exist
+func openOutputFile(path string) (*os.File, error) {
+	_, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		logger.Infof("Creating new file %s", path)
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("creating output file: %w", err)
+		}
+
+		return file, nil
+	}
+
+	logger.Infof("Appending to existing file %s", path)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("opening output file: %w", err)
+	}
+
+	return file, nil
+}
+
+// splitBots separates bot accounts from users, so they can be written to their own file
+func splitBots(users []User) (humans, bots []User) {
+	humans = make([]User, 0, len(users))
+	bots = make([]User, 0)
+	for _, user := range users {
+		if user.Type == "bot" {
+			bots = append(bots, user)
+		} else {
+			humans = append(humans, user)
+		}
+	}
+
+	return humans, bots
+}
+
+// recordWriter writes users of each scrapping cycle to output
+type recordWriter interface {
+	Write(users []User) error
+}
+
+// newRecordWriter creates writer of output format, it's compressed with gzip if compress is true. Output is
+// appended to existing file, so csv header is written only if file doesn't have it yet.
+func newRecordWriter(format string, w io.Writer, compress bool) (recordWriter, error) {
+	header := true
+	if format == "csv" {
+		exists, err := csvHeaderExists(w, compress)
+		if err != nil {
+			return nil, err
+		}
+		header = !exists
+	}
+	if format == "json" {
+		empty, err := outputFileEmpty(w)
+		if err != nil {
+			return nil, err
+		}
+		if !empty {
+			return nil, errors.New("json output can't be appended to existing file, as file with several arrays isn't valid JSON, use ndjson format or another file")
+		}
+	}
+
+	// parquet and xlsx files are written by their writers directly, as they aren't made of lines
+	if format == "parquet" || format == "xlsx" {
+		if compress {
+			return nil, fmt.Errorf("%s output can't be compressed with gzip", format)
+		}
+		return newFormatRecordWriter(format, w, header)
+	}
+
+	// rows of each cycle are encoded into buffer first, and then written to output at once
+	buf := new(bytes.Buffer)
+	var fw recordWriter
+	var err error
+	if compress {
+		fw, err = newGzipRecordWriter(format, buf, header)
+	} else {
+		fw, err = newFormatRecordWriter(format, buf, header)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &syncRecordWriter{dst: w, buf: buf, w: fw}, nil
+}
+
+// newFormatRecordWriter creates writer of output format, header is written only if it's true (eg: csv header)
+func newFormatRecordWriter(format string, w io.Writer, header bool) (recordWriter, error) {
+	switch format {
+	case "csv":
+		return &csvRecordWriter{w: csv.NewWriter(w), header: header}, nil
+	case "json":
+		return &jsonRecordWriter{enc: json.NewEncoder(w)}, nil
+	case "ndjson":
+		return &ndjsonRecordWriter{enc: json.NewEncoder(w)}, nil
+	case "xlsx":
+		xw, err := newXLSXRecordWriter(w, *xlsxSheets == "run")
+		if err != nil {
+			return nil, err
+		}
+		return xw, nil
+	case "template":
+		return &templateRecordWriter{w: w, tmpl: recordTemplate}, nil
+	case "parquet":
+		pw, err := newParquetRecordWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return pw, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %s", format)
+	}
+}
+
+// closeRecordWriter finalizes writer, if its format needs it (eg: parquet footer)
+func closeRecordWriter(w recordWriter) {
+	c, ok := w.(io.Closer)
+	if !ok {
+		return
+	}
+
+	err := c.Close()
+	if err != nil {
+		logger.Errorf("Couldn't close output writer: %v", err)
+	}
+}
+
+// openOutputWriter opens output file at path, and creates writer of output format into it. Parquet and xlsx outputs
+// are directories (see isDirectoryOutput), and encrypted output is written into file of each scrapping process, so
+// they don't have file.
+func openOutputWriter(format, path string) (*os.File, recordWriter, error) {
+	if *encryptOutput {
+		return nil, newEncryptedRecordWriter(format, path, isCompressedOutput(strings.TrimSuffix(path, ".age"))), nil
+	}
+
+	switch format {
+	case "parquet":
+		w, err := newParquetDatasetWriter(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, w, nil
+	case "xlsx":
+		w, err := newXLSXDatasetWriter(path, *xlsxSheets == "run")
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, w, nil
+	}
+
+	file, err := openOutputFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w, err := newFileRecordWriter(format, file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return file, w, nil
+}
+
+// isDirectoryOutput checks if output of format is a directory of files, that are never rewritten after they are
+// finished, as files of format can't be appended to: parquet part file of each scrapping process, and xlsx workbook
+// of each day
+func isDirectoryOutput(format string) bool {
+	return format == "parquet" || format == "xlsx"
+}
+
+// newFileRecordWriter creates writer of output format into file, it's compressed if user asked for it, or file has
+// .gz extension
+func newFileRecordWriter(format string, file *os.File) (recordWriter, error) {
+	return newRecordWriter(format, file, isCompressedOutput(file.Name()))
+}
+
+// isCompressedOutput checks if output file has to be compressed with gzip, it's detected by .gz extension as well
+func isCompressedOutput(path string) bool {
+	return *compressOutput || strings.HasSuffix(path, ".gz")
+}
+
+// gzipRecordWriter compresses output of another writer, users of each cycle are written as separate gzip member,
+// so file stays valid if tool is stopped between cycles, and existing file can be appended to. Concatenated
+// members are read as one stream by gzip, zcat and etc.
+type gzipRecordWriter struct {
+	gz  *gzip.Writer
+	dst io.Writer
+	w   recordWriter
+}
+
+// newGzipRecordWriter creates writer of output format, that is compressed with gzip
+func newGzipRecordWriter(format string, dst io.Writer, header bool) (*gzipRecordWriter, error) {
+	gz := gzip.NewWriter(dst)
+	w, err := newFormatRecordWriter(format, gz, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gzipRecordWriter{gz: gz, dst: dst, w: w}, nil
+}
+
+func (w *gzipRecordWriter) Write(users []User) error {
+	// empty member would be appended on each cycle without users, eg: when server couldn't be scrapped
+	if len(users) == 0 {
+		return nil
+	}
+
+	w.gz.Reset(w.dst)
+
+	err := w.w.Write(users)
+	if err != nil {
+		return err
+	}
+
+	return w.gz.Close()
+}
+
+// outputFileEmpty checks if output file is empty, writers that aren't files (eg: stdout) are considered empty
+func outputFileEmpty(w io.Writer) (bool, error) {
+	file, ok := w.(*os.File)
+	if !ok {
+		return true, nil
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("getting info of output file: %w", err)
+	}
+
+	return info.Size() == 0, nil
+}
+
+// csvHeaderExists checks if existing output file starts with csv header, so it isn't written again, when file is
+// appended to. File with different columns can't be appended to, as its rows would be mixed up.
+func csvHeaderExists(w io.Writer, compressed bool) (bool, error) {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false, nil
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("getting info of output file: %w", err)
+	}
+	if info.Size() == 0 {
+		return false, nil
+	}
+
+	// output file is opened only for writing, so it's read with separate descriptor
+	r, err := os.Open(file.Name())
+	if err != nil {
+		return false, fmt.Errorf("opening output file for reading: %w", err)
+	}
+	defer r.Close()
+
+	var src io.Reader = r
+	if compressed {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return false, fmt.Errorf("decompressing output file: %w", err)
+		}
+		defer gz.Close()
+
+		src = gz
+	}
+
+	line, err := bufio.NewReader(src).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("reading header of output file: %w", err)
+	}
+
+	if strings.TrimRight(line, "\r\n") != strings.Join(outputColumns, ",") {
+		return false, errors.New("existing output file has different columns, it can't be appended to")
+	}
+
+	return true, nil
+}
+
+// csvRecordWriter writes users as csv rows, header is written only once, before first row of file
+type csvRecordWriter struct {
+	w      *csv.Writer
+	header bool // header has to be written before next row
+}
+
+func (w *csvRecordWriter) Write(users []User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	if w.header {
+		err := w.w.Write(outputColumns)
+		if err != nil {
+			return err
+		}
+		w.header = false
+	}
+
+	for _, user := range users {
+		err := w.w.Write(userValues(user, outputColumns))
+		if err != nil {
+			return err
+		}
+	}
+
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// jsonRecordWriter writes users as one json array, on its own line. It's used only for outputs written once (eg: --once
+// or export), as file with array of each cycle wouldn't be valid JSON, ndjson is used for runs with interval instead.
+type jsonRecordWriter struct {
+	enc *json.Encoder
+}
+
+func (w *jsonRecordWriter) Write(users []User) error {
+	records := make([]userRecord, len(users))
+	for i, user := range users {
+		records[i] = userRecord(user)
+	}
+
+	return w.enc.Encode(records)
+}
+
+// ndjsonRecordWriter writes each user as json object on its own line, so output can be piped to tools like jq
+type ndjsonRecordWriter struct {
+	enc *json.Encoder
+}
+
+func (w *ndjsonRecordWriter) Write(users []User) error {
+	for _, user := range users {
+		err := w.enc.Encode(userRecord(user))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordTemplate is a template of each user, supplied by user with --format-template
+var recordTemplate *template.Template
+
+// parseRecordTemplate parses template of user, escaped tabs and new lines are unescaped, as shells pass them as
+// they are, and new line is added to the end, if template doesn't have it
+func parseRecordTemplate(text string) (*template.Template, error) {
+	text = strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n").Replace(text)
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+
+	tmpl, err := template.New("record").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing format template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// templateRecordWriter writes each user with template, eg: '{{.Username}}\t{{.Status}}'
+type templateRecordWriter struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+func (w *templateRecordWriter) Write(users []User) error {
+	// users are rendered into buffer, so failed template doesn't leave half of line in output
+	var buf bytes.Buffer
+	for _, user := range users {
+		err := w.tmpl.Execute(&buf, user)
+		if err != nil {
+			return fmt.Errorf("executing format template: %w", err)
+		}
+	}
+
+	_, err := w.w.Write(buf.Bytes())
+	return err
+}
+
+// userRecord is encoded into json object with output columns only, in their order
+type userRecord User
+
+func (r userRecord) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, column := range outputColumns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(column)
+		buf.Write(key)
+		buf.WriteByte(':')
+
+		value, err := json.Marshal(userValue(User(r), column))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// userColumns are names of all user's columns, in their default order
+var userColumns = []string{
+	"server", "channel", "id", "username", "nickname", "global_username", "status", "type", "role_group",
+	"custom_status", "activity", "avatar_url", "joined_at", "status_time", "previous_status",
+}
+
+// outputColumns are columns written to output, in their order, they are supplied by user with --columns
+var outputColumns = userColumns
+
+// parseColumns checks that columns supplied by user are known, and aren't repeated, all columns are returned if
+// none are supplied
+func parseColumns(columns []string) ([]string, error) {
+	if len(columns) == 0 {
+		return userColumns, nil
+	}
+
+	seen := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		if !containsString(userColumns, column) {
+			return nil, fmt.Errorf("unknown column %s, known columns are: %s", column, strings.Join(userColumns, ", "))
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("column %s is repeated", column)
+		}
+		seen[column] = true
+	}
+
+	return columns, nil
+}
+
+// userValues returns values of user's columns, in order of columns
+func userValues(user User, columns []string) []string {
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		values[i] = userValue(user, column)
+	}
+
+	return values
+}
+
+// userValue returns value of user's column, status time is formatted with --time-format in --timezone (see
+// formatStatusTime)
+func userValue(user User, column string) string {
+	switch column {
+	case "server":
+		return user.Server
+	case "channel":
+		return user.Channel
+	case "id":
+		return user.ID
+	case "username":
+		return user.Username
+	case "nickname":
+		return user.Nickname
+	case "global_username":
+		return user.GlobalUsername
+	case "status":
+		return user.Status
+	case "type":
+		return user.Type
+	case "role_group":
+		return user.RoleGroup
+	case "custom_status":
+		return user.CustomStatus
+	case "activity":
+		return user.Activity
+	case "avatar_url":
+		return user.AvatarURL
+	case "joined_at":
+		return user.JoinedAt
+	case "status_time":
+		return formatStatusTime(user.StatusTime.Time)
+	case "previous_status":
+		return user.PreviousStatus
+	default:
+		return ""
+	}
+}
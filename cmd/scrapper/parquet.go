@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// parquetUser is a row of parquet output with all columns, status time is a timestamp. Rows of output are built
+// from its fields of output columns, in their order, see parquetRowType.
+type parquetUser struct {
+	Server         string `parquet:"name=server, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Channel        string `parquet:"name=channel, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ID             string `parquet:"name=id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Username       string `parquet:"name=username, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Nickname       string `parquet:"name=nickname, type=BYTE_ARRAY, convertedtype=UTF8"`
+	GlobalUsername string `parquet:"name=global_username, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Status         string `parquet:"name=status, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Type           string `parquet:"name=type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	RoleGroup      string `parquet:"name=role_group, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	CustomStatus   string `parquet:"name=custom_status, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Activity       string `parquet:"name=activity, type=BYTE_ARRAY, convertedtype=UTF8"`
+	AvatarURL      string `parquet:"name=avatar_url, type=BYTE_ARRAY, convertedtype=UTF8"`
+	JoinedAt       string `parquet:"name=joined_at, type=BYTE_ARRAY, convertedtype=UTF8"`
+	StatusTime     int64  `parquet:"name=status_time, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	PreviousStatus string `parquet:"name=previous_status, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+}
+
+// parquetRowType returns type of parquet row with fields of parquetUser, that are in columns, in their order
+func parquetRowType(columns []string) reflect.Type {
+	all := reflect.TypeOf(parquetUser{})
+	fields := make([]reflect.StructField, 0, len(columns))
+	for _, column := range columns {
+		for i := 0; i < all.NumField(); i++ {
+			field := all.Field(i)
+			if strings.HasPrefix(field.Tag.Get("parquet"), "name="+column+",") {
+				fields = append(fields, field)
+				break
+			}
+		}
+	}
+
+	return reflect.StructOf(fields)
+}
+
+// parquetRecordWriter writes users of each cycle as one row group of parquet file with output columns, file is
+// finished by Close, as parquet footer is at the end of file. It's used for outputs written once (eg: export),
+// output of scrapping processes is written by parquetDatasetWriter instead.
+type parquetRecordWriter struct {
+	rowType reflect.Type
+	pw      *writer.ParquetWriter
+	closed  bool
+}
+
+// newParquetRecordWriter creates parquet writer, rows are compressed with snappy. Parquet file can't be appended to,
+// so existing file has to be empty.
+func newParquetRecordWriter(w io.Writer) (*parquetRecordWriter, error) {
+	empty, err := outputFileEmpty(w)
+	if err != nil {
+		return nil, err
+	}
+	if !empty {
+		return nil, errors.New("existing parquet file can't be appended to, as its footer is at the end of file")
+	}
+
+	rowType := parquetRowType(outputColumns)
+	pw, err := writer.NewParquetWriterFromWriter(w, reflect.New(rowType).Interface(), 1)
+	if err != nil {
+		return nil, fmt.Errorf("creating parquet writer: %w", err)
+	}
+
+	return &parquetRecordWriter{rowType: rowType, pw: pw}, nil
+}
+
+func (w *parquetRecordWriter) Write(users []User) error {
+	return writeParquetRows(w.pw, w.rowType, users)
+}
+
+// Close writes parquet footer, it can be called several times
+func (w *parquetRecordWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	return w.pw.WriteStop()
+}
+
+// parquetDatasetWriter writes users of each cycle into new part file of dataset directory, which is partitioned by
+// date of status time, like <dir>/date=2026-01-02/part-3.parquet, so files are never rewritten, and directory can
+// be read by DuckDB or Spark as one table. Part file is written into temporary file first, and renamed after it's
+// finished, so readers never see half-written file, even if tool crashes.
+type parquetDatasetWriter struct {
+	dir     string
+	rowType reflect.Type
+}
+
+// newParquetDatasetWriter creates dataset directory, if it doesn't exist, parts of existing dataset are kept, and
+// new parts are numbered after them
+func newParquetDatasetWriter(dir string) (*parquetDatasetWriter, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("creating parquet dataset directory: %w", err)
+	}
+
+	return &parquetDatasetWriter{dir: dir, rowType: parquetRowType(outputColumns)}, nil
+}
+
+func (w *parquetDatasetWriter) Write(users []User) error {
+	// cycle without users doesn't need its own part
+	if len(users) == 0 {
+		return nil
+	}
+
+	partition := filepath.Join(w.dir, "date="+users[0].StatusTime.In(statusTimeLocation).Format("2006-01-02"))
+	err := os.MkdirAll(partition, 0755)
+	if err != nil {
+		return fmt.Errorf("creating parquet partition directory: %w", err)
+	}
+
+	part, err := nextParquetPart(partition)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(partition, fmt.Sprintf("part-%d.parquet", part)), func(dst io.Writer) error {
+		pw, err := writer.NewParquetWriterFromWriter(dst, reflect.New(w.rowType).Interface(), 1)
+		if err != nil {
+			return fmt.Errorf("creating parquet writer: %w", err)
+		}
+
+		err = writeParquetRows(pw, w.rowType, users)
+		if err != nil {
+			return err
+		}
+
+		err = pw.WriteStop()
+		if err != nil {
+			return fmt.Errorf("writing parquet footer: %w", err)
+		}
+		return nil
+	})
+}
+
+// nextParquetPart returns number of next part file of partition directory, it's one more than the biggest number of
+// existing parts, so parts of previous runs aren't overwritten
+func nextParquetPart(partition string) (int, error) {
+	entries, err := ioutil.ReadDir(partition)
+	if err != nil {
+		return 0, fmt.Errorf("reading parquet partition directory: %w", err)
+	}
+
+	next := 1
+	for _, entry := range entries {
+		var part int
+		_, err := fmt.Sscanf(entry.Name(), "part-%d.parquet", &part)
+		if err == nil && entry.Name() == fmt.Sprintf("part-%d.parquet", part) && part >= next {
+			next = part + 1
+		}
+	}
+
+	return next, nil
+}
+
+// writeParquetRows writes users as one row group
+func writeParquetRows(pw *writer.ParquetWriter, rowType reflect.Type, users []User) error {
+	columns := parquetColumns(rowType)
+	for _, user := range users {
+		row := reflect.New(rowType).Elem()
+		for i, column := range columns {
+			if column == "status_time" {
+				row.Field(i).SetInt(user.StatusTime.UnixNano() / int64(1e6))
+			} else {
+				row.Field(i).SetString(userValue(user, column))
+			}
+		}
+
+		err := pw.Write(row.Interface())
+		if err != nil {
+			return fmt.Errorf("writing parquet row: %w", err)
+		}
+	}
+
+	// each cycle is a separate row group
+	return pw.Flush(true)
+}
+
+// parquetColumns returns names of columns of parquet row type
+func parquetColumns(rowType reflect.Type) []string {
+	columns := make([]string, rowType.NumField())
+	for i := range columns {
+		tag := rowType.Field(i).Tag.Get("parquet")
+		columns[i] = strings.TrimPrefix(strings.SplitN(tag, ",", 2)[0], "name=")
+	}
+
+	return columns
+}
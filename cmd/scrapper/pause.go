@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// pauseSwitch pauses and resumes scrapping without stopping tool, so logged in browser is kept, while it isn't used
+// (eg: when selenium node is needed for something else)
+type pauseSwitch struct {
+	mu      sync.Mutex
+	resumed chan struct{} // it's closed, while scrapping isn't paused
+}
+
+// scrapPause is a switch used by whole tool
+var scrapPause = newPauseSwitch()
+
+// newPauseSwitch creates switch, scrapping isn't paused by default
+func newPauseSwitch() *pauseSwitch {
+	resumed := make(chan struct{})
+	close(resumed)
+
+	return &pauseSwitch{resumed: resumed}
+}
+
+// pause pauses scrapping, it's paused before next call of browser, or before next scrapping process
+func (p *pauseSwitch) pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.resumed:
+		p.resumed = make(chan struct{})
+		logger.Infof("Scrapping is paused")
+	default:
+	}
+}
+
+// resume resumes paused scrapping
+func (p *pauseSwitch) resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.resumed:
+	default:
+		close(p.resumed)
+		logger.Infof("Scrapping is resumed")
+	}
+}
+
+// paused checks if scrapping is paused
+func (p *pauseSwitch) paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.resumed:
+		return false
+	default:
+		return true
+	}
+}
+
+// wait blocks while scrapping is paused, error is returned if ctx is canceled
+func (p *pauseSwitch) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	resumed := p.resumed
+	p.mu.Unlock()
+
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// serveControlSocket accepts commands on unix socket at path, each command is a line: 'pause', 'resume' or
+// 'status', and state of scrapping is responded to it. It's used on Windows, where there are no user signals.
+func serveControlSocket(path string) {
+	// socket file is left, if tool crashes
+	os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		logger.Errorf("Listening on control socket: %v", err)
+		return
+	}
+	defer l.Close()
+
+	logger.Infof("Accepting commands on control socket %s", path)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			logger.Errorf("Accepting control connection: %v", err)
+			return
+		}
+
+		go handleControlConn(conn)
+	}
+}
+
+// handleControlConn executes commands of control connection, until it's closed
+func handleControlConn(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		switch command := strings.TrimSpace(scanner.Text()); command {
+		case "pause":
+			scrapPause.pause()
+		case "resume":
+			scrapPause.resume()
+		case "status":
+		default:
+			fmt.Fprintf(conn, "unknown command %s\n", command)
+			continue
+		}
+
+		if scrapPause.paused() {
+			fmt.Fprintln(conn, "paused")
+		} else {
+			fmt.Fprintln(conn, "running")
+		}
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handlePauseSignals pauses scrapping on SIGUSR1 and resumes it on SIGUSR2
+func handlePauseSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGUSR1 {
+				scrapPause.pause()
+			} else {
+				scrapPause.resume()
+			}
+		}
+	}()
+}
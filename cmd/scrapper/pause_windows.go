@@ -0,0 +1,4 @@
+package main
+
+// handlePauseSignals does nothing, as Windows doesn't have user signals, scrapping is paused with control socket
+func handlePauseSignals() {}
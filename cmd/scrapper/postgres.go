@@ -0,0 +1,266 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq" // pure Go driver, so tool is still built without cgo
+)
+
+// postgresBatchSize is maximum amount of rows inserted with one statement, PostgreSQL limits amount of parameters
+// of statement to 65535
+const postgresBatchSize = 1000
+
+// postgresSchema creates the same tables as in SQLite database, with native types. Several scrapper instances can
+// write into the same database, as users are upserted and duplicated samples are skipped.
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	server          TEXT NOT NULL,
+	id              TEXT NOT NULL,
+	username        TEXT NOT NULL,
+	nickname        TEXT NOT NULL,
+	global_username TEXT NOT NULL,
+	type            TEXT NOT NULL,
+	avatar_url      TEXT NOT NULL,
+	joined_at       TEXT NOT NULL,
+	first_seen      TIMESTAMPTZ NOT NULL,
+	last_seen       TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (server, id)
+);
+
+CREATE TABLE IF NOT EXISTS presence_samples (
+	server        TEXT NOT NULL,
+	channel       TEXT NOT NULL,
+	user_id       TEXT NOT NULL,
+	status        TEXT NOT NULL,
+	role_group    TEXT NOT NULL,
+	custom_status TEXT NOT NULL,
+	activity      TEXT NOT NULL,
+	sampled_at    TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS presence_samples_user_time ON presence_samples (server, channel, user_id, sampled_at);
+CREATE INDEX IF NOT EXISTS presence_samples_time ON presence_samples (sampled_at);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	server     TEXT NOT NULL,
+	user_id    TEXT NOT NULL,
+	username   TEXT NOT NULL,
+	started_at TIMESTAMPTZ NOT NULL,
+	ended_at   TIMESTAMPTZ NOT NULL,
+	samples    INTEGER NOT NULL,
+	open       BOOLEAN NOT NULL,
+	PRIMARY KEY (server, user_id, started_at)
+);
+`
+
+// postgresWriter writes users into PostgreSQL database
+type postgresWriter struct {
+	db *sql.DB
+}
+
+// openPostgres connects to PostgreSQL database with dsn, and creates its tables if they don't exist
+func openPostgres(dsn string) (*postgresWriter, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening postgres database: %w", err)
+	}
+
+	_, err = db.Exec(postgresSchema)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating postgres tables: %w", err)
+	}
+
+	return &postgresWriter{db: db}, nil
+}
+
+// Write upserts users and appends their presence samples in one transaction, rows are inserted in batches
+func (w *postgresWriter) Write(users []User) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for start := 0; start < len(users); start += postgresBatchSize {
+		end := start + postgresBatchSize
+		if end > len(users) {
+			end = len(users)
+		}
+		batch := users[start:end]
+		deduped := dedupUsers(batch)
+
+		usersArgs := make([]interface{}, 0, len(deduped)*10)
+		for _, user := range deduped {
+			usersArgs = append(usersArgs, user.Server, userKey(user), user.Username, user.Nickname, user.GlobalUsername, user.Type, user.AvatarURL, user.JoinedAt, user.StatusTime.Time, user.StatusTime.Time)
+		}
+
+		samplesArgs := make([]interface{}, 0, len(batch)*8)
+		for _, user := range batch {
+			samplesArgs = append(samplesArgs, user.Server, user.Channel, userKey(user), user.Status, user.RoleGroup, user.CustomStatus, user.Activity, user.StatusTime.Time)
+		}
+
+		_, err = tx.Exec(`INSERT INTO users (server, id, username, nickname, global_username, type, avatar_url, joined_at, first_seen, last_seen)
+VALUES `+postgresValues(len(deduped), 10)+`
+ON CONFLICT (server, id) DO UPDATE SET
+	username = excluded.username,
+	nickname = excluded.nickname,
+	global_username = excluded.global_username,
+	type = excluded.type,
+	avatar_url = excluded.avatar_url,
+	joined_at = CASE WHEN excluded.joined_at = '' THEN users.joined_at ELSE excluded.joined_at END,
+	last_seen = excluded.last_seen`, usersArgs...)
+		if err != nil {
+			return fmt.Errorf("upserting users: %w", err)
+		}
+
+		_, err = tx.Exec(`INSERT INTO presence_samples (server, channel, user_id, status, role_group, custom_status, activity, sampled_at)
+VALUES `+postgresValues(len(batch), 8)+`
+ON CONFLICT DO NOTHING`, samplesArgs...)
+		if err != nil {
+			return fmt.Errorf("inserting presence samples: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// WriteSessions upserts sessions in one transaction, rows are inserted in batches
+func (w *postgresWriter) WriteSessions(sessions []userSession) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for start := 0; start < len(sessions); start += postgresBatchSize {
+		end := start + postgresBatchSize
+		if end > len(sessions) {
+			end = len(sessions)
+		}
+		batch := sessions[start:end]
+
+		args := make([]interface{}, 0, len(batch)*7)
+		for _, s := range batch {
+			args = append(args, s.Server, s.ID, s.Username, s.Start, s.End, s.Samples, s.Open)
+		}
+
+		_, err = tx.Exec(`INSERT INTO sessions (server, user_id, username, started_at, ended_at, samples, open)
+VALUES `+postgresValues(len(batch), 7)+`
+ON CONFLICT (server, user_id, started_at) DO UPDATE SET
+	username = excluded.username,
+	ended_at = excluded.ended_at,
+	samples = excluded.samples,
+	open = excluded.open`, args...)
+		if err != nil {
+			return fmt.Errorf("upserting sessions: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// PruneSamples removes presence samples older than before in one transaction, users without samples left and
+// sessions finished before are removed as well. Samples are archived first, so they aren't lost, if archiving fails.
+func (w *postgresWriter) PruneSamples(before time.Time, archive func(users []User) error) (int64, error) {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if archive != nil {
+		// time is selected in the same format as in SQLite database, so rows are read the same way
+		rows, err := tx.Query(`SELECT s.server, s.channel, s.user_id, u.username, u.nickname, u.global_username, s.status, u.type,
+	s.role_group, s.custom_status, s.activity, u.avatar_url, u.joined_at, to_char(s.sampled_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+FROM presence_samples s JOIN users u ON u.server = s.server AND u.id = s.user_id
+WHERE s.sampled_at < $1 ORDER BY s.sampled_at`, before)
+		if err != nil {
+			return 0, fmt.Errorf("querying old presence samples: %w", err)
+		}
+		users, err := scanStoredUsers(rows)
+		rows.Close()
+		if err != nil {
+			return 0, err
+		}
+
+		err = archive(users)
+		if err != nil {
+			return 0, fmt.Errorf("archiving old presence samples: %w", err)
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM presence_samples WHERE sampled_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("removing old presence samples: %w", err)
+	}
+	removed, _ := result.RowsAffected()
+
+	_, err = tx.Exec(`DELETE FROM users WHERE last_seen < $1
+	AND NOT EXISTS (SELECT 1 FROM presence_samples s WHERE s.server = users.server AND s.user_id = users.id)`, before)
+	if err != nil {
+		return 0, fmt.Errorf("removing old users: %w", err)
+	}
+	_, err = tx.Exec(`DELETE FROM sessions WHERE ended_at < $1 AND NOT open`, before)
+	if err != nil {
+		return 0, fmt.Errorf("removing old sessions: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return removed, nil
+}
+
+// Close closes database
+func (w *postgresWriter) Close() error {
+	return w.db.Close()
+}
+
+// postgresValues returns placeholders of rows for multi-row insert, eg: '($1, $2), ($3, $4)'
+func postgresValues(rows, columns int) string {
+	values := make([]string, rows)
+	for i := range values {
+		placeholders := make([]string, columns)
+		for j := range placeholders {
+			placeholders[j] = fmt.Sprintf("$%d", i*columns+j+1)
+		}
+		values[i] = "(" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	return strings.Join(values, ", ")
+}
+
+// dedupUsers removes repeated users (the same user is scrapped in several channels), as one statement can't
+// upsert the same row twice, last one is kept
+func dedupUsers(users []User) []User {
+	index := make(map[string]int, len(users))
+	deduped := make([]User, 0, len(users))
+	for _, user := range users {
+		key := user.Server + "\x00" + userKey(user)
+		if i, ok := index[key]; ok {
+			deduped[i] = user
+			continue
+		}
+
+		index[key] = len(deduped)
+		deduped = append(deduped, user)
+	}
+
+	return deduped
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// progressBarWidth is amount of characters in progress bar
+const progressBarWidth = 30
+
+// scrapProgress reports progress of member list scrapping: scrolls done, users found and estimated time left. It's
+// either a progress bar in terminal, or log lines written every --progress-interval, when tool runs as daemon.
+type scrapProgress struct {
+	logger     *Logger
+	bar        bool
+	maxScrolls int
+	started    time.Time
+	reported   time.Time
+}
+
+// newScrapProgress starts progress of member list, that is scrolled at most maxScrolls times
+func newScrapProgress(server Server, channel Channel, maxScrolls int) *scrapProgress {
+	now := time.Now()
+	return &scrapProgress{
+		logger:     logger.With("server", server, "channel", channel),
+		bar:        progressMode() == "bar",
+		maxScrolls: maxScrolls,
+		started:    now,
+		reported:   now,
+	}
+}
+
+// progressMode returns how progress is reported: auto mode shows progress bar, if tool is run in terminal by user,
+// and writes log lines otherwise
+func progressMode() string {
+	if *progressReport != "auto" {
+		return *progressReport
+	}
+
+	// several workers can't share one progress bar, and json logs are read by programs
+	if *concurrency > 1 || *logFormat == "json" || *pathToOutputFile == "-" || !isTerminal(os.Stderr) {
+		return "log"
+	}
+
+	return "bar"
+}
+
+// isTerminal checks if file is a terminal
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// update reports progress after scroll, expected is amount of members from role section headers, it's 0 if they
+// don't contain counts
+func (p *scrapProgress) update(scrolls, users, expected int) {
+	if *progressReport == "off" {
+		return
+	}
+
+	left := p.estimate(scrolls, users, expected)
+	if p.bar {
+		done := progressBarWidth * scrolls / p.maxScrolls
+		if done > progressBarWidth {
+			done = progressBarWidth
+		}
+		fmt.Fprintf(os.Stderr, "\r\033[K[%s%s] %d/%d scrolls, %d users, %s left", strings.Repeat("#", done),
+			strings.Repeat(".", progressBarWidth-done), scrolls, p.maxScrolls, users, left)
+		return
+	}
+
+	if time.Since(p.reported) < *progressInterval {
+		return
+	}
+	p.reported = time.Now()
+
+	p.logger.With("scrolls", scrolls, "users", users).Infof("Scrapped %d users after %d of %d scrolls, about %s left",
+		users, scrolls, p.maxScrolls, left)
+}
+
+// estimate returns approximate time left, it's based on members count, if headers contain it, as list usually ends
+// much earlier than after maximum amount of scrolls
+func (p *scrapProgress) estimate(scrolls, users, expected int) time.Duration {
+	done := float64(scrolls) / float64(p.maxScrolls)
+	if expected > 0 {
+		done = float64(users) / float64(expected)
+	}
+	if done <= 0 {
+		return 0
+	}
+	if done > 1 {
+		done = 1
+	}
+
+	elapsed := time.Since(p.started)
+	return (time.Duration(float64(elapsed)/done) - elapsed).Round(time.Second)
+}
+
+// finish clears progress bar, so next log line isn't written after it
+func (p *scrapProgress) finish() {
+	if p.bar && *progressReport != "off" {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+}
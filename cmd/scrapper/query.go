@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// parseQueryTime parses bound of query: age relative to now (eg: 24h, 7d), date in local time (2006-01-02) or time
+// in RFC 3339 format. Date of end bound is inclusive, so its next day is returned.
+func parseQueryTime(value string, now time.Time, end bool) (time.Time, error) {
+	if d, err := parseAge(value); err == nil {
+		return now.Add(-d), nil
+	}
+
+	t, err := parseRangeTime(value, end)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("time %q isn't duration (24h, 7d), date (2006-01-02) or RFC 3339 time", value)
+	}
+
+	return t, nil
+}
+
+// parseAge parses duration, that can be in days as well (eg: 7d, 90d), as hours are too small unit for history
+func parseAge(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err == nil && days >= 0 {
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%q isn't duration, like 24h or 7d", value)
+	}
+
+	return d, nil
+}
+
+// sampleQuery is a filter of stored users, empty conditions match all users
+type sampleQuery struct {
+	users    []userPattern
+	server   string
+	statuses []string
+	since    time.Time
+	until    time.Time
+}
+
+// match checks if user matches all conditions of query
+func (q sampleQuery) match(user User) bool {
+	if q.server != "" && user.Server != q.server {
+		return false
+	}
+	if len(q.users) > 0 && !matchAny(q.users, user) {
+		return false
+	}
+	if len(q.statuses) > 0 && !containsFold(q.statuses, user.Status) {
+		return false
+	}
+	if !q.since.IsZero() && user.StatusTime.Before(q.since) {
+		return false
+	}
+
+	return q.until.IsZero() || user.StatusTime.Before(q.until)
+}
+
+// querySamples returns users matching query sorted by time, only last limit users are returned, if limit is positive
+func querySamples(users []User, q sampleQuery, limit int) []User {
+	matched := make([]User, 0)
+	for _, user := range users {
+		if q.match(user) {
+			matched = append(matched, user)
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].StatusTime.Before(matched[j].StatusTime.Time)
+	})
+
+	if limit > 0 && len(matched) > limit {
+		matched = matched[len(matched)-limit:]
+	}
+
+	return matched
+}
+
+// queryCommand prints stored users, that match filters, so questions like 'when was Alice online yesterday' are
+// answered without loading whole history into other tools
+func queryCommand(args []string) int {
+	flags := pflag.NewFlagSet("query", pflag.ExitOnError)
+	inputs := flags.StringArrayP("input", "i", nil, "path to file (csv, json or ndjson, optionally .gz) or SQLite database (sqlite://users.db), where users are read from, can be repeated")
+	users := flags.StringArrayP("user", "u", nil, "comma separated IDs, names or regular expressions wrapped in slashes of users, can be repeated")
+	server := flags.String("server", "", "ID or name of server")
+	statuses := flags.StringSlice("status", nil, "comma separated statuses (online, idle, dnd, offline, or status shown by Discord)")
+	since := flags.String("since", "", "start of time range: duration before now (eg: 24h, 7d), date (2006-01-02) or RFC 3339 time")
+	until := flags.String("until", "", "end of time range: duration before now (eg: 1h), date (2006-01-02, inclusive) or RFC 3339 time (exclusive)")
+	limit := flags.Int("limit", 0, "amount of most recent samples to print, 0 means all samples")
+	format := flags.String("format", "text", "format of output (text, csv, json or ndjson)")
+	queryColumns := flags.StringSlice("columns", nil, "comma separated columns of csv, json and ndjson output, in their order, all columns by default")
+	flags.Parse(args)
+
+	logger = newLogger(os.Stderr, levelInfo, false)
+
+	if len(*inputs) == 0 || (*format != "text" && *format != "csv" && *format != "json" && *format != "ndjson") {
+		fmt.Fprintln(os.Stderr, "Usage: scrapper query --input <file> [--user <name|id>] [--server <server>] [--status online] [--since 24h] [--until <time>] [--limit <n>] [--format text|csv|json|ndjson]")
+		flags.PrintDefaults()
+		return 1
+	}
+
+	var err error
+	outputColumns, err = parseColumns(*queryColumns)
+	if err != nil {
+		logger.Errorf("Couldn't parse columns: %v", err)
+		return 1
+	}
+
+	q := sampleQuery{server: *server}
+	q.users, err = compileUserPatterns(splitUserValues(*users))
+	if err != nil {
+		logger.Errorf("Couldn't parse users: %v", err)
+		return 1
+	}
+	for _, status := range *statuses {
+		q.statuses = append(q.statuses, alertStatus(status))
+	}
+
+	now := time.Now()
+	if *since != "" {
+		q.since, err = parseQueryTime(*since, now, false)
+		if err != nil {
+			logger.Errorf("Couldn't parse --since: %v", err)
+			return 1
+		}
+	}
+	if *until != "" {
+		q.until, err = parseQueryTime(*until, now, true)
+		if err != nil {
+			logger.Errorf("Couldn't parse --until: %v", err)
+			return 1
+		}
+	}
+
+	stored, err := readAnalyzedUsers(*inputs, "")
+	if err != nil {
+		logger.Errorf("Couldn't read users: %v", err)
+		return 1
+	}
+
+	samples := querySamples(stored, q, *limit)
+
+	if *format == "text" {
+		err = writeSamplesTable(os.Stdout, samples)
+	} else {
+		var writer recordWriter
+		writer, err = newFormatRecordWriter(*format, os.Stdout, true)
+		if err == nil {
+			err = writer.Write(samples)
+		}
+	}
+	if err != nil {
+		logger.Errorf("Couldn't write samples: %v", err)
+		return 1
+	}
+
+	return 0
+}
+
+// writeSamplesTable writes users as table aligned with spaces, time is shown in local time
+func writeSamplesTable(w io.Writer, users []User) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tSERVER\tUSERNAME\tID\tSTATUS\tACTIVITY")
+	for _, user := range users {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", user.StatusTime.Local().Format("2006-01-02 15:04:05"), user.Server,
+			user.Username, user.ID, user.Status, user.Activity)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// prunableStore is implemented by databases, whose old samples can be removed. Samples are passed to archive before
+// they are removed, if it isn't nil.
+type prunableStore interface {
+	PruneSamples(before time.Time, archive func(users []User) error) (int64, error)
+}
+
+// retentionPolicy removes samples older than window from stores every interval, so databases of long running
+// tool don't grow without limit
+type retentionPolicy struct {
+	stores     map[string]prunableStore // name of store -> store
+	window     time.Duration
+	every      time.Duration
+	archiveDir string // directory, where removed samples are archived, they aren't archived if it's empty
+	last       time.Time
+}
+
+// newRetentionPolicy creates policy, that doesn't remove anything until stores are added
+func newRetentionPolicy(window, every time.Duration, archiveDir string) *retentionPolicy {
+	return &retentionPolicy{stores: make(map[string]prunableStore), window: window, every: every, archiveDir: archiveDir}
+}
+
+// add adds store, whose samples are removed, name is used in log and name of archive
+func (p *retentionPolicy) add(name string, store prunableStore) {
+	p.stores[name] = store
+}
+
+// prune removes old samples from stores, if interval passed since previous pruning, errors are logged, so
+// scrapping isn't stopped by them
+func (p *retentionPolicy) prune(now time.Time) {
+	if p.window <= 0 || len(p.stores) == 0 || now.Sub(p.last) < p.every {
+		return
+	}
+	p.last = now
+
+	before := now.Add(-p.window).UTC()
+	for name, store := range p.stores {
+		var archive func([]User) error
+		if p.archiveDir != "" {
+			path := filepath.Join(p.archiveDir, fmt.Sprintf("%s-%s.csv.gz", name, before.Format("20060102T150405Z")))
+			archive = func(users []User) error {
+				return archiveUsers(path, users)
+			}
+		}
+
+		removed, err := store.PruneSamples(before, archive)
+		if err != nil {
+			logger.Errorf("Couldn't remove old samples from %s database: %v", name, err)
+			metrics.incError("output")
+			continue
+		}
+
+		logger.Infof("Removed %d samples older than %s from %s database", removed, before.Local().Format(timeFormat), name)
+	}
+}
+
+// archiveUsers writes users into compressed csv file with all columns, so it can be read by export and analyze
+// subcommands. Nothing is written, if there are no users.
+func archiveUsers(path string, users []User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating archive: %w", err)
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	err = writeUsersCSV(gz, users)
+	if err != nil {
+		return fmt.Errorf("writing archive: %w", err)
+	}
+
+	err = gz.Close()
+	if err != nil {
+		return fmt.Errorf("compressing archive: %w", err)
+	}
+
+	return file.Close()
+}
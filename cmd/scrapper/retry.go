@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// transientErrors are parts of messages of errors, that happen while Discord renders page, element is found or
+// becomes usable after a while then. Selenium and chromedp report them differently, so both are listed.
+var transientErrors = []string{
+	"no such element",
+	"stale element reference",
+	"element click intercepted",
+	"element not interactable",
+	"node is detached from document",
+	"could not find node with given id",
+	"cannot find context with specified id",
+}
+
+// fatalErrors are parts of messages of errors, that mean browser or its session is gone, so retrying doesn't help
+var fatalErrors = []string{
+	"invalid session id",
+	"no such window",
+	"session deleted",
+	"chrome not reachable",
+	"connection refused",
+	"target closed",
+}
+
+// isTransientError checks if err is caused by page, that isn't rendered yet, so action can be retried
+func isTransientError(err error) bool {
+	return err != nil && !isFatalError(err) && containsErrorMessage(err, transientErrors)
+}
+
+// isFatalError checks if err is caused by stopped tool or lost browser, then nothing else can be scrapped with
+// the same browser
+func isFatalError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return true
+	}
+
+	return containsErrorMessage(err, fatalErrors)
+}
+
+// containsErrorMessage checks if message of err contains any of messages, case is ignored
+func containsErrorMessage(err error, messages []string) bool {
+	msg := strings.ToLower(err.Error())
+	for _, m := range messages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retry calls fn again while it fails with transient error, at most as many times as user allowed, delay between
+// retries doubles each time. Other errors are returned at once.
+func retry(ctx context.Context, action string, fn func() error) error {
+	delay := *elementRetryDelay
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= *elementRetries || !isTransientError(err) {
+			return err
+		}
+
+		logger.Warnf("%s: %v, retrying in %s", action, err, delay)
+		if !sleepContext(ctx, delay) {
+			return ctx.Err()
+		}
+		delay *= 2
+	}
+}
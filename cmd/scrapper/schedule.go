@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// jitterRand is a source of random jitter, it's seeded with start time, so instances started together get
+// different delays
+var jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// randomJitter returns random duration in [0, max) range, or zero if max isn't positive
+func randomJitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+
+	return time.Duration(jitterRand.Int63n(int64(max)))
+}
+
+// sleepContext sleeps for d, it returns false if ctx is canceled before that
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
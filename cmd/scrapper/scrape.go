@@ -0,0 +1,651 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a Discord server to scrap data from, it's found either by ID or by name
+type Server struct {
+	ID   string
+	Name string
+}
+
+// String returns server ID, or name if server is found by name
+func (s Server) String() string {
+	if s.ID != "" {
+		return s.ID
+	}
+
+	return s.Name
+}
+
+// servers returns all servers supplied by user, servers supplied by ID go first
+func servers() []Server {
+	servers := make([]Server, 0, len(*discordServerIDs)+len(*discordServerNames))
+	for _, id := range *discordServerIDs {
+		servers = append(servers, Server{ID: id})
+	}
+	for _, name := range *discordServerNames {
+		servers = append(servers, Server{Name: name})
+	}
+
+	return servers
+}
+
+// Channel is a channel of Discord server, whose member list is scrapped, it's found either by ID or by name.
+// Zero Channel means channel that Discord opens by default.
+type Channel struct {
+	ID   string
+	Name string
+}
+
+// String returns channel ID, or name if channel is found by name
+func (c Channel) String() string {
+	if c.ID != "" {
+		return c.ID
+	}
+
+	return c.Name
+}
+
+// channels returns all channels supplied by user, or only default channel if user didn't supply any
+func channels() []Channel {
+	channels := make([]Channel, 0, len(*discordChannelIDs)+len(*discordChannelNames))
+	for _, id := range *discordChannelIDs {
+		channels = append(channels, Channel{ID: id})
+	}
+	for _, name := range *discordChannelNames {
+		channels = append(channels, Channel{Name: name})
+	}
+
+	if len(channels) == 0 {
+		channels = append(channels, Channel{})
+	}
+
+	return channels
+}
+
+// openMemberList opens server and its channel, and then populates right member bar
+func openMemberList(driver Driver, server Server, channel Channel) error {
+	err := openServer(driver, server)
+	if err != nil {
+		return err
+	}
+
+	if channel != (Channel{}) {
+		err = openChannel(driver, channel)
+		if err != nil {
+			return err
+		}
+	}
+
+	// member list toggle remembers its state, so it's clicked only if member list isn't shown already,
+	// otherwise it would hide it
+	_, err = findElement(driver, selectors.MemberList)
+	if err == nil {
+		return nil
+	}
+
+	//select member button to populate right member bar
+
+	membersLink, err := findElement(driver, selectors.MembersToggle)
+	if err != nil {
+		return fmt.Errorf("finding members link: %w", err)
+	}
+
+	err = membersLink.Click()
+	if err != nil {
+		return fmt.Errorf("clicking members link: %w", err)
+	}
+
+	time.Sleep(2 * time.Second) // wait until clicked server is loaded
+
+	// make sure that clicked button really opened member list
+	_, err = findElement(driver, selectors.MemberList)
+	if err != nil {
+		return fmt.Errorf("member list isn't shown after clicking members link: %w", err)
+	}
+
+	return nil
+}
+
+// openServer clicks server link
+func openServer(driver Driver, server Server) error {
+	// find and click server link
+	if server.Name != "" { // find by name
+		serverLink, err := findElement(driver, selectors.ServerLinkByName.format(server.Name))
+		if err != nil {
+			return fmt.Errorf("finding server link: %w", err)
+		}
+
+		err = serverLink.Click()
+		if err != nil {
+			return fmt.Errorf("clicking server link: %w", err)
+		}
+	} else { // find by id
+		serverLink, err := findElement(driver, selectors.ServerLinkByID.format(server.ID))
+		if err != nil {
+			return fmt.Errorf("finding server link: %w", err)
+		}
+
+		err = serverLink.Click()
+		if err != nil {
+			return fmt.Errorf("clicking server link: %w", err)
+		}
+	}
+
+	time.Sleep(2 * time.Second) // wait until clicked server is loaded
+
+	return nil
+}
+
+// openChannel clicks channel link in left channel bar of opened server
+func openChannel(driver Driver, channel Channel) error {
+	selector := selectors.ChannelLinkByID.format(channel.ID)
+	if channel.Name != "" {
+		selector = selectors.ChannelLinkByName.format(channel.Name)
+	}
+
+	channelLink, err := findElement(driver, selector)
+	if err != nil {
+		return fmt.Errorf("finding channel link: %w", err)
+	}
+
+	err = channelLink.Click()
+	if err != nil {
+		return fmt.Errorf("clicking channel link: %w", err)
+	}
+
+	time.Sleep(2 * time.Second) // wait until clicked channel is loaded
+
+	return nil
+}
+
+// scrapAll scraps member lists of all servers and channels supplied by user, if some member list can't be
+// scrapped, then it's skipped. Servers are scrapped concurrently, each driver scraps one server at a time, and
+// users are returned in order of servers. If ctx is canceled, users scrapped so far are returned.
+//
+// Driver, whose browser session is lost (eg: browser crashed, or selenium server restarted), is replaced in
+// drivers with new logged in one, and server is scrapped again. Driver is nil, if it can't be replaced.
+func scrapAll(ctx context.Context, drivers []Driver) []User {
+	// member lists scrapped before tool crashed are skipped, their users are taken from state file
+	users := checkpoints.resume()
+
+	allServers := servers()
+	serverUsers := make([][]User, len(allServers))
+
+	// all servers are queued at once, so they are taken by other drivers, if some driver stops
+	jobs := make(chan int, len(allServers))
+	for i := range allServers {
+		jobs <- i
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for w := range drivers {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for i := range jobs {
+				users, err := scrapServer(ctx, drivers[w], allServers[i])
+				if err != nil && ctx.Err() == nil {
+					// session is recovered once per server, so server, that crashes browser, doesn't do it forever
+					logger.Warnf("Browser session is lost, starting new one: %v", err)
+					drivers[w] = prepareDriver(ctx, drivers[w], w)
+					if drivers[w] == nil {
+						// servers of lost browser are left to other drivers
+						serverUsers[i] = users
+						return
+					}
+
+					// member lists scrapped before session was lost are skipped, if they are saved in state file,
+					// otherwise whole server is scrapped again, so users aren't duplicated
+					if checkpoints != nil {
+						var more []User
+						more, err = scrapServer(ctx, drivers[w], allServers[i])
+						users = append(users, more...)
+					} else {
+						users, err = scrapServer(ctx, drivers[w], allServers[i])
+					}
+				}
+				serverUsers[i] = users
+				if err != nil {
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// servers, that weren't taken by any driver, aren't scrapped in this scrapping process
+	if skipped := len(jobs); skipped > 0 && ctx.Err() == nil {
+		logger.Errorf("%d servers aren't scrapped, as all browsers are stopped", skipped)
+		metrics.incError("scrap")
+	}
+
+	for _, su := range serverUsers {
+		users = append(users, su...)
+	}
+
+	return users
+}
+
+// scrapServer scraps member lists of server, either its members page, or all channels supplied by user. Error is
+// returned only if browser can't be used anymore, with users of member lists scrapped so far, as unfinished one
+// is scrapped again, other errors are logged and member list is skipped.
+func scrapServer(ctx context.Context, driver Driver, server Server) ([]User, error) {
+	users := make([]User, 0)
+	serverLogger := logger.With("server", server)
+
+	// members page contains whole server roster, so there is no need to open channels
+	if *discordMembersPage {
+		list := server.String() + "/members"
+		if checkpoints.isDone(list) {
+			return users, nil
+		}
+
+		usernameStatuses, err := scrapServerMembersPage(ctx, driver, server)
+		if ctx.Err() != nil {
+			serverLogger.Warnf("Scrapping members page is interrupted")
+			return collectUsers(users, usernameStatuses), ctx.Err()
+		}
+		if err != nil {
+			serverLogger.Errorf("Scrapping members page: %v", err)
+			metrics.incError("scrap")
+			if isFatalError(err) {
+				return users, err
+			}
+			return users, nil
+		}
+
+		users = collectUsers(users, usernameStatuses)
+		checkpoints.listDone(list, users)
+		serverLogger.With("users", len(users)).Infof("Scrapping is done !")
+		return users, nil
+	}
+
+	for _, channel := range channels() {
+		list := memberListName(server, channel)
+		if checkpoints.isDone(list) {
+			continue
+		}
+		listLogger := serverLogger.With("channel", channel)
+
+		// open server, its channel and member list
+		err := retry(ctx, "Opening member list", func() error {
+			return openMemberList(driver, server, channel)
+		})
+		if ctx.Err() != nil {
+			return users, ctx.Err()
+		}
+		if err != nil {
+			listLogger.Errorf("Opening member list: %v", err)
+			metrics.incError("scrap")
+			if isFatalError(err) {
+				return users, err
+			}
+			continue
+		}
+
+		// scrap user data using right bar
+		listLogger.Infof("Scrapping user data in progress...")
+		usernameStatuses, err := scrapUsersWithCoverage(ctx, driver, server, channel)
+		if ctx.Err() != nil {
+			listLogger.Warnf("Scrapping user data is interrupted")
+			return collectUsers(users, usernameStatuses), ctx.Err()
+		}
+		if err != nil {
+			listLogger.Errorf("Scrapping user data: %v", err)
+			metrics.incError("scrap")
+			if isFatalError(err) {
+				return users, err
+			}
+			continue
+		}
+
+		listUsers := collectUsers(make([]User, 0), usernameStatuses)
+		checkpoints.listDone(list, listUsers)
+		listLogger.With("users", len(listUsers)).Infof("Scrapping is done !")
+		users = append(users, listUsers...)
+	}
+
+	return users, nil
+}
+
+// memberListName returns name of member list of server's channel, it's used in state file
+func memberListName(server Server, channel Channel) string {
+	return server.String() + "/" + channel.String()
+}
+
+// collectUsers adds scrapped users, that pass filters, to users, and downloads their avatars
+func collectUsers(users []User, usernameStatuses map[string]User) []User {
+	// save avatars, so their changes can be tracked
+	if *pathToAvatarsDir != "" {
+		err := downloadAvatars(usernameStatuses, *pathToAvatarsDir)
+		if err != nil {
+			logger.Errorf("Couldn't download avatars: %v", err)
+		}
+	}
+
+	for _, v := range usernameStatuses {
+		if keepUser(v) {
+			users = append(users, v)
+		}
+	}
+
+	return users
+}
+
+// scrapUsersWithCoverage scraps member list and checks if amount of scrapped users covers amount of members
+// displayed in role section headers, if coverage is below threshold, then warning is logged and member list is
+// scrapped again with doubled amount of scrolls, as many times as user allowed
+func scrapUsersWithCoverage(ctx context.Context, driver Driver, server Server, channel Channel) (map[string]User, error) {
+	maxScrolls := *discordServerMaxScrolls
+	listLogger := logger.With("server", server, "channel", channel)
+	for attempt := 0; ; attempt++ {
+		usernameStatuses, expected, err := scrapUsers(ctx, driver, server, channel, maxScrolls)
+		if err != nil {
+			return usernameStatuses, err
+		}
+
+		// headers don't contain counts, so there is nothing to compare with, and in watchlist mode
+		// scrolling stops before whole list is scrapped
+		if expected == 0 || len(watchPatterns) > 0 {
+			return usernameStatuses, nil
+		}
+
+		coverage := float64(len(usernameStatuses)) / float64(expected) * 100
+		if coverage >= *minCoverage {
+			listLogger.With("users", len(usernameStatuses), "expected", expected).Infof("Scrapped %d of %d members (%.1f%%)", len(usernameStatuses), expected, coverage)
+			return usernameStatuses, nil
+		}
+
+		listLogger.With("users", len(usernameStatuses), "expected", expected).Warnf("Scrapped only %d of %d members (%.1f%%), which is below %.1f%%", len(usernameStatuses), expected, coverage, *minCoverage)
+		if attempt >= *coverageRetries {
+			return usernameStatuses, nil
+		}
+
+		// open member list again, so it's scrolled to top
+		maxScrolls *= 2
+		listLogger.Infof("Scrapping again with %d scrolls", maxScrolls)
+		err = retry(ctx, "Opening member list", func() error {
+			return openMemberList(driver, server, channel)
+		})
+		if err != nil {
+			return usernameStatuses, err
+		}
+	}
+}
+
+// membersCount returns amount of members displayed in role section headers
+func membersCount(groupCounts map[string]int) int {
+	count := 0
+	for _, c := range groupCounts {
+		count += c
+	}
+
+	return count
+}
+
+// scrapUsers collects all usernames and statuses from right member bar of opened server, doing at most maxScrolls
+// scrolls. It also returns amount of members displayed in role section headers, to check if all users were
+// scrapped, it's 0 if headers don't contain counts. Users scrapped before error are returned with it.
+func scrapUsers(ctx context.Context, driver Driver, server Server, channel Channel, maxScrolls int) (map[string]User, int, error) {
+	usernameStatuses := make(map[string]User, 0) // collect all usernames and statuses into map
+	// so basically here, we iterate through right bar of Discord, where all users are located
+	// because of lazy loading, we scroll by 500px after each iteration and then
+	// add new and old users to map
+	var lastScrollTop interface{}       // scrollTop of right bar after previous scroll
+	groupCounts := make(map[string]int) // members counts of role sections
+	ownUserSkipped := false
+
+	// member list is scrolled to position, where it was, when tool crashed
+	list := memberListName(server, channel)
+	resumeScrollTop, resumedUsers := checkpoints.listProgress(list)
+	for k, v := range resumedUsers {
+		usernameStatuses[k] = v
+	}
+	if resumeScrollTop > 0 {
+		logger.Infof("Resuming member list from scroll position %.0f with %d users", resumeScrollTop, len(resumedUsers))
+		err := scrollMemberList(driver, resumeScrollTop)
+		if err != nil {
+			return usernameStatuses, 0, err
+		}
+	}
+
+	listProgress := newScrapProgress(server, channel, maxScrolls)
+	defer listProgress.finish()
+
+	i := 0
+	for i < maxScrolls {
+		// session can expire in the middle of scrapping, then Discord redirects to login page
+		err := ensureSession(ctx, driver, server, channel)
+		if err != nil {
+			return usernameStatuses, 0, err
+		}
+
+		// rows are re-rendered while member list is loaded, so they are searched again if they aren't there yet
+		var layoutElems []Element
+		err = retry(ctx, "Finding user layouts", func() error {
+			var err error
+			layoutElems, err = findElements(driver, selectors.MemberRow)
+			return err
+		})
+		if err != nil {
+			return usernameStatuses, 0, fmt.Errorf("finding user layouts: %w", err)
+		}
+
+		err = memberGroupCounts(driver, groupCounts)
+		if err != nil {
+			return usernameStatuses, 0, err
+		}
+
+		for _, layout := range layoutElems {
+			var username, status, userType string
+
+			// find avatar class, username and status are contained here
+			user, err := findElement(layout, selectors.MemberAvatar)
+			if err != nil {
+				//logger.Printf("Finding user icons: %v\n", err)
+				continue
+			}
+
+			// find content class, bot account names are container here
+			_, err = findElement(layout, selectors.MemberBotTag)
+			if err != nil { // if error happened then type is user
+				userType = "user"
+			} else { // else type is bot
+				userType = "bot"
+			}
+
+			// retrieve each username and status from aria-label attribute and avatar class
+			info, err := user.GetAttribute("aria-label")
+			if err != nil {
+				//logger.Printf("Getting status of user: %v\n", err)
+				continue
+			}
+
+			// if info doesn't contain ',', means user is offline
+			if strings.ContainsAny(info, ",") {
+				// separate username and status, eg: 'bejaneps, Online'
+				temp := strings.Split(info, ",")
+
+				username = temp[0]
+				status = temp[1][1:] // skip space
+			} else {
+				username = info
+				status = "Offline"
+			}
+
+			// if user supplied his/her username then omit it from output
+			if *discordUsername != "" {
+				if strings.EqualFold(*discordUsername, username) {
+					ownUserSkipped = true
+					continue
+				}
+			}
+
+			// either custom status or activity (playing, listening and etc) is shown under username
+			var customStatus, activity string
+			if subText := memberSubText(driver, layout); isActivity(subText) {
+				activity = subText
+			} else {
+				customStatus = subText
+			}
+
+			// aria-label contains only displayed name, which is either nickname or global username
+			nickname, globalUsername := memberNames(driver, layout)
+
+			// display names collide, so users are distinguished by ID if it was found
+			id := memberID(driver, layout)
+			key := id
+			if key == "" {
+				key = username
+			}
+
+			// add user to temporary map
+			usernameStatuses[key] = User{
+				ID:             id,
+				Server:         server.String(),
+				Channel:        channel.String(),
+				Username:       username,
+				Nickname:       nickname,
+				GlobalUsername: globalUsername,
+				Status:         status,
+				Type:           userType,
+				RoleGroup:      memberRoleGroup(driver, layout),
+				CustomStatus:   customStatus,
+				Activity:       activity,
+				AvatarURL:      memberAvatarURL(layout),
+				StatusTime:     Time{time.Now()},
+			}
+		}
+
+		// in watchlist mode there is no need to scroll further, once all watched users are located
+		if len(watchPatterns) > 0 && allWatchedFound(usernameStatuses) {
+			logger.Debugf("All watched users are located after %d scrolls", i)
+			break
+		}
+
+		// scroll right bar for 700px each iteration
+		if i > 0 {
+			// get right bar scroll element
+			var rightBar Element
+			err := retry(ctx, "Finding right scroll bar", func() error {
+				var err error
+				rightBar, err = findElement(driver, selectors.MemberList)
+				return err
+			})
+			if err != nil {
+				return usernameStatuses, 0, fmt.Errorf("finding right scroll bar: %w", err)
+			}
+
+			// remember rendered rows, so we can tell when new ones are rendered after scroll
+			rowsBefore, err := memberRowsSignature(driver)
+			if err != nil {
+				return usernameStatuses, 0, err
+			}
+
+			// scroll user icons to top by some amount of pixels
+			scrollTop, err := driver.ExecuteScript("arguments[0].scrollTop += 700; return arguments[0].scrollTop", []interface{}{rightBar})
+			if err != nil {
+				return usernameStatuses, 0, fmt.Errorf("scrolling window vertically: %w", err)
+			}
+
+			// if scroll position didn't change, then end of the list is reached and there is nothing left to scrap
+			if scrollTop == lastScrollTop {
+				logger.Debugf("Reached end of member list after %d scrolls", i)
+				break
+			}
+			lastScrollTop = scrollTop
+
+			// wait until virtualized list renders new rows
+			err = waitMemberRows(driver, rowsBefore)
+			if err != nil {
+				return usernameStatuses, 0, err
+			}
+
+			// users of rows above scroll position are collected already, so scrapping can be resumed from it
+			if top, ok := scrollTop.(float64); ok && i%checkpointScrolls == 0 {
+				checkpoints.scrolled(list, top, usernameStatuses)
+			}
+		}
+
+		i++
+		listProgress.update(i, len(usernameStatuses), membersCount(groupCounts))
+	}
+
+	expected := membersCount(groupCounts)
+
+	// own user is counted in headers, but isn't added to output
+	if ownUserSkipped && expected > 0 {
+		expected--
+	}
+
+	return usernameStatuses, expected, nil
+}
+
+// scrollMemberList scrolls member list to scrollTop position, and waits until its rows are rendered
+func scrollMemberList(driver Driver, scrollTop float64) error {
+	rightBar, err := findElement(driver, selectors.MemberList)
+	if err != nil {
+		return fmt.Errorf("finding right scroll bar: %w", err)
+	}
+
+	rowsBefore, err := memberRowsSignature(driver)
+	if err != nil {
+		return err
+	}
+
+	_, err = driver.ExecuteScript("arguments[0].scrollTop = arguments[1]", []interface{}{rightBar, scrollTop})
+	if err != nil {
+		return fmt.Errorf("scrolling window vertically: %w", err)
+	}
+
+	return waitMemberRows(driver, rowsBefore)
+}
+
+// memberRowsScript returns aria-labels of all rendered member rows, joined into one string
+const memberRowsScript = `var avatarSelector = arguments[1];
+return Array.from(document.querySelectorAll(arguments[0]))
+	.map(function(row) { var avatar = row.querySelector(avatarSelector); return avatar ? avatar.getAttribute("aria-label") : ""; })
+	.join("\n");`
+
+// memberRowsPollInterval is a delay between checks if new member rows were rendered
+const memberRowsPollInterval = 50 * time.Millisecond
+
+// memberRowsSignature returns signature of currently rendered member rows, it changes when list renders other rows
+func memberRowsSignature(driver Driver) (string, error) {
+	signature, err := driver.ExecuteScript(memberRowsScript, []interface{}{selectors.MemberRow.css(), selectors.MemberAvatar.css()})
+	if err != nil {
+		return "", fmt.Errorf("getting rendered user layouts: %w", err)
+	}
+
+	s, _ := signature.(string)
+	return s, nil
+}
+
+// waitMemberRows polls member rows after scroll until they differ from rows rendered before scroll,
+// it waits at most for scroll refresh time specified by user
+func waitMemberRows(driver Driver, before string) error {
+	deadline := time.Now().Add(*discordServerScrollRefreshTime)
+	for time.Now().Before(deadline) {
+		time.Sleep(memberRowsPollInterval)
+
+		after, err := memberRowsSignature(driver)
+		if err != nil {
+			return err
+		}
+
+		if after != "" && after != before {
+			return nil
+		}
+	}
+
+	return nil
+}
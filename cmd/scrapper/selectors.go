@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/tebeka/selenium"
+)
+
+// SelectorChain is an ordered list of candidate selectors of one page element, they are tried one by one until
+// some of them matches, so a single class name change doesn't break the tool. Selectors starting with '/' are
+// XPath, all others are CSS.
+type SelectorChain []string
+
+// UnmarshalJSON accepts either a single selector or a list of them
+func (c *SelectorChain) UnmarshalJSON(data []byte) error {
+	var selector string
+	if err := json.Unmarshal(data, &selector); err == nil {
+		*c = SelectorChain{selector}
+		return nil
+	}
+
+	var chain []string
+	err := json.Unmarshal(data, &chain)
+	if err != nil {
+		return err
+	}
+	*c = chain
+
+	return nil
+}
+
+// format replaces '%s' in each selector with value
+func (c SelectorChain) format(value string) SelectorChain {
+	formatted := make(SelectorChain, len(c))
+	for i, selector := range c {
+		formatted[i] = fmt.Sprintf(selector, value)
+	}
+
+	return formatted
+}
+
+// css joins CSS selectors of chain into one selector list, so it can be used inside of page scripts,
+// XPath selectors are omitted
+func (c SelectorChain) css() string {
+	css := make([]string, 0, len(c))
+	for _, selector := range c {
+		if !strings.HasPrefix(selector, "/") {
+			css = append(css, selector)
+		}
+	}
+
+	return strings.Join(css, ", ")
+}
+
+// Selectors contains selector chains of all Discord page elements used by tool. Selectors used inside of page
+// scripts (member rows, sub text, role headers and members page) have to be CSS. Selectors of server and channel
+// links contain '%s', which is replaced with ID or name.
+type Selectors struct {
+	EmailField     SelectorChain `json:"email_field"`
+	PasswordField  SelectorChain `json:"password_field"`
+	SubmitButton   SelectorChain `json:"submit_button"`
+	TwoFactorField SelectorChain `json:"two_factor_field"`
+	CaptchaFrame   SelectorChain `json:"captcha_frame"`
+
+	ServerLinkByID    SelectorChain `json:"server_link_by_id"`
+	ServerLinkByName  SelectorChain `json:"server_link_by_name"`
+	ChannelLinkByID   SelectorChain `json:"channel_link_by_id"`
+	ChannelLinkByName SelectorChain `json:"channel_link_by_name"`
+	ServerLinks       SelectorChain `json:"server_links"`
+	ChannelLinks      SelectorChain `json:"channel_links"`
+
+	MembersToggle     SelectorChain `json:"members_toggle"`
+	MemberList        SelectorChain `json:"member_list"`
+	MemberRow         SelectorChain `json:"member_row"`
+	MemberAvatar      SelectorChain `json:"member_avatar"`
+	MemberAvatarImage SelectorChain `json:"member_avatar_image"`
+	MemberBotTag      SelectorChain `json:"member_bot_tag"`
+	MemberSubText     SelectorChain `json:"member_sub_text"`
+	MemberGroupHeader SelectorChain `json:"member_group_header"`
+
+	ServerHeader        SelectorChain `json:"server_header"`
+	ServerSettingsItem  SelectorChain `json:"server_settings_item"`
+	MembersTab          SelectorChain `json:"members_tab"`
+	MembersPageRow      SelectorChain `json:"members_page_row"`
+	MembersPageName     SelectorChain `json:"members_page_name"`
+	MembersPageUsername SelectorChain `json:"members_page_username"`
+	MembersPageJoinedAt SelectorChain `json:"members_page_joined_at"`
+	MembersPageScroller SelectorChain `json:"members_page_scroller"`
+}
+
+// defaultSelectors are built-in selectors, they are used when user doesn't supply selectors file. Class names go
+// first, and then fallbacks based on ids, aria-labels and roles, which change less often.
+var defaultSelectors = Selectors{
+	EmailField:     SelectorChain{`//*[@id="uid_5"]`, `input[name="email"]`, `input[type="email"]`, `input[aria-label*="Email"]`},
+	PasswordField:  SelectorChain{`//*[@id="uid_7"]`, `input[name="password"]`, `input[type="password"]`, `input[aria-label*="Password"]`},
+	SubmitButton:   SelectorChain{`button[type="submit"]`, `form button[class*="button"]`},
+	TwoFactorField: SelectorChain{`input[autocomplete="one-time-code"]`, `input[placeholder*="6-digit"]`, `input[aria-label*="Code"]`},
+	CaptchaFrame:   SelectorChain{`iframe[src*="hcaptcha"]`, `iframe[title*="hCaptcha"]`},
+
+	ServerLinkByID:    SelectorChain{`div[data-list-item-id="guildsnav___%s"]`, `a[href^="/channels/%s/"]`},
+	ServerLinkByName:  SelectorChain{`div[aria-label*="%s"]`, `nav [role="treeitem"][aria-label*="%s"]`},
+	ChannelLinkByID:   SelectorChain{`a[data-list-item-id="channels___%s"]`, `a[href$="/%s"]`},
+	ChannelLinkByName: SelectorChain{`a[data-list-item-id^="channels___"][aria-label^="%s ("]`}, // aria-label looks like: 'general (text channel)'
+	ServerLinks:       SelectorChain{`div[data-list-item-id^="guildsnav___"]`},
+	ChannelLinks:      SelectorChain{`a[data-list-item-id^="channels___"]`},
+
+	// toolbar buttons get reordered, so members toggle is found by its aria-label, in English and other popular locales
+	MembersToggle: SelectorChain{
+		`[aria-label="Show Member List"]`,
+		`[aria-label="Mitgliederliste anzeigen"]`,
+		`[aria-label="Afficher la liste des membres"]`,
+		`[aria-label="Mostrar lista de miembros"]`,
+		`[aria-label="Mostrar lista de membros"]`,
+		`[aria-label="Mostra elenco membri"]`,
+		`[aria-label="Показать список участников"]`,
+		`[aria-label="Pokaż listę członków"]`,
+	},
+	MemberList: SelectorChain{
+		`div.appMount-2yBXZl div.app-3xd6d0 div.container-1eFtFS div.base-2jDfDU div.content-1SgpWY div.chat-2ZfjoI div.content-1jQy2l div.container-2o3qEW aside.membersWrap-3NUR2t div.scrollerBase-1Pkza4`,
+		`aside[class*="membersWrap"] div[class*="scroller"]`,
+		`div[role="list"][aria-label^="Members"]`,
+	},
+	MemberRow:         SelectorChain{`div[class*="member"] > div[class*="layout"]`, `div[role="listitem"][data-list-item-id^="members-"] > div`},
+	MemberAvatar:      SelectorChain{`div[class*="avatar"] > div[class*="wrapper"]`, `div[role="img"][aria-label]`},
+	MemberAvatarImage: SelectorChain{`div[class*="avatar"] img`, `img[src*="/avatars/"]`},
+	MemberBotTag:      SelectorChain{`div[class*="content"] > div[class*="nameAndDecorators"] > span[class*="botTag"]`, `span[class*="botTag"]`},
+	MemberSubText:     SelectorChain{`div[class*="subText"]`},
+	MemberGroupHeader: SelectorChain{`h3[class*="membersGroup"]`, `div[role="list"] h3[aria-label]`},
+
+	ServerHeader:        SelectorChain{`nav[class*="guilds"] ~ div header[class*="header"]`, `div[class*="sidebar"] header`},
+	ServerSettingsItem:  SelectorChain{`#guild-header-popout-settings`, `[role="menuitem"][id$="-settings"]`},
+	MembersTab:          SelectorChain{`//div[@role="tab" and normalize-space(.)="Members"]`, `div[role="tab"][aria-label="Members"]`},
+	MembersPageRow:      SelectorChain{`div[class*="memberRow"]`},
+	MembersPageName:     SelectorChain{`[class*="name"]`},
+	MembersPageUsername: SelectorChain{`[class*="username"]`, `[class*="tag"]`},
+	MembersPageJoinedAt: SelectorChain{`[class*="joinedAt"]`, `[class*="memberSince"]`},
+	MembersPageScroller: SelectorChain{`[class*="scroller"]`},
+}
+
+// selectors are selectors used by tool, they are default ones, overridden by user's selectors file
+var selectors = defaultSelectors
+
+// loadSelectors reads selectors profile in .json, .yaml or .toml format (by its extension, like config file),
+// selectors that aren't present in it keep default values, so profile may contain only changed ones
+func loadSelectors(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading selectors file: %w", err)
+	}
+
+	values, err := decodeConfig(path, data)
+	if err != nil {
+		return fmt.Errorf("parsing selectors file: %w", err)
+	}
+
+	// values are decoded into selectors as json, so each of them can be a single selector or a list
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("parsing selectors file: %w", err)
+	}
+
+	loaded := defaultSelectors
+	err = json.Unmarshal(encoded, &loaded)
+	if err != nil {
+		return fmt.Errorf("parsing selectors file: %w", err)
+	}
+
+	selectors = loaded
+	return nil
+}
+
+// elementFinder is implemented by both Driver and Element
+type elementFinder interface {
+	FindElement(by, value string) (Element, error)
+	FindElements(by, value string) ([]Element, error)
+}
+
+// selectorBy returns selenium's strategy for selector, XPath for selectors starting with '/', CSS for others
+func selectorBy(selector string) string {
+	if strings.HasPrefix(selector, "/") {
+		return selenium.ByXPATH
+	}
+
+	return selenium.ByCSSSelector
+}
+
+var (
+	matchedSelectorsMu sync.Mutex
+	matchedSelectors   = make(map[string]string) // first selector of chain -> last matched selector
+)
+
+// logMatchedSelector logs which selector of chain matched, only when it differs from previous match,
+// so lookups done for every member row don't flood the log
+func logMatchedSelector(chain SelectorChain, selector string) {
+	matchedSelectorsMu.Lock()
+	defer matchedSelectorsMu.Unlock()
+
+	if matchedSelectors[chain[0]] == selector {
+		return
+	}
+	matchedSelectors[chain[0]] = selector
+
+	logger.Debugf("Selector %q matched (candidate of %q)", selector, chain[0])
+}
+
+// findElement finds first element matching some selector of chain, selectors are tried in order
+func findElement(f elementFinder, chain SelectorChain) (Element, error) {
+	err := errors.New("selector chain is empty")
+	for _, selector := range chain {
+		var elem Element
+		elem, err = f.FindElement(selectorBy(selector), selector)
+		if err == nil {
+			logMatchedSelector(chain, selector)
+			return elem, nil
+		}
+	}
+
+	return nil, err
+}
+
+// findElements finds all elements matching first selector of chain, that matches anything
+func findElements(f elementFinder, chain SelectorChain) ([]Element, error) {
+	err := errors.New("selector chain is empty")
+	for _, selector := range chain {
+		var elems []Element
+		elems, err = f.FindElements(selectorBy(selector), selector)
+		if err == nil && len(elems) > 0 {
+			logMatchedSelector(chain, selector)
+			return elems, nil
+		}
+	}
+
+	return nil, err
+}
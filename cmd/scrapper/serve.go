@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// dashboardTemplate is a page with last status of each user and report of their activity
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<title>Discord User Monitor</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 4px 12px; border-bottom: 1px solid #ddd; text-align: left; }
+.Online { color: #23a55a; } .Idle { color: #f0b232; } .Do.Not.Disturb { color: #f23f43; } .Offline { color: #80848e; }
+</style>
+</head>
+<body>
+<h1>Discord User Monitor</h1>
+<p>{{len .}} users, page is refreshed every minute</p>
+<table>
+<tr><th>Server</th><th>Username</th><th>Status</th><th>Online</th><th>Changes</th><th>Last seen</th></tr>
+{{range .}}<tr><td>{{.Server}}</td><td>{{.Username}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{printf "%.1f" .OnlinePercent}}%</td><td>{{.Changes}}</td><td>{{.LastSeen.Format "2006-01-02 15:04"}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// dataServer serves users stored in output, they are read on each request, so running scrapper keeps adding them
+type dataServer struct {
+	inputs []string
+}
+
+// users reads users of all inputs
+func (s dataServer) users() ([]User, error) {
+	users := make([]User, 0)
+	for _, input := range s.inputs {
+		inputUsers, err := readUsers(input)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, inputUsers...)
+	}
+
+	return users, nil
+}
+
+// serveUsers responds with last status of each user, in json format
+func (s dataServer) serveUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := s.users()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	latest := latestUsers(users, r.URL.Query().Get("server"))
+	records := make([]userRecord, 0, len(latest))
+	for _, user := range latest {
+		records = append(records, userRecord(user))
+	}
+
+	writeJSON(w, http.StatusOK, records)
+}
+
+// latestUsers returns last sample of each user, of server only, if it isn't empty. Users are returned in order they
+// were seen first.
+func latestUsers(users []User, server string) []User {
+	latest := make(map[string]User)
+	keys := make([]string, 0)
+	for _, user := range users {
+		if server != "" && user.Server != server {
+			continue
+		}
+
+		key := user.Server + "/" + userKey(user)
+		last, ok := latest[key]
+		if !ok {
+			keys = append(keys, key)
+		} else if user.StatusTime.Before(last.StatusTime.Time) {
+			continue
+		}
+		latest[key] = user
+	}
+
+	result := make([]User, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, latest[key])
+	}
+
+	return result
+}
+
+// serveReport responds with report of each user's activity, in json format
+func (s dataServer) serveReport(w http.ResponseWriter, r *http.Request) {
+	users, err := s.users()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, analyzeUsers(users))
+}
+
+// serveDashboard responds with html page of users' activity
+func (s dataServer) serveDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	users, err := s.users()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// users are sorted by server and name, so they don't jump around, when page is refreshed
+	reports := analyzeUsers(users)
+	sort.SliceStable(reports, func(i, j int) bool {
+		if reports[i].Server != reports[j].Server {
+			return reports[i].Server < reports[j].Server
+		}
+		return reports[i].Username < reports[j].Username
+	})
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = dashboardTemplate.Execute(w, reports)
+	if err != nil {
+		logger.Errorf("Rendering dashboard: %v", err)
+	}
+}
+
+// serveCommand serves users stored in output over HTTP: dashboard on / path, last statuses on /api/users, report of
+// activity on /api/report and REST API on /v1/ paths, and broadcasts status changes of users to WebSocket clients
+// with --ws
+func serveCommand(args []string) int {
+	flags := pflag.NewFlagSet("serve", pflag.ExitOnError)
+	inputs := flags.StringArrayP("input", "i", nil, "path to file (csv, json or ndjson, optionally .gz) or SQLite database (sqlite://users.db), where users are read from, can be repeated")
+	addr := flags.String("listen", ":8080", "address where dashboard and API are served")
+	wsAddr := flags.String("ws", "", "address where status changes of users are broadcast to WebSocket clients as json messages, on /ws path, if it's the same as --listen")
+	wsPoll := flags.Duration("ws-poll", 10*time.Second, "interval, in which inputs are read to find status changes for WebSocket clients")
+	flags.Parse(args)
+
+	logger = newLogger(os.Stderr, levelInfo, false)
+
+	if len(*inputs) == 0 || *wsPoll <= 0 {
+		fmt.Fprintln(os.Stderr, "Usage: scrapper serve --input <file> [--listen <addr>] [--ws <addr>] [--ws-poll 10s]")
+		flags.PrintDefaults()
+		return 1
+	}
+
+	s := dataServer{inputs: *inputs}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.serveDashboard)
+	mux.HandleFunc("/api/users", s.serveUsers)
+	mux.HandleFunc("/api/report", s.serveReport)
+	mux.HandleFunc("/v1/servers/", s.serveAPIServers)
+	mux.HandleFunc("/v1/users/", s.serveAPIUsers)
+	mux.HandleFunc("/v1/stats", s.serveAPIStats)
+
+	if *wsAddr != "" {
+		broadcaster := newWSBroadcaster(s, *wsPoll)
+		go broadcaster.run()
+
+		if *wsAddr == *addr {
+			mux.Handle("/ws", broadcaster)
+		} else {
+			go func() {
+				logger.Infof("Serving WebSocket on %s", *wsAddr)
+				err := http.ListenAndServe(*wsAddr, broadcaster)
+				if err != nil {
+					logger.Errorf("Serving WebSocket: %v", err)
+					os.Exit(1)
+				}
+			}()
+		}
+	}
+
+	logger.Infof("Serving dashboard on %s", *addr)
+	err := http.ListenAndServe(*addr, mux)
+	if err != nil {
+		logger.Errorf("Serving dashboard: %v", err)
+		return 1
+	}
+
+	return 0
+}
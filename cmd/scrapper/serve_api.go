@@ -0,0 +1,242 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiError is a body of error response of REST API
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// membersResponse is a last status of each member of server
+type membersResponse struct {
+	Server  string       `json:"server"`
+	Members int          `json:"members"` // amount of users, that were ever seen
+	Online  int          `json:"online"`  // amount of users, whose last status isn't offline
+	Users   []userRecord `json:"users"`
+}
+
+// historyResponse is a history of user's samples
+type historyResponse struct {
+	User    string       `json:"user"`
+	Samples []userRecord `json:"samples"`
+}
+
+// serverStats are totals of server's samples, and its last scrapping process
+type serverStats struct {
+	Server      string    `json:"server"`
+	Users       int       `json:"users"`   // amount of users, that were ever seen
+	Samples     int       `json:"samples"` // amount of stored samples
+	Snapshots   int       `json:"snapshots"`
+	LastMembers int       `json:"last_members"` // amount of users in last scrapping process
+	LastOnline  int       `json:"last_online"`  // amount of users online in last scrapping process
+	LastTime    time.Time `json:"last_time"`
+}
+
+// statsResponse are totals of all stored samples
+type statsResponse struct {
+	Users     int           `json:"users"` // amount of users, that were ever seen, user of several servers is counted on each
+	Samples   int           `json:"samples"`
+	FirstTime *time.Time    `json:"first_time"`
+	LastTime  *time.Time    `json:"last_time"`
+	Servers   []serverStats `json:"servers"`
+}
+
+// writeAPIError responds with error of REST API in json format
+func writeAPIError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, apiError{Error: message})
+}
+
+// apiPath checks that request is GET, and returns segments of its path after prefix, eg: [123 members] for
+// /v1/servers/123/members, error is written, if request doesn't match
+func apiPath(w http.ResponseWriter, r *http.Request, prefix string, segments int) ([]string, bool) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, "only GET requests are supported")
+		return nil, false
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/"), "/")
+	if len(parts) != segments || parts[0] == "" {
+		writeAPIError(w, http.StatusNotFound, "unknown path "+r.URL.Path)
+		return nil, false
+	}
+
+	return parts, true
+}
+
+// serveAPIServers responds with last status of each member of server on /v1/servers/{id}/members, server is found
+// by ID or name, like it's stored
+func (s dataServer) serveAPIServers(w http.ResponseWriter, r *http.Request) {
+	parts, ok := apiPath(w, r, "/v1/servers/", 2)
+	if !ok {
+		return
+	}
+	if parts[1] != "members" {
+		writeAPIError(w, http.StatusNotFound, "unknown path "+r.URL.Path)
+		return
+	}
+
+	users, err := s.users()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	server := parts[0]
+	latest := latestUsers(users, server)
+	if len(latest) == 0 {
+		writeAPIError(w, http.StatusNotFound, "server "+server+" isn't found")
+		return
+	}
+
+	resp := membersResponse{Server: server, Members: len(latest), Users: make([]userRecord, 0, len(latest))}
+	for _, user := range latest {
+		if user.Status != "Offline" {
+			resp.Online++
+		}
+		resp.Users = append(resp.Users, userRecord(user))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// serveAPIUsers responds with samples of user sorted by time on /v1/users/{id}/history, user is found by ID or name.
+// Samples are filtered with query parameters: since and until (duration before now, date or RFC 3339 time), server,
+// status (comma separated) and limit (amount of most recent samples).
+func (s dataServer) serveAPIUsers(w http.ResponseWriter, r *http.Request) {
+	parts, ok := apiPath(w, r, "/v1/users/", 2)
+	if !ok {
+		return
+	}
+	if parts[1] != "history" {
+		writeAPIError(w, http.StatusNotFound, "unknown path "+r.URL.Path)
+		return
+	}
+
+	params := r.URL.Query()
+	q := sampleQuery{server: params.Get("server")}
+	if params.Get("status") != "" {
+		q.statuses = strings.Split(params.Get("status"), ",")
+	}
+
+	var err error
+	now := time.Now()
+	if params.Get("since") != "" {
+		q.since, err = parseQueryTime(params.Get("since"), now, false)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "since: "+err.Error())
+			return
+		}
+	}
+	if params.Get("until") != "" {
+		q.until, err = parseQueryTime(params.Get("until"), now, true)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "until: "+err.Error())
+			return
+		}
+	}
+
+	limit := 0
+	if params.Get("limit") != "" {
+		limit, err = strconv.Atoi(params.Get("limit"))
+		if err != nil || limit < 0 {
+			writeAPIError(w, http.StatusBadRequest, "limit has to be a number, 0 or more")
+			return
+		}
+	}
+
+	users, err := s.users()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id := parts[0]
+	found := false
+	samples := make([]User, 0)
+	for _, user := range users {
+		if isUser(user, id) {
+			found = true
+			samples = append(samples, user)
+		}
+	}
+	if !found {
+		writeAPIError(w, http.StatusNotFound, "user "+id+" isn't found")
+		return
+	}
+
+	resp := historyResponse{User: id, Samples: make([]userRecord, 0)}
+	for _, user := range querySamples(samples, q, limit) {
+		resp.Samples = append(resp.Samples, userRecord(user))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// serveAPIStats responds with totals of stored samples on /v1/stats, and with totals and last scrapping process of
+// each server
+func (s dataServer) serveAPIStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAPIError(w, http.StatusMethodNotAllowed, "only GET requests are supported")
+		return
+	}
+
+	users, err := s.users()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp := statsResponse{Samples: len(users), Servers: make([]serverStats, 0)}
+	servers := make(map[string]*serverStats)
+	seen := make(map[string]bool)
+	for _, user := range users {
+		stats, ok := servers[user.Server]
+		if !ok {
+			stats = &serverStats{Server: user.Server}
+			servers[user.Server] = stats
+		}
+		stats.Samples++
+
+		key := user.Server + "/" + userKey(user)
+		if !seen[key] {
+			seen[key] = true
+			stats.Users++
+		}
+
+		t := user.StatusTime.Time
+		if resp.FirstTime == nil || t.Before(*resp.FirstTime) {
+			resp.FirstTime = &t
+		}
+		if resp.LastTime == nil || t.After(*resp.LastTime) {
+			resp.LastTime = &t
+		}
+	}
+	resp.Users = len(seen)
+
+	for _, snapshot := range splitSnapshots(users) {
+		stats := servers[snapshot.Server]
+		stats.Snapshots++
+		if !snapshot.Time.Before(stats.LastTime) {
+			stats.LastMembers = snapshot.Members
+			stats.LastOnline = snapshot.Online
+			stats.LastTime = snapshot.Time
+		}
+	}
+
+	for _, stats := range servers {
+		resp.Servers = append(resp.Servers, *stats)
+	}
+	sort.Slice(resp.Servers, func(i, j int) bool {
+		return resp.Servers[i].Server < resp.Servers[j].Server
+	})
+
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// wsClientBuffer is an amount of messages, that are queued for client, client, that doesn't read them in time, is
+// disconnected, so it doesn't slow down others
+const wsClientBuffer = 64
+
+// wsSnapshot is a first message sent to client, with last status of each user, so overlay shows who is online at
+// once, and then updates it with change events
+type wsSnapshot struct {
+	Type  string       `json:"type"` // always snapshot
+	Users []userRecord `json:"users"`
+}
+
+// wsClient is a connected WebSocket client with queue of its messages
+type wsClient struct {
+	conn     net.Conn
+	mu       sync.Mutex // guards writes to conn
+	messages chan []byte
+}
+
+// Read reads from connection, it's used by wsutil to read frames of client
+func (c *wsClient) Read(p []byte) (int, error) {
+	return c.conn.Read(p)
+}
+
+// Write writes to connection, it's used by wsutil to answer control frames, so they aren't mixed with messages
+func (c *wsClient) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.conn.Write(p)
+}
+
+// send writes message to connection as one text frame
+func (c *wsClient) send(message []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return wsutil.WriteServerText(c.conn, message)
+}
+
+// wsBroadcaster polls inputs, and sends status changes of users as json messages to all connected WebSocket clients
+type wsBroadcaster struct {
+	data     dataServer
+	interval time.Duration
+
+	mu      sync.Mutex
+	clients map[*wsClient]bool
+	latest  map[string]User // server and user -> last sample of user
+}
+
+// newWSBroadcaster creates broadcaster, that polls inputs of data every interval
+func newWSBroadcaster(data dataServer, interval time.Duration) *wsBroadcaster {
+	return &wsBroadcaster{
+		data:     data,
+		interval: interval,
+		clients:  make(map[*wsClient]bool),
+	}
+}
+
+// run polls inputs until process is stopped, first poll only learns last statuses, so old changes aren't sent
+func (b *wsBroadcaster) run() {
+	for {
+		events, err := b.poll()
+		if err != nil {
+			logger.Errorf("Couldn't read users for WebSocket clients: %v", err)
+		}
+
+		for _, event := range events {
+			b.broadcast(event)
+		}
+
+		time.Sleep(b.interval)
+	}
+}
+
+// poll reads inputs, and returns changes of last statuses of users since previous poll: status, when status of user
+// changed, and appeared, when user is seen first time
+func (b *wsBroadcaster) poll() ([]ChangeEvent, error) {
+	users, err := b.data.users()
+	if err != nil {
+		return nil, err
+	}
+
+	users = latestUsers(users, "")
+	latest := make(map[string]User, len(users))
+	for _, user := range users {
+		latest[user.Server+"/"+userKey(user)] = user
+	}
+
+	b.mu.Lock()
+	previous := b.latest
+	b.latest = latest
+	b.mu.Unlock()
+
+	events := make([]ChangeEvent, 0)
+	if previous == nil {
+		return events, nil
+	}
+	for _, user := range users {
+		last, ok := previous[user.Server+"/"+userKey(user)]
+		switch {
+		case !ok:
+			events = append(events, newChangeEvent(changeAppeared, user, "", user.Status, user.StatusTime.Time))
+		case last.Status != user.Status:
+			events = append(events, newChangeEvent(changeStatus, user, last.Status, user.Status, user.StatusTime.Time))
+		}
+	}
+
+	return events, nil
+}
+
+// broadcast queues message for all clients, clients with full queue are disconnected
+func (b *wsBroadcaster) broadcast(v interface{}) {
+	message, err := json.Marshal(v)
+	if err != nil {
+		logger.Errorf("Couldn't encode WebSocket message: %v", err)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for client := range b.clients {
+		select {
+		case client.messages <- message:
+		default:
+			logger.Warnf("WebSocket client %s is too slow, disconnecting it", client.conn.RemoteAddr())
+			b.remove(client)
+		}
+	}
+}
+
+// remove disconnects client, b.mu has to be locked
+func (b *wsBroadcaster) remove(client *wsClient) {
+	if !b.clients[client] {
+		return
+	}
+
+	delete(b.clients, client)
+	close(client.messages)
+	client.conn.Close()
+}
+
+// ServeHTTP upgrades connection to WebSocket, sends snapshot of last statuses to client, and then change events
+func (b *wsBroadcaster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, _, _, err := ws.UpgradeHTTP(r, w)
+	if err != nil {
+		logger.Errorf("Couldn't upgrade connection to WebSocket: %v", err)
+		return
+	}
+	logger.Infof("WebSocket client %s is connected", conn.RemoteAddr())
+
+	client := &wsClient{conn: conn, messages: make(chan []byte, wsClientBuffer)}
+
+	b.mu.Lock()
+	snapshot := wsSnapshot{Type: "snapshot", Users: make([]userRecord, 0, len(b.latest))}
+	for _, user := range b.latest {
+		snapshot.Users = append(snapshot.Users, userRecord(user))
+	}
+	sort.SliceStable(snapshot.Users, func(i, j int) bool {
+		if snapshot.Users[i].Server != snapshot.Users[j].Server {
+			return snapshot.Users[i].Server < snapshot.Users[j].Server
+		}
+		return snapshot.Users[i].Username < snapshot.Users[j].Username
+	})
+	message, _ := json.Marshal(snapshot)
+	client.messages <- message
+	b.clients[client] = true
+	b.mu.Unlock()
+
+	// messages of client are only read, so control frames (ping and close) are answered, and disconnect is noticed
+	go func() {
+		for {
+			_, _, err := wsutil.ReadClientData(client)
+			if err != nil {
+				b.mu.Lock()
+				b.remove(client)
+				b.mu.Unlock()
+				return
+			}
+		}
+	}()
+
+	for message := range client.messages {
+		err = client.send(message)
+		if err != nil {
+			break
+		}
+	}
+
+	b.mu.Lock()
+	b.remove(client)
+	b.mu.Unlock()
+	logger.Infof("WebSocket client %s is disconnected", conn.RemoteAddr())
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// serviceCommand is available only on Windows, on other systems tool is run as service by systemd, launchd and etc
+func serviceCommand(args []string) int {
+	fmt.Fprintln(os.Stderr, "Service mode is available only on Windows")
+	return 1
+}
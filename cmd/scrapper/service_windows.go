@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// serviceName is a name of Windows service, that tool is installed as
+const serviceName = "discord-user-monitor"
+
+// serviceCommand installs, runs or uninstalls tool as Windows service, so it runs unattended without console
+// window. Flags after install are saved in service, and passed to it each time it's started.
+func serviceCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: scrapper service install [flags] | run [flags] | uninstall")
+		return 1
+	}
+
+	var err error
+	switch args[0] {
+	case "install":
+		err = installService(args[1:])
+	case "run":
+		err = runService(args[1:])
+	case "uninstall":
+		err = uninstallService()
+	default:
+		err = fmt.Errorf("unknown service command %s", args[0])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't %s service: %v\n", args[0], err)
+		return 1
+	}
+
+	return 0
+}
+
+// installService registers tool as service, that starts automatically with Windows
+func installService(args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("getting path to executable: %w", err)
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists", serviceName)
+	}
+
+	s, err = m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: "Discord User Monitor",
+		Description: "Scraps statuses of Discord server members every interval",
+		StartType:   mgr.StartAutomatic,
+	}, append([]string{"service", "run"}, args...)...)
+	if err != nil {
+		return fmt.Errorf("creating service: %w", err)
+	}
+	defer s.Close()
+
+	fmt.Printf("Service %s is installed, it can be started with: sc start %s\n", serviceName, serviceName)
+	return nil
+}
+
+// uninstallService removes service, it's removed by Windows, when it's stopped
+func uninstallService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("opening service: %w", err)
+	}
+	defer s.Close()
+
+	err = s.Delete()
+	if err != nil {
+		return fmt.Errorf("deleting service: %w", err)
+	}
+
+	fmt.Printf("Service %s is uninstalled\n", serviceName)
+	return nil
+}
+
+// runService runs tool with flags, when it's started by service manager
+func runService(args []string) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return fmt.Errorf("checking if tool is started as service: %w", err)
+	}
+	if !isService {
+		return fmt.Errorf("tool isn't started by service manager, start it with: sc start %s", serviceName)
+	}
+
+	// flags are parsed from arguments of process
+	os.Args = append([]string{os.Args[0]}, args...)
+
+	return svc.Run(serviceName, scrapperService{})
+}
+
+// scrapperService stops tool the same way as Ctrl + C does, when service manager asks to stop it
+type scrapperService struct{}
+
+func (scrapperService) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	quit := make(chan os.Signal, 1)
+	exited := make(chan int, 1)
+	go func() {
+		exited <- run(quit)
+	}()
+
+	accepts := svc.AcceptStop | svc.AcceptShutdown
+	status <- svc.Status{State: svc.Running, Accepts: accepts}
+
+	for {
+		select {
+		case exitCode := <-exited:
+			return false, uint32(exitCode)
+		case r := <-requests:
+			switch r.Cmd {
+			case svc.Interrogate:
+				status <- r.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				// service manager waits for tool to write scrapped users and close browser
+				status <- svc.Status{State: svc.StopPending, WaitHint: uint32(*shutdownTimeout / time.Millisecond)}
+
+				select {
+				case quit <- os.Interrupt:
+				default:
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// userSession is a continuous span of time, when user wasn't offline. It starts at first sample, where user was
+// online, and ends at last one, so duration is a lower bound of real session.
+type userSession struct {
+	Server   string    `json:"server"`
+	ID       string    `json:"id"`
+	Username string    `json:"username"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Samples  int       `json:"samples"`        // amount of samples, where user was online
+	Open     bool      `json:"open,omitempty"` // session isn't finished yet, it's only set by live tracking
+}
+
+// Duration returns length of session
+func (s userSession) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// sessionGap returns maximum time between online samples of one session, one missed sample (user was offline or
+// not in member list for one scrapping process) is tolerated, and half of interval covers delays of scrapping
+func sessionGap(interval time.Duration) time.Duration {
+	return interval*2 + interval/2
+}
+
+// sampleInterval estimates interval between scrapping processes as median time between samples of each user
+func sampleInterval(users []User) time.Duration {
+	last := make(map[string]time.Time)
+	deltas := make([]time.Duration, 0, len(users))
+	for _, user := range users {
+		key := user.Server + "/" + userKey(user)
+		if t, ok := last[key]; ok && user.StatusTime.After(t) {
+			deltas = append(deltas, user.StatusTime.Sub(t))
+		}
+		if user.StatusTime.After(last[key]) {
+			last[key] = user.StatusTime.Time
+		}
+	}
+
+	return medianDuration(deltas, 2*time.Minute)
+}
+
+// sessionTracker joins online samples of each user into sessions, samples have to be supplied in order of time
+type sessionTracker struct {
+	gap  time.Duration
+	open map[string]*userSession // server/user -> unfinished session
+}
+
+// newSessionTracker returns tracker, whose sessions end, when user isn't online for longer than gap
+func newSessionTracker(gap time.Duration) *sessionTracker {
+	return &sessionTracker{gap: gap, open: make(map[string]*userSession)}
+}
+
+// add adds sample of user, session of user is returned, if sample starts new one after gap
+func (t *sessionTracker) add(user User) (userSession, bool) {
+	if user.Status == "Offline" {
+		return userSession{}, false
+	}
+
+	key := user.Server + "/" + userKey(user)
+	at := user.StatusTime.UTC()
+
+	s, ok := t.open[key]
+	if ok && at.Sub(s.End) <= t.gap {
+		if at.After(s.End) {
+			s.End = at
+			s.Samples++
+		}
+		s.Username = user.Username
+		return userSession{}, false
+	}
+
+	t.open[key] = &userSession{Server: user.Server, ID: userKey(user), Username: user.Username, Start: at, End: at, Samples: 1, Open: true}
+	if !ok {
+		return userSession{}, false
+	}
+
+	s.Open = false
+	return *s, true
+}
+
+// cycle adds samples of scrapping process at now, and returns sessions, that are continued or started by it, and
+// sessions, that are finished, as user wasn't online for longer than gap
+func (t *sessionTracker) cycle(users []User, now time.Time) []userSession {
+	sessions := make([]userSession, 0)
+	for _, user := range users {
+		if s, ok := t.add(user); ok {
+			sessions = append(sessions, s)
+		}
+	}
+
+	for key, s := range t.open {
+		if now.Sub(s.End) > t.gap {
+			s.Open = false
+			delete(t.open, key)
+		}
+		sessions = append(sessions, *s)
+	}
+
+	return sessions
+}
+
+// finish returns sessions, that aren't finished yet, and removes them from tracker
+func (t *sessionTracker) finish() []userSession {
+	sessions := make([]userSession, 0, len(t.open))
+	for key, s := range t.open {
+		s.Open = false
+		sessions = append(sessions, *s)
+		delete(t.open, key)
+	}
+
+	return sessions
+}
+
+// buildSessions estimates online sessions of users from stored samples, sessions end, when user isn't online for
+// longer than gap. Sessions are sorted by server, user and start.
+func buildSessions(users []User, gap time.Duration) []userSession {
+	samples := append([]User(nil), users...)
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].StatusTime.Before(samples[j].StatusTime.Time)
+	})
+
+	tracker := newSessionTracker(gap)
+	sessions := make([]userSession, 0)
+	for _, sample := range samples {
+		if s, ok := tracker.add(sample); ok {
+			sessions = append(sessions, s)
+		}
+	}
+	sessions = append(sessions, tracker.finish()...)
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		a, b := sessions[i], sessions[j]
+		switch {
+		case a.Server != b.Server:
+			return a.Server < b.Server
+		case a.ID != b.ID:
+			return a.ID < b.ID
+		}
+		return a.Start.Before(b.Start)
+	})
+
+	return sessions
+}
+
+// sessionWriter is implemented by outputs, that store sessions of users besides users, eg: databases
+type sessionWriter interface {
+	WriteSessions(sessions []userSession) error
+}
+
+// writeUserSessions writes sessions to each writer, errors are logged, so scrapping isn't stopped by them
+func writeUserSessions(writers []sessionWriter, sessions []userSession) {
+	if len(sessions) == 0 {
+		return
+	}
+
+	for _, writer := range writers {
+		err := writer.WriteSessions(sessions)
+		if err != nil {
+			logger.Errorf("Couldn't write sessions of users: %v", err)
+			metrics.incError("output")
+		}
+	}
+}
+
+// analyzeSessionsCommand prints online sessions of users, estimated from stored users
+func analyzeSessionsCommand(args []string) int {
+	flags := pflag.NewFlagSet("analyze sessions", pflag.ExitOnError)
+	inputs := flags.StringArrayP("input", "i", nil, "path to file (csv, json or ndjson, optionally .gz) or SQLite database (sqlite://users.db), where users are read from, can be repeated")
+	user := flags.StringP("user", "u", "", "ID, username, nickname or global username of user, sessions of all users by default")
+	server := flags.String("server", "", "ID or name of server, only its sessions are reported")
+	from := flags.String("from", "", "first date (2006-01-02) or time (RFC 3339) of range, since first sample by default")
+	to := flags.String("to", "", "last date (2006-01-02, inclusive) or time (RFC 3339, exclusive) of range, until last sample by default")
+	interval := flags.Duration("interval", 0, "interval between scrapping processes, one missed sample is tolerated in session, it's estimated from samples by default")
+	minDuration := flags.Duration("min-duration", 0, "minimum duration of reported sessions (eg: 10m)")
+	format := flags.String("format", "text", "format of report (text, csv or json)")
+	output := flags.StringP("output", "o", "", "path to file, where report is written, stdout by default")
+	flags.Parse(args)
+
+	logger = newLogger(os.Stderr, levelInfo, false)
+
+	if len(*inputs) == 0 || (*format != "text" && *format != "csv" && *format != "json") {
+		fmt.Fprintln(os.Stderr, "Usage: scrapper analyze sessions --input <file> [--user <name|id>] [--server <server>] [--from <date>] [--to <date>] [--interval 2m] [--min-duration 10m] [--format text|csv|json] [--output <file>]")
+		flags.PrintDefaults()
+		return 1
+	}
+
+	var rangeFrom, rangeTo time.Time
+	var err error
+	if *from != "" {
+		rangeFrom, err = parseRangeTime(*from, false)
+		if err != nil {
+			logger.Errorf("Couldn't parse --from: %v", err)
+			return 1
+		}
+	}
+	if *to != "" {
+		rangeTo, err = parseRangeTime(*to, true)
+		if err != nil {
+			logger.Errorf("Couldn't parse --to: %v", err)
+			return 1
+		}
+	}
+
+	users, err := readAnalyzedUsers(*inputs, *server)
+	if err != nil {
+		logger.Errorf("Couldn't read users: %v", err)
+		return 1
+	}
+
+	// interval is estimated from all users, as samples of one user can be rare
+	if *interval <= 0 {
+		*interval = sampleInterval(users)
+		logger.Infof("Estimated interval between scrapping processes is %v", interval.Round(time.Second))
+	}
+
+	users = filterTimeRange(users, rangeFrom, rangeTo)
+	if *user != "" {
+		sampled := make([]User, 0)
+		for _, u := range users {
+			if isUser(u, *user) {
+				sampled = append(sampled, u)
+			}
+		}
+		if len(sampled) == 0 {
+			logger.Errorf("Couldn't find user %s in input", *user)
+			return 1
+		}
+		users = sampled
+	}
+
+	sessions := make([]userSession, 0)
+	for _, s := range buildSessions(users, sessionGap(*interval)) {
+		if s.Duration() >= *minDuration {
+			sessions = append(sessions, s)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			logger.Errorf("Couldn't create output file: %v", err)
+			return 1
+		}
+		defer file.Close()
+
+		w = file
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(sessions)
+	case "csv":
+		err = writeSessionsCSV(w, sessions)
+	default:
+		err = writeSessionsTable(w, sessions)
+	}
+	if err != nil {
+		logger.Errorf("Couldn't write sessions: %v", err)
+		return 1
+	}
+
+	return 0
+}
+
+// writeSessionsTable writes sessions as table aligned with spaces, times are shown in local time
+func writeSessionsTable(w io.Writer, sessions []userSession) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVER\tUSERNAME\tID\tSTART\tEND\tDURATION\tSAMPLES")
+	for _, s := range sessions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\n", s.Server, s.Username, s.ID, s.Start.Local().Format(timeFormat),
+			s.End.Local().Format(timeFormat), s.Duration().Round(time.Second), s.Samples)
+	}
+
+	return tw.Flush()
+}
+
+// writeSessionsCSV writes sessions as csv rows, times are written in RFC 3339 format and duration in seconds
+func writeSessionsCSV(w io.Writer, sessions []userSession) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"server", "username", "id", "start", "end", "duration_seconds", "samples"})
+	for _, s := range sessions {
+		cw.Write([]string{s.Server, s.Username, s.ID, s.Start.Format(time.RFC3339), s.End.Format(time.RFC3339),
+			strconv.FormatInt(int64(s.Duration()/time.Second), 10), strconv.Itoa(s.Samples)})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
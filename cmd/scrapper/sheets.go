@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// sheetsScope is OAuth scope needed to edit spreadsheets
+const sheetsScope = "https://www.googleapis.com/auth/spreadsheets"
+
+// sheetsAPIURL is a base URL of Google Sheets API
+const sheetsAPIURL = "https://sheets.googleapis.com/v4/spreadsheets"
+
+// serviceAccount is a key of Google service account, downloaded from Google Cloud Console in .json format
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// sheetsWriter appends users of each cycle as rows of Google Sheet, spreadsheet has to be shared with service
+// account's email
+type sheetsWriter struct {
+	client        *http.Client
+	account       serviceAccount
+	key           *rsa.PrivateKey
+	spreadsheetID string
+	sheet         string
+
+	token       string
+	tokenExpiry time.Time
+}
+
+// newSheetsWriter creates writer of spreadsheet, authorized with service account key from credentials file
+func newSheetsWriter(credentialsPath, spreadsheetID, sheet string) (*sheetsWriter, error) {
+	data, err := ioutil.ReadFile(credentialsPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading service account file: %w", err)
+	}
+
+	var account serviceAccount
+	err = json.Unmarshal(data, &account)
+	if err != nil {
+		return nil, fmt.Errorf("decoding service account file: %w", err)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, errors.New("service account private key isn't in PEM format")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing service account private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("service account private key isn't RSA key")
+	}
+
+	return &sheetsWriter{
+		client:        &http.Client{Timeout: 30 * time.Second},
+		account:       account,
+		key:           key,
+		spreadsheetID: spreadsheetID,
+		sheet:         sheet,
+	}, nil
+}
+
+// accessToken returns OAuth access token, it's exchanged for signed JWT and reused until it expires
+func (w *sheetsWriter) accessToken() (string, error) {
+	if w.token != "" && time.Now().Before(w.tokenExpiry) {
+		return w.token, nil
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   w.account.ClientEmail,
+		"scope": sheetsScope,
+		"aud":   w.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, w.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("signing jwt: %w", err)
+	}
+	assertion := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+
+	resp, err := w.client.PostForm(w.account.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", fmt.Errorf("requesting access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint responded with %s", resp.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return "", fmt.Errorf("decoding access token: %w", err)
+	}
+
+	// token is refreshed a minute before it expires
+	w.token = token.AccessToken
+	w.tokenExpiry = now.Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return w.token, nil
+}
+
+// do sends request to Sheets API and decodes json response into v, if it isn't nil
+func (w *sheetsWriter) do(method, path string, body, v interface{}) error {
+	token, err := w.accessToken()
+	if err != nil {
+		return err
+	}
+
+	var reqBody bytes.Buffer
+	if body != nil {
+		err = json.NewEncoder(&reqBody).Encode(body)
+		if err != nil {
+			return fmt.Errorf("encoding sheets request: %w", err)
+		}
+	}
+
+	req, err := http.NewRequest(method, sheetsAPIURL+path, &reqBody)
+	if err != nil {
+		return fmt.Errorf("creating sheets request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending sheets request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("sheets api responded with %s", resp.Status)
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Write appends users as rows to the end of sheet, header is added if sheet is empty
+func (w *sheetsWriter) Write(users []User) error {
+	var first struct {
+		Values [][]string `json:"values"`
+	}
+	err := w.do(http.MethodGet, "/"+w.spreadsheetID+"/values/"+url.PathEscape(w.sheet+"!A1:A1"), nil, &first)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(users)+1)
+	if len(first.Values) == 0 {
+		rows = append(rows, outputColumns)
+	}
+	for _, user := range users {
+		rows = append(rows, userValues(user, outputColumns))
+	}
+
+	// values are appended as they are, so Sheets doesn't reformat IDs as numbers
+	path := "/" + w.spreadsheetID + "/values/" + url.PathEscape(w.sheet) + ":append?valueInputOption=RAW&insertDataOption=INSERT_ROWS"
+	return w.do(http.MethodPost, path, map[string]interface{}{"values": rows}, nil)
+}
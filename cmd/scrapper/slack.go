@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// slackMessage is a message of Slack incoming webhook, text is shown in notifications, and blocks in channel
+type slackMessage struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks,omitempty"`
+}
+
+// slackBlock is a block of Slack block kit layout, eg: header, section or context
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+// slackText is a text object of block kit, plain_text or mrkdwn
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackNotification formats notification as section with event in bold
+func slackNotification(event, message string) slackMessage {
+	return slackMessage{
+		Text: fmt.Sprintf("%s: %s", event, message),
+		Blocks: []slackBlock{
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: fmt.Sprintf("*%s*\n%s", event, escapeSlack(message))}},
+			{Type: "context", Elements: []slackText{{Type: "mrkdwn", Text: "Discord User Monitor, " + time.Now().Format(timeFormat)}}},
+		},
+	}
+}
+
+// escapeSlack escapes characters, that are control characters of Slack messages
+func escapeSlack(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// cycleSummary is an outcome of one scrapping process, it's sent to Slack with --slack-summary
+type cycleSummary struct {
+	Cycle    int
+	Users    []User
+	Changes  map[string]int // type of change -> amount
+	Errors   int
+	Duration time.Duration
+}
+
+// slackSummary formats summary of scrapping process as header with fields of totals, and users online on each
+// server
+func slackSummary(s cycleSummary) slackMessage {
+	online := make(map[string]int)
+	members := make(map[string]int)
+	total := 0
+	for _, user := range s.Users {
+		members[user.Server]++
+		if user.Status != "Offline" {
+			online[user.Server]++
+			total++
+		}
+	}
+
+	title := fmt.Sprintf("Scrapping process %d is finished", s.Cycle)
+	if s.Errors > 0 {
+		title = fmt.Sprintf("Scrapping process %d is finished with %d errors", s.Cycle, s.Errors)
+	}
+
+	changes := make([]string, 0, len(s.Changes))
+	for _, t := range []string{changeStatus, changeAppeared, changeDisappeared, changeJoined, changeLeft} {
+		if s.Changes[t] > 0 {
+			changes = append(changes, fmt.Sprintf("%s %d", t, s.Changes[t]))
+		}
+	}
+	if len(changes) == 0 {
+		changes = append(changes, "none")
+	}
+
+	servers := make([]string, 0, len(members))
+	for server := range members {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+	lines := make([]string, len(servers))
+	for i, server := range servers {
+		lines[i] = fmt.Sprintf("*%s*: %d online of %d", escapeSlack(server), online[server], members[server])
+	}
+
+	blocks := []slackBlock{
+		{Type: "header", Text: &slackText{Type: "plain_text", Text: title}},
+		{Type: "section", Fields: []slackText{
+			{Type: "mrkdwn", Text: fmt.Sprintf("*Users*\n%d", len(s.Users))},
+			{Type: "mrkdwn", Text: fmt.Sprintf("*Online*\n%d", total)},
+			{Type: "mrkdwn", Text: fmt.Sprintf("*Changes*\n%s", strings.Join(changes, ", "))},
+			{Type: "mrkdwn", Text: fmt.Sprintf("*Duration*\n%s", s.Duration.Round(time.Second))},
+		}},
+	}
+	if len(lines) > 0 {
+		blocks = append(blocks, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: strings.Join(lines, "\n")}})
+	}
+
+	return slackMessage{
+		Text:   fmt.Sprintf("%s: %d users, %d online", title, len(s.Users), total),
+		Blocks: blocks,
+	}
+}
+
+// sendSlackSummary sends summary of scrapping process to Slack, errors are logged, so scrapping isn't stopped by
+// them
+func sendSlackSummary(s cycleSummary) {
+	err := sendWebhook(*slackWebhookURL, slackSummary(s))
+	if err != nil {
+		logger.Errorf("Couldn't send summary to Slack: %v", err)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // pure Go driver, so tool is still built without cgo
+)
+
+// sqliteOutputPrefix is a prefix of output, that is a SQLite database, eg: 'sqlite://users.db'
+const sqliteOutputPrefix = "sqlite://"
+
+// sqliteSchema creates tables of users, their presence samples and online sessions. Users are identified by server
+// and ID (or username, if ID isn't found), they are upserted each cycle, while samples are only appended. Sessions
+// are written only with --sessions, open ones are updated each cycle.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	server          TEXT NOT NULL,
+	id              TEXT NOT NULL,
+	username        TEXT NOT NULL,
+	nickname        TEXT NOT NULL,
+	global_username TEXT NOT NULL,
+	type            TEXT NOT NULL,
+	avatar_url      TEXT NOT NULL,
+	joined_at       TEXT NOT NULL,
+	first_seen      TEXT NOT NULL,
+	last_seen       TEXT NOT NULL,
+	PRIMARY KEY (server, id)
+);
+
+CREATE TABLE IF NOT EXISTS presence_samples (
+	server        TEXT NOT NULL,
+	channel       TEXT NOT NULL,
+	user_id       TEXT NOT NULL,
+	status        TEXT NOT NULL,
+	role_group    TEXT NOT NULL,
+	custom_status TEXT NOT NULL,
+	activity      TEXT NOT NULL,
+	sampled_at    TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS presence_samples_user_time ON presence_samples (server, channel, user_id, sampled_at);
+CREATE INDEX IF NOT EXISTS presence_samples_time ON presence_samples (sampled_at);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	server     TEXT NOT NULL,
+	user_id    TEXT NOT NULL,
+	username   TEXT NOT NULL,
+	started_at TEXT NOT NULL,
+	ended_at   TEXT NOT NULL,
+	samples    INTEGER NOT NULL,
+	open       INTEGER NOT NULL,
+	PRIMARY KEY (server, user_id, started_at)
+);
+`
+
+// sqliteWriter writes users into SQLite database
+type sqliteWriter struct {
+	db *sql.DB
+}
+
+// openSQLite opens SQLite database at path, and creates its tables if they don't exist
+func openSQLite(path string) (*sqliteWriter, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("opening sqlite database: %w", err)
+	}
+
+	_, err = db.Exec(sqliteSchema)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating sqlite tables: %w", err)
+	}
+
+	return &sqliteWriter{db: db}, nil
+}
+
+// userKey returns ID of user, or username if ID isn't found
+func userKey(user User) string {
+	if user.ID != "" {
+		return user.ID
+	}
+
+	return user.Username
+}
+
+// Write upserts users and appends their presence samples in one transaction, samples that are already stored are
+// skipped, so the same cycle can be written twice
+func (w *sqliteWriter) Write(users []User) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, user := range users {
+		sampledAt := user.StatusTime.UTC().Format(time.RFC3339)
+
+		_, err = tx.Exec(`INSERT INTO users (server, id, username, nickname, global_username, type, avatar_url, joined_at, first_seen, last_seen)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (server, id) DO UPDATE SET
+	username = excluded.username,
+	nickname = excluded.nickname,
+	global_username = excluded.global_username,
+	type = excluded.type,
+	avatar_url = excluded.avatar_url,
+	joined_at = CASE WHEN excluded.joined_at = '' THEN users.joined_at ELSE excluded.joined_at END,
+	last_seen = excluded.last_seen`,
+			user.Server, userKey(user), user.Username, user.Nickname, user.GlobalUsername, user.Type, user.AvatarURL, user.JoinedAt, sampledAt, sampledAt)
+		if err != nil {
+			return fmt.Errorf("upserting user: %w", err)
+		}
+
+		_, err = tx.Exec(`INSERT OR IGNORE INTO presence_samples (server, channel, user_id, status, role_group, custom_status, activity, sampled_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			user.Server, user.Channel, userKey(user), user.Status, user.RoleGroup, user.CustomStatus, user.Activity, sampledAt)
+		if err != nil {
+			return fmt.Errorf("inserting presence sample: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// WriteSessions upserts sessions in one transaction, session is identified by its user and start
+func (w *sqliteWriter) WriteSessions(sessions []userSession) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, s := range sessions {
+		_, err = tx.Exec(`INSERT INTO sessions (server, user_id, username, started_at, ended_at, samples, open)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (server, user_id, started_at) DO UPDATE SET
+	username = excluded.username,
+	ended_at = excluded.ended_at,
+	samples = excluded.samples,
+	open = excluded.open`,
+			s.Server, s.ID, s.Username, s.Start.UTC().Format(time.RFC3339), s.End.UTC().Format(time.RFC3339), s.Samples, s.Open)
+		if err != nil {
+			return fmt.Errorf("upserting session: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// PruneSamples removes presence samples older than before in one transaction, users without samples left and
+// sessions finished before are removed as well. Samples are archived first, so they aren't lost, if archiving fails.
+func (w *sqliteWriter) PruneSamples(before time.Time, archive func(users []User) error) (int64, error) {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// times are stored in RFC 3339 format in UTC, so they are compared as text
+	cutoff := before.UTC().Format(time.RFC3339)
+
+	if archive != nil {
+		rows, err := tx.Query(sqliteSamplesQuery+` WHERE s.sampled_at < ? ORDER BY s.sampled_at`, cutoff)
+		if err != nil {
+			return 0, fmt.Errorf("querying old presence samples: %w", err)
+		}
+		users, err := scanStoredUsers(rows)
+		rows.Close()
+		if err != nil {
+			return 0, err
+		}
+
+		err = archive(users)
+		if err != nil {
+			return 0, fmt.Errorf("archiving old presence samples: %w", err)
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM presence_samples WHERE sampled_at < ?`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("removing old presence samples: %w", err)
+	}
+	removed, _ := result.RowsAffected()
+
+	_, err = tx.Exec(`DELETE FROM users WHERE last_seen < ?
+	AND NOT EXISTS (SELECT 1 FROM presence_samples s WHERE s.server = users.server AND s.user_id = users.id)`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("removing old users: %w", err)
+	}
+	_, err = tx.Exec(`DELETE FROM sessions WHERE ended_at < ? AND open = 0`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("removing old sessions: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return removed, nil
+}
+
+// CompactSamples replaces presence samples older than before with samples compacted into buckets in one
+// transaction, see compactUsers, users aren't changed. Amounts of samples before and after compacting are returned.
+func (w *sqliteWriter) CompactSamples(before time.Time, bucket time.Duration) (int, int, error) {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	cutoff := before.UTC().Format(time.RFC3339)
+
+	rows, err := tx.Query(sqliteSamplesQuery+` WHERE s.sampled_at < ? ORDER BY s.sampled_at`, cutoff)
+	if err != nil {
+		return 0, 0, fmt.Errorf("querying old presence samples: %w", err)
+	}
+	users, err := scanStoredUsers(rows)
+	rows.Close()
+	if err != nil {
+		return 0, 0, err
+	}
+	compacted := compactUsers(users, before, bucket)
+
+	_, err = tx.Exec(`DELETE FROM presence_samples WHERE sampled_at < ?`, cutoff)
+	if err != nil {
+		return 0, 0, fmt.Errorf("removing old presence samples: %w", err)
+	}
+
+	for _, user := range compacted {
+		_, err = tx.Exec(`INSERT OR IGNORE INTO presence_samples (server, channel, user_id, status, role_group, custom_status, activity, sampled_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			user.Server, user.Channel, userKey(user), user.Status, user.RoleGroup, user.CustomStatus, user.Activity, user.StatusTime.UTC().Format(time.RFC3339))
+		if err != nil {
+			return 0, 0, fmt.Errorf("inserting compacted presence sample: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, 0, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	return len(users), len(compacted), nil
+}
+
+// Close closes database
+func (w *sqliteWriter) Close() error {
+	return w.db.Close()
+}
+
+// isSQLiteOutput checks if output supplied by user is a SQLite database
+func isSQLiteOutput(output string) bool {
+	return strings.HasPrefix(output, sqliteOutputPrefix)
+}
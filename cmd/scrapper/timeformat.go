@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// statusTimeLayout and statusTimeLocation are format and timezone of status time in output, they are RFC 3339 and
+// UTC by default, so data of scrappers in different timezones can be merged. statusTimeUnix is unix or unix-ms,
+// when status time is written as unix time instead.
+var (
+	statusTimeLayout   = time.RFC3339
+	statusTimeUnix     = ""
+	statusTimeLocation = time.UTC
+)
+
+// initTimeFormat sets format and timezone of status time from --time-format and --timezone
+func initTimeFormat(format, timezone string) error {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return fmt.Errorf("loading timezone %s: %w", timezone, err)
+	}
+
+	layout, unix := format, ""
+	switch name := strings.ToLower(format); name {
+	case "rfc3339":
+		layout = time.RFC3339
+	case "rfc3339nano":
+		layout = time.RFC3339Nano
+	case "unix", "unix-ms":
+		layout, unix = time.RFC3339, name
+	default:
+		// layout has to contain at least one element of time, otherwise every time is formatted the same way
+		if time.Unix(0, 0).UTC().Format(format) == time.Unix(86400+3600+60+1, 0).UTC().Format(format) {
+			return fmt.Errorf("time format %q doesn't contain Go layout elements, like 2006-01-02 15:04:05", format)
+		}
+	}
+
+	statusTimeLayout, statusTimeUnix, statusTimeLocation = layout, unix, location
+
+	return nil
+}
+
+// formatStatusTime formats status time in format and timezone supplied by user
+func formatStatusTime(t time.Time) string {
+	switch statusTimeUnix {
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unix-ms":
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+
+	return t.In(statusTimeLocation).Format(statusTimeLayout)
+}
+
+// parseStatusTime parses status time written by any version of tool: in format supplied by user, in RFC 3339
+// format, in unix time, or in minute resolution local time, which was used before
+func parseStatusTime(value string) (time.Time, error) {
+	if statusTimeUnix == "" {
+		if t, err := time.ParseInLocation(statusTimeLayout, value, statusTimeLocation); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(timeFormat, value, time.Local); err == nil {
+		return t, nil
+	}
+
+	// unix time is written in seconds or milliseconds, milliseconds are 13 digits long until year 2286
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if len(value) >= 13 {
+			return time.Unix(0, n*int64(time.Millisecond)), nil
+		}
+		return time.Unix(n, 0), nil
+	}
+
+	return time.Time{}, fmt.Errorf("unknown format of time %q", value)
+}
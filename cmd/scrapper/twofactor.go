@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tebeka/selenium"
+)
+
+// handleTwoFactor waits for Discord's 2FA prompt after login form was submitted, and passes it either
+// with code generated from TOTP secret, code read from stdin, or by waiting until user types code manually in browser
+func handleTwoFactor(driver Driver) error {
+	time.Sleep(2 * time.Second) // wait until 2FA prompt is shown
+
+	codeField, err := findElement(driver, selectors.TwoFactorField)
+	if err != nil { // prompt isn't shown, so 2FA isn't needed for this login
+		return nil
+	}
+
+	if *discordTOTPSecret != "" {
+		code, err := totpCode(*discordTOTPSecret, time.Now())
+		if err != nil {
+			return err
+		}
+
+		return submitTwoFactorCode(driver, codeField, code)
+	}
+
+	if *discordTwoFactorBrowserTimeout > 0 {
+		return waitTwoFactorInBrowser(driver)
+	}
+
+	code, err := readTwoFactorCode()
+	if err != nil {
+		return err
+	}
+
+	return submitTwoFactorCode(driver, codeField, code)
+}
+
+// readTwoFactorCode asks user to type 2FA code in terminal
+func readTwoFactorCode() (string, error) {
+	fmt.Fprint(os.Stderr, "Enter Discord 2FA code: ")
+
+	code, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("reading 2FA code: %w", err)
+	}
+
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return "", errors.New("2FA code is empty")
+	}
+
+	return code, nil
+}
+
+// submitTwoFactorCode fills 2FA field with code and submits it
+func submitTwoFactorCode(driver Driver, codeField Element, code string) error {
+	err := codeField.SendKeys(code + selenium.EnterKey)
+	if err != nil {
+		return fmt.Errorf("filling 2FA field: %w", err)
+	}
+
+	time.Sleep(2 * time.Second) // wait until code is checked
+
+	// if prompt is still shown, then code was rejected
+	_, err = findElement(driver, selectors.TwoFactorField)
+	if err == nil {
+		return errors.New("2FA code was rejected")
+	}
+
+	return nil
+}
+
+// waitTwoFactorInBrowser polls 2FA prompt until it disappears, which means user typed code in browser
+func waitTwoFactorInBrowser(driver Driver) error {
+	deadline := time.Now().Add(time.Duration(*discordTwoFactorBrowserTimeout) * time.Second)
+	for time.Now().Before(deadline) {
+		_, err := findElement(driver, selectors.TwoFactorField)
+		if err != nil {
+			return nil
+		}
+
+		time.Sleep(time.Second)
+	}
+
+	return fmt.Errorf("2FA code wasn't typed in browser in %d seconds", *discordTwoFactorBrowserTimeout)
+}
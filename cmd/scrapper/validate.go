@@ -0,0 +1,370 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// seleniumDialTimeout is a time to wait for selenium server to accept connection, when config is validated
+const seleniumDialTimeout = 3 * time.Second
+
+// configProblem is an invalid setting, with hint how to fix it
+type configProblem struct {
+	problem string
+	hint    string
+}
+
+// configProblems collects problems of config, so all of them are reported at once
+type configProblems []configProblem
+
+func (p *configProblems) add(problem, hint string) {
+	*p = append(*p, configProblem{problem: problem, hint: hint})
+}
+
+// validateConfig checks all settings before browser is started, and returns all problems found in them, so user
+// doesn't fix them one by one, while tool fails mid-run. Settings, that are parsed while they are checked (filters,
+// format template, columns and time format), are kept, so they aren't parsed again.
+func validateConfig() configProblems {
+	var problems configProblems
+
+	validateSource(&problems)
+	validateServers(&problems)
+	validateTiming(&problems)
+	validateOutput(&problems)
+	validateBrowser(&problems)
+
+	err := initFilters()
+	if err != nil {
+		problems.add(fmt.Sprintf("filters are invalid: %v", err), "check regular expressions wrapped in slashes in --include-users, --exclude-users, --watch and --watch-file")
+	}
+
+	if _, err := parseLogLevel(*logLevelName); err != nil {
+		problems.add(err.Error(), "use --log-level debug, info, warn or error")
+	}
+	if *logFormat != "text" && *logFormat != "json" {
+		problems.add(fmt.Sprintf("unknown log format %s", *logFormat), "use --log-format text or json")
+	}
+	if *progressReport != "auto" && *progressReport != "bar" && *progressReport != "log" && *progressReport != "off" {
+		problems.add(fmt.Sprintf("unknown progress mode %s", *progressReport), "use --progress auto, bar, log or off")
+	}
+
+	return problems
+}
+
+// validateSource checks if source of members is known, and credentials it needs are supplied
+func validateSource(problems *configProblems) {
+	switch *source {
+	case "browser", "hybrid":
+		if *discordToken == "" && *browserProfileDir == "" && (*discordEmail == "" || *discordPassword == "") {
+			problems.add("Discord credentials aren't supplied", "supply --d-email and --d-password (or --d-password-file), --d-token, or --browser-profile-dir with logged in session")
+		}
+		if *source == "hybrid" && *discordBotToken == "" {
+			problems.add("hybrid source needs bot token to fetch roster", "supply --d-bot-token or DISCORD_BOT_TOKEN env variable")
+		}
+	case "gateway":
+		if *discordToken == "" && *discordBotToken == "" {
+			problems.add("gateway source needs token", "supply --d-token or --d-bot-token")
+		}
+	case "bot-api":
+		if *discordBotToken == "" {
+			problems.add("bot-api source needs bot token", "supply --d-bot-token or DISCORD_BOT_TOKEN env variable")
+		}
+	default:
+		problems.add(fmt.Sprintf("unknown source %s", *source), "use --source browser, gateway, bot-api or hybrid")
+	}
+
+	if _, ok := discordInstances[*discordInstance]; !ok {
+		problems.add(fmt.Sprintf("unknown Discord instance %s", *discordInstance), "use --d-instance stable, ptb or canary")
+	}
+}
+
+// validateServers checks if servers are supplied, and their IDs and IDs of channels look like Discord IDs
+func validateServers(problems *configProblems) {
+	if len(*discordServerIDs) == 0 && len(*discordServerNames) == 0 {
+		problems.add("server isn't supplied", "supply --d-server-id or --d-server-name, run 'scrapper list-servers' to see servers of account")
+	}
+
+	for _, id := range *discordServerIDs {
+		if !isSnowflake(id) {
+			problems.add(fmt.Sprintf("server ID %q isn't a Discord ID", id), "Discord IDs are 17-20 digits long, copy it with right click on server icon → Copy Server ID (Developer Mode has to be enabled), or use --d-server-name")
+		}
+	}
+	for _, id := range *discordChannelIDs {
+		if !isSnowflake(id) {
+			problems.add(fmt.Sprintf("channel ID %q isn't a Discord ID", id), "Discord IDs are 17-20 digits long, run 'scrapper list-channels' to see channels of server, or use --d-channel-name")
+		}
+	}
+
+	if *discordMembersPage && len(*discordChannelIDs)+len(*discordChannelNames) > 0 {
+		problems.add("--d-members-page can't be used with channels", "members page contains whole server roster, remove --d-channel-id and --d-channel-name")
+	}
+}
+
+// isSnowflake checks if value looks like Discord ID
+func isSnowflake(value string) bool {
+	return len(value) >= 17 && len(value) <= 20 && strings.Trim(value, "0123456789") == ""
+}
+
+// validateTiming checks if timing flags are positive, and mode of tool is known
+func validateTiming(problems *configProblems) {
+	if *scrappingInterval <= 0 {
+		problems.add("--interval has to be positive", "use interval like 2m, otherwise Discord is scrapped without pause")
+	}
+	if *discordLoadTime <= 0 {
+		problems.add("--load-timeout has to be positive", "use timeout like 10s")
+	}
+	if *discordServerScrollRefreshTime <= 0 {
+		problems.add("--scroll-refresh-time has to be positive", "use time like 300ms")
+	}
+	if *runOnce && *runDaemon {
+		problems.add("--once and --daemon can't be used together", "remove one of them, tool runs as daemon by default")
+	}
+	if *deltaOutput && *dedupeOutput {
+		problems.add("--delta and --dedupe can't be used together", "use --delta to write only changes of status, or --dedupe to write changes of any column")
+	}
+	if *dedupeSnapshot < 0 {
+		problems.add("--dedupe-snapshot-every can't be negative", "use --dedupe-snapshot-every 0 to write all users only in first scrapping process")
+	}
+	if *concurrency < 1 {
+		problems.add("--concurrency has to be at least 1", "use --concurrency 1 to scrap servers one by one")
+	}
+	if *concurrency > 1 && *browserProfileDir != "" {
+		problems.add("--browser-profile-dir can't be used with --concurrency above 1", "profile can be opened only by one browser, remove one of them")
+	}
+}
+
+// validateOutput checks output format and its options, and if output files can be written
+func validateOutput(problems *configProblems) {
+	if *formatTemplate != "" {
+		var err error
+		recordTemplate, err = parseRecordTemplate(*formatTemplate)
+		if err != nil {
+			problems.add(fmt.Sprintf("format template is invalid: %v", err), "check syntax of Go template, like '{{.Username}}\\t{{.Status}}'")
+		} else {
+			*outputFormat = "template"
+		}
+	}
+
+	switch *outputFormat {
+	case "csv", "json", "ndjson", "parquet", "xlsx", "template":
+	default:
+		problems.add(fmt.Sprintf("unknown output format %s", *outputFormat), "use --format csv, json, ndjson, parquet or xlsx")
+	}
+
+	if *outputFormat == "json" && !*runOnce {
+		problems.add("json output can be written only with --once", "each scrapping process would append its own array, and file wouldn't be valid JSON, use --format ndjson")
+	}
+	if *compressOutput && (*outputFormat == "parquet" || *outputFormat == "xlsx") {
+		problems.add("--compress can't be used with parquet and xlsx", "they are compressed already, remove --compress")
+	}
+	if *pathToOutputFile == "-" && (*outputFormat == "parquet" || *outputFormat == "xlsx") {
+		problems.add("parquet and xlsx can't be written to stdout", "they are written as directory of files, supply path of directory with --output")
+	}
+	if *encryptOutput && *pathToOutputFile == "-" {
+		problems.add("encrypted output can't be written to stdout", "it's written as encrypted file of each scrapping process, supply file with --output")
+	}
+	if *encryptOutput && isDirectoryOutput(*outputFormat) {
+		problems.add("parquet and xlsx output can't be encrypted", "they are written as directory of files, remove --encrypt-output or use another format")
+	}
+	if *xlsxSheets != "run" && *xlsxSheets != "server" {
+		problems.add(fmt.Sprintf("unknown way to split xlsx sheets %s", *xlsxSheets), "use --xlsx-sheets run or server")
+	}
+	if *sheetsID != "" && *sheetsCredentials == "" {
+		problems.add("Google Sheet needs service account key", "supply --sheets-credentials with key in .json format")
+	}
+
+	err := initTimeFormat(*statusTimeFormat, *statusTimezone)
+	if err != nil {
+		problems.add(err.Error(), "use --time-format rfc3339, unix or Go layout, and --timezone UTC, Local or IANA name, like Europe/Berlin")
+	}
+
+	outputColumns, err = parseColumns(*columns)
+	if err != nil {
+		problems.add(err.Error(), "fix --columns, or remove it to write all columns")
+	}
+
+	if _, err := parseChangeTypes(*notifyChanges); err != nil {
+		problems.add(err.Error(), "use --notify-changes with status, appeared, disappeared, joined and left")
+	}
+	for _, rule := range *alertRules {
+		if _, err := parseAlertRule(rule, *alertCooldown); err != nil {
+			problems.add(err.Error(), "use --alert with conditions like 'user=Alice status=Online', known conditions are user, server, status, from, event and cooldown")
+		}
+	}
+	if *alertCooldown < 0 {
+		problems.add("--alert-cooldown can't be negative", "use --alert-cooldown 0 to send notification on every matching change")
+	}
+	if *hookTimeout <= 0 {
+		problems.add("--hook-timeout has to be positive", "use --hook-timeout 30s")
+	}
+	if *leftAfter < 1 {
+		problems.add("--left-after has to be at least 1", "use --left-after 3, so user, who isn't in member list for 3 scrapping processes, left server")
+	}
+	if *retention < 0 {
+		problems.add("--retention can't be negative", "use --retention 90d to keep samples of last 90 days, or 0 to keep them forever")
+	}
+	if *retention > 0 && !isSQLiteOutput(*pathToOutputFile) && *postgresDSN == "" {
+		problems.add("--retention needs database, where old samples are removed", "use --output sqlite://users.db or --postgres-dsn, output files are rotated by other tools, like logrotate")
+	}
+	if *retention > 0 && *retentionInterval <= 0 {
+		problems.add("--retention-interval has to be positive", "use --retention-interval 1h")
+	}
+	if *retentionArchive != "" {
+		if info, err := os.Stat(*retentionArchive); err != nil || !info.IsDir() {
+			problems.add(fmt.Sprintf("--retention-archive %s isn't a directory", *retentionArchive), "create directory, where removed samples are archived")
+		}
+		if *retention == 0 {
+			problems.add("--retention-archive needs --retention", "use --retention 90d, so samples older than 90 days are archived and removed")
+		}
+	}
+	if *writeSessions && !isSQLiteOutput(*pathToOutputFile) && *postgresDSN == "" {
+		problems.add("--sessions needs database, where sessions are written", "use --output sqlite://users.db or --postgres-dsn")
+	}
+	if len(*notifyChanges) > 0 && *notifyWebhookURL == "" && *slackWebhookURL == "" && len(*emailTo) == 0 {
+		problems.add("--notify-changes needs webhook or email for notifications", "supply --notify-webhook-url, --slack-webhook-url or --email with alerts=all, otherwise changes are only logged")
+	}
+	if *slackSummaries && *slackWebhookURL == "" {
+		problems.add("--slack-summary needs Slack webhook", "supply --slack-webhook-url with URL of Slack incoming webhook")
+	}
+	if len(*emailTo) > 0 && (*smtpAddr == "" || *smtpFrom == "") {
+		problems.add("--email needs SMTP server", "supply --smtp-addr and --smtp-from, and --smtp-username and --smtp-password (or SMTP_PASSWORD), if server needs them")
+	}
+	if _, _, err := net.SplitHostPort(*smtpAddr); *smtpAddr != "" && err != nil {
+		problems.add(fmt.Sprintf("--smtp-addr %s isn't host:port", *smtpAddr), "use --smtp-addr smtp.example.com:587")
+	}
+	digests := false
+	for _, text := range *emailTo {
+		r, err := parseEmailRecipient(text, time.Now())
+		if err != nil {
+			problems.add(err.Error(), "use --email with address and settings like 'ops@example.com alerts=failures digest=08:00 attach=csv,html'")
+			continue
+		}
+		digests = digests || r.digest != ""
+	}
+	// digests are built from output, so it has to be readable while it's written
+	readable := isSQLiteOutput(*pathToOutputFile) || ((*outputFormat == "csv" || *outputFormat == "ndjson") && *pathToOutputFile != "-" &&
+		!*compressOutput && !*encryptOutput && (*pathToOutputFile == "" || filepath.Ext(*pathToOutputFile) == "."+*outputFormat))
+	if digests && !readable {
+		problems.add("email digests need output, that can be read", "use --output sqlite://users.db, or --format csv or ndjson without --compress and --encrypt-output")
+	}
+
+	// files are checked before scrapping, so users of first scrapping process aren't lost
+	files := map[string]string{
+		"--output":         *pathToOutputFile,
+		"--bots-output":    *pathToBotsOutputFile,
+		"--changes-output": *pathToChangesFile,
+		"--log":            *pathToLogFile,
+		"--state-file":     *pathToStateFile,
+	}
+	for _, flag := range []string{"--output", "--bots-output", "--changes-output", "--log", "--state-file"} {
+		path := files[flag]
+		if path == "" || path == "-" {
+			continue
+		}
+		path = strings.TrimPrefix(path, sqliteOutputPrefix)
+
+		err := checkWritable(path)
+		if err != nil {
+			problems.add(fmt.Sprintf("%s %s can't be written: %v", flag, path, err), "check that its directory exists, and user running tool has permission to write there")
+		}
+	}
+	if *pathToAvatarsDir != "" {
+		err := checkWritableDir(*pathToAvatarsDir)
+		if err != nil {
+			problems.add(fmt.Sprintf("--download-avatars %s can't be written: %v", *pathToAvatarsDir, err), "check that directory exists, and user running tool has permission to write there")
+		}
+	}
+}
+
+// checkWritable checks if file can be written, existing file is opened for appending, so it isn't changed
+func checkWritable(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err == nil {
+		return file.Close()
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	return checkWritableDir(filepath.Dir(path))
+}
+
+// checkWritableDir checks if files can be created in directory
+func checkWritableDir(dir string) error {
+	file, err := ioutil.TempFile(dir, ".scrapper-")
+	if err != nil {
+		return err
+	}
+	file.Close()
+
+	return os.Remove(file.Name())
+}
+
+// validateBrowser checks browser settings, and if selenium server accepts connections, as browser is started
+// only after output is opened
+func validateBrowser(problems *configProblems) {
+	if *source != "browser" && *source != "hybrid" {
+		return
+	}
+
+	if *backend != "selenium" && *backend != "chromedp" {
+		problems.add(fmt.Sprintf("unknown browser automation backend %s", *backend), "use --backend selenium or chromedp")
+	}
+
+	for _, proxy := range *proxies {
+		if _, err := parseProxy(proxy); err != nil {
+			problems.add(fmt.Sprintf("proxy is invalid: %v", err), "use http://host:port or socks5://host:port")
+		}
+	}
+
+	for _, capability := range *capabilities {
+		if _, _, err := parseCapability(capability); err != nil {
+			problems.add(fmt.Sprintf("capability %q is invalid", capability), "use key=value format, like browserVersion=120.0")
+		}
+	}
+
+	// driver started by tool doesn't need selenium server
+	if *backend != "selenium" || *webdriver != "" {
+		return
+	}
+
+	u, err := url.Parse(seleniumServerURL())
+	if err != nil || u.Host == "" {
+		problems.add(fmt.Sprintf("selenium URL %s is invalid", seleniumServerURL()), "use URL like http://localhost:4444/wd/hub")
+		return
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "80")
+		if u.Scheme == "https" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+	}
+
+	conn, err := net.DialTimeout("tcp", host, seleniumDialTimeout)
+	if err != nil {
+		problems.add(fmt.Sprintf("selenium server %s isn't reachable: %v", seleniumServerURL(), err), "start selenium server (or Docker image selenium/standalone-firefox), fix --selenium-url, or use --webdriver auto to start driver by tool")
+		return
+	}
+	conn.Close()
+}
+
+// printConfigProblems writes all problems of config with their hints
+func printConfigProblems(problems configProblems) {
+	if len(problems) == 1 {
+		fmt.Fprintln(os.Stderr, "Found 1 problem in configuration:")
+	} else {
+		fmt.Fprintf(os.Stderr, "Found %d problems in configuration:\n", len(problems))
+	}
+	for _, p := range problems {
+		fmt.Fprintf(os.Stderr, "  - %s\n    hint: %s\n", p.problem, p.hint)
+	}
+	fmt.Fprintln(os.Stderr, "Run with --help to see all flags")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"time"
+)
+
+// webhookPayload is a body of request, that is sent to webhook with users of scrapping process
+type webhookPayload struct {
+	Time    time.Time `json:"time"`    // time when scrapping process finished
+	Batch   int       `json:"batch"`   // number of batch, starting from 1
+	Batches int       `json:"batches"` // amount of batches of scrapping process
+	Users   []User    `json:"users"`
+}
+
+// webhookWriter posts users of each scrapping process to webhook in json format, split into batches
+type webhookWriter struct {
+	url       string
+	batchSize int // 0 means all users are sent in one request
+	retries   int
+}
+
+// newWebhookWriter creates writer, that posts users to url
+func newWebhookWriter(url string, batchSize, retries int) *webhookWriter {
+	return &webhookWriter{url: url, batchSize: batchSize, retries: retries}
+}
+
+// Write posts users in batches, each failed request is retried with growing delay
+func (w *webhookWriter) Write(users []User) error {
+	size := w.batchSize
+	if size <= 0 || size > len(users) {
+		size = len(users)
+	}
+
+	batches := 1
+	if size > 0 {
+		batches = (len(users) + size - 1) / size
+	}
+
+	now := time.Now()
+	for i := 0; i < batches; i++ {
+		start := i * size
+		end := start + size
+		if end > len(users) {
+			end = len(users)
+		}
+
+		payload := webhookPayload{
+			Time:    now,
+			Batch:   i + 1,
+			Batches: batches,
+			Users:   users[start:end],
+		}
+		if payload.Users == nil {
+			payload.Users = []User{}
+		}
+
+		err := w.send(payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// send posts payload to webhook, it's retried after 1, 2, 4... seconds
+func (w *webhookWriter) send(payload webhookPayload) error {
+	delay := time.Second
+	for attempt := 0; ; attempt++ {
+		err := sendWebhook(w.url, payload)
+		if err == nil || attempt >= w.retries {
+			return err
+		}
+
+		logger.Warnf("Sending batch %d/%d to webhook: %v, retrying in %v", payload.Batch, payload.Batches, err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
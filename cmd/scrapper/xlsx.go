@@ -0,0 +1,448 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// xlsxIndexSheet is a name of first sheet, that lists all other sheets
+const xlsxIndexSheet = "Index"
+
+// xlsxMaxSheetName is maximum length of sheet name, that Excel allows
+const xlsxMaxSheetName = 31
+
+// xlsxMaxRows is maximum amount of users in sheet, Excel allows 1048576 rows, and first one is a header
+const xlsxMaxRows = excelize.TotalRows - 1
+
+// xlsxEpoch is a day zero of Excel dates
+var xlsxEpoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxRecordWriter writes users into Excel workbooks, either each scrapping process into its own sheet, or each
+// server into its own sheet. Zip archive can't be appended to, so output is a directory with workbook of each day,
+// like <dir>/2026-01-02.xlsx, workbook of current day is replaced after each cycle, and workbooks of previous days
+// are never rewritten, so amount of rows kept in memory and written after each cycle is limited by one day.
+// Workbook of current day, that exists already (eg: tool was restarted), is appended to.
+type xlsxRecordWriter struct {
+	byRun bool
+
+	// dir is a directory of daily workbooks, date is a day of current workbook
+	dir  string
+	date string
+
+	// w gets the whole workbook at once, when writer is closed, if it isn't written into directory
+	w      io.Writer
+	closed bool
+
+	wb *xlsxWorkbook
+}
+
+// newXLSXRecordWriter creates writer of Excel workbook into w, sheets are split by scrapping process if byRun is
+// true, otherwise by server. Workbook can't be appended to, so existing file has to be empty.
+func newXLSXRecordWriter(w io.Writer, byRun bool) (*xlsxRecordWriter, error) {
+	empty, err := outputFileEmpty(w)
+	if err != nil {
+		return nil, err
+	}
+	if !empty {
+		return nil, errors.New("existing xlsx file can't be appended to, as it's a zip archive")
+	}
+
+	return &xlsxRecordWriter{w: w, byRun: byRun}, nil
+}
+
+// newXLSXDatasetWriter creates writer of daily Excel workbooks into dir, it's created, if it doesn't exist
+func newXLSXDatasetWriter(dir string, byRun bool) (*xlsxRecordWriter, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("creating xlsx output directory: %w", err)
+	}
+
+	return &xlsxRecordWriter{dir: dir, byRun: byRun}, nil
+}
+
+func (w *xlsxRecordWriter) Write(users []User) error {
+	now := time.Now().In(statusTimeLocation)
+
+	if w.dir != "" && now.Format("2006-01-02") != w.date {
+		// workbook of previous day was written already, so it's dropped from memory
+		w.date = now.Format("2006-01-02")
+		w.wb = nil
+
+		if _, err := os.Stat(w.path()); err == nil {
+			wb, err := openXLSXWorkbook(w.path())
+			if err != nil {
+				return err
+			}
+			w.wb = wb
+		}
+	}
+	if w.wb == nil {
+		wb, err := newXLSXWorkbook()
+		if err != nil {
+			return err
+		}
+		w.wb = wb
+	}
+
+	if w.byRun {
+		err := w.wb.append(now.Format("2006-01-02 15.04.05"), users, now)
+		if err != nil {
+			return err
+		}
+	} else {
+		// servers keep order of their first users
+		var servers []string
+		byServer := make(map[string][]User)
+		for _, user := range users {
+			if _, ok := byServer[user.Server]; !ok {
+				servers = append(servers, user.Server)
+			}
+			byServer[user.Server] = append(byServer[user.Server], user)
+		}
+
+		for _, server := range servers {
+			err := w.wb.append(server, byServer[server], now)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// other writers get workbook on close
+	if w.dir == "" {
+		return nil
+	}
+
+	// workbook is replaced with new one, so it can be opened in the middle of interval scrapping
+	return writeFileAtomic(w.path(), w.wb.writeTo)
+}
+
+// path returns path of workbook of current day
+func (w *xlsxRecordWriter) path() string {
+	return filepath.Join(w.dir, w.date+".xlsx")
+}
+
+// Close writes workbook into writer, if it isn't written into directory, it can be called several times
+func (w *xlsxRecordWriter) Close() error {
+	if w.closed || w.dir != "" {
+		return nil
+	}
+	w.closed = true
+
+	if w.wb == nil {
+		wb, err := newXLSXWorkbook()
+		if err != nil {
+			return err
+		}
+		w.wb = wb
+	}
+
+	return w.wb.writeTo(w.w)
+}
+
+// xlsxSheet is a sheet of workbook with users
+type xlsxSheet struct {
+	name string
+	rows int // amount of users, header isn't counted
+}
+
+// xlsxWorkbook is a workbook with index sheet, that has links to other sheets, amount of their users and time of
+// their last update, and sheets of users, that have header with output columns
+type xlsxWorkbook struct {
+	f      *excelize.File
+	sheets []*xlsxSheet
+
+	headerStyle int
+	dateStyle   int
+}
+
+// newXLSXWorkbook creates workbook with empty index sheet
+func newXLSXWorkbook() (*xlsxWorkbook, error) {
+	wb := &xlsxWorkbook{f: excelize.NewFile()}
+	wb.f.SetSheetName(wb.f.GetSheetName(0), xlsxIndexSheet)
+
+	err := wb.addStyles()
+	if err != nil {
+		return nil, err
+	}
+
+	err = wb.writeHeader(xlsxIndexSheet, []string{"sheet", "users", "updated"})
+	if err != nil {
+		return nil, err
+	}
+
+	return wb, nil
+}
+
+// openXLSXWorkbook opens existing workbook, that was written by xlsxRecordWriter, so users are added to its sheets.
+// Workbook with different columns can't be appended to, as its rows would be mixed up.
+func openXLSXWorkbook(path string) (*xlsxWorkbook, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening existing xlsx file: %w", err)
+	}
+
+	names := f.GetSheetList()
+	if len(names) == 0 || names[0] != xlsxIndexSheet {
+		return nil, fmt.Errorf("existing xlsx file %s doesn't have %s sheet, it can't be appended to", path, xlsxIndexSheet)
+	}
+
+	wb := &xlsxWorkbook{f: f}
+	for _, name := range names[1:] {
+		rows, err := f.Rows(name)
+		if err != nil {
+			return nil, fmt.Errorf("reading sheet %s of existing xlsx file: %w", name, err)
+		}
+
+		sheet := &xlsxSheet{name: name, rows: -1}
+		for rows.Next() {
+			if sheet.rows == -1 {
+				header, err := rows.Columns()
+				if err != nil {
+					return nil, fmt.Errorf("reading sheet %s of existing xlsx file: %w", name, err)
+				}
+				if strings.Join(header, ",") != strings.Join(outputColumns, ",") {
+					return nil, fmt.Errorf("sheet %s of existing xlsx file has different columns, it can't be appended to", name)
+				}
+			}
+			sheet.rows++
+		}
+		if sheet.rows == -1 {
+			return nil, fmt.Errorf("sheet %s of existing xlsx file doesn't have header, it can't be appended to", name)
+		}
+
+		wb.sheets = append(wb.sheets, sheet)
+	}
+
+	err = wb.addStyles()
+	if err != nil {
+		return nil, err
+	}
+
+	return wb, nil
+}
+
+// addStyles adds bold style of header and date style of status time to workbook
+func (wb *xlsxWorkbook) addStyles() error {
+	var err error
+	wb.headerStyle, err = wb.f.NewStyle(`{"font":{"bold":true}}`)
+	if err != nil {
+		return fmt.Errorf("creating xlsx header style: %w", err)
+	}
+
+	wb.dateStyle, err = wb.f.NewStyle(`{"custom_number_format":"yyyy-mm-dd hh:mm:ss"}`)
+	if err != nil {
+		return fmt.Errorf("creating xlsx date style: %w", err)
+	}
+
+	return nil
+}
+
+// writeHeader writes bold first row of sheet, it's frozen, so it's visible, when sheet is scrolled
+func (wb *xlsxWorkbook) writeHeader(sheet string, columns []string) error {
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = column
+	}
+
+	err := wb.f.SetSheetRow(sheet, "A1", &header)
+	if err != nil {
+		return fmt.Errorf("writing header of sheet %s: %w", sheet, err)
+	}
+
+	last, _ := excelize.CoordinatesToCellName(len(columns), 1)
+	err = wb.f.SetCellStyle(sheet, "A1", last, wb.headerStyle)
+	if err != nil {
+		return fmt.Errorf("writing header of sheet %s: %w", sheet, err)
+	}
+
+	err = wb.f.SetPanes(sheet, `{"freeze":true,"split":false,"x_split":0,"y_split":1,"top_left_cell":"A2","active_pane":"bottomLeft"}`)
+	if err != nil {
+		return fmt.Errorf("freezing header of sheet %s: %w", sheet, err)
+	}
+
+	return nil
+}
+
+// sheet returns sheet, where users of name are added, it's added if it doesn't exist yet. Excel compares sheet names
+// case insensitively, so servers like "Foo" and "foo" share sheet. When sheet is full, users are added to next one,
+// like "Foo (2)".
+func (wb *xlsxWorkbook) sheet(name string) (*xlsxSheet, error) {
+	for n := 1; ; n++ {
+		suffix := ""
+		if n > 1 {
+			suffix = fmt.Sprintf(" (%d)", n)
+		}
+		sheetName := xlsxSheetName(name, suffix)
+
+		var sheet *xlsxSheet
+		for _, s := range wb.sheets {
+			if strings.EqualFold(s.name, sheetName) {
+				sheet = s
+				break
+			}
+		}
+		if sheet == nil {
+			return wb.addSheet(sheetName)
+		}
+		if sheet.rows < xlsxMaxRows {
+			return sheet, nil
+		}
+	}
+}
+
+// addSheet adds sheet with header, and its row to index sheet
+func (wb *xlsxWorkbook) addSheet(name string) (*xlsxSheet, error) {
+	wb.f.NewSheet(name)
+	err := wb.writeHeader(name, outputColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	sheet := &xlsxSheet{name: name}
+	wb.sheets = append(wb.sheets, sheet)
+
+	return sheet, nil
+}
+
+// append adds users to the end of sheet of name, and updates its row of index sheet
+func (wb *xlsxWorkbook) append(name string, users []User, updated time.Time) error {
+	for {
+		sheet, err := wb.sheet(name)
+		if err != nil {
+			return err
+		}
+
+		n := len(users)
+		if n > xlsxMaxRows-sheet.rows {
+			n = xlsxMaxRows - sheet.rows
+		}
+
+		err = wb.writeUsers(sheet, users[:n])
+		if err != nil {
+			return err
+		}
+
+		err = wb.writeIndexRow(sheet, updated)
+		if err != nil {
+			return err
+		}
+
+		users = users[n:]
+		if len(users) == 0 {
+			return nil
+		}
+	}
+}
+
+// writeUsers writes users after last row of sheet, status time is written as Excel date, so it isn't parsed
+// differently with different locale settings, as it happens with csv
+func (wb *xlsxWorkbook) writeUsers(sheet *xlsxSheet, users []User) error {
+	for _, user := range users {
+		row := sheet.rows + 2
+		values := make([]interface{}, len(outputColumns))
+		for i, column := range outputColumns {
+			if column == "status_time" {
+				values[i] = xlsxDate(user.StatusTime.In(statusTimeLocation))
+				continue
+			}
+			values[i] = userValue(user, column)
+		}
+
+		err := wb.f.SetSheetRow(sheet.name, fmt.Sprintf("A%d", row), &values)
+		if err != nil {
+			return fmt.Errorf("writing row of sheet %s: %w", sheet.name, err)
+		}
+		sheet.rows++
+	}
+
+	for i, column := range outputColumns {
+		if column != "status_time" || len(users) == 0 {
+			continue
+		}
+
+		first, _ := excelize.CoordinatesToCellName(i+1, sheet.rows-len(users)+2)
+		last, _ := excelize.CoordinatesToCellName(i+1, sheet.rows+1)
+		err := wb.f.SetCellStyle(sheet.name, first, last, wb.dateStyle)
+		if err != nil {
+			return fmt.Errorf("writing status time of sheet %s: %w", sheet.name, err)
+		}
+	}
+
+	return nil
+}
+
+// writeIndexRow writes row of sheet in index sheet: link to sheet, amount of its users and time of last update
+func (wb *xlsxWorkbook) writeIndexRow(sheet *xlsxSheet, updated time.Time) error {
+	row := 0
+	for i, s := range wb.sheets {
+		if s == sheet {
+			row = i + 2
+		}
+	}
+
+	values := []interface{}{sheet.name, sheet.rows, xlsxDate(updated)}
+	err := wb.f.SetSheetRow(xlsxIndexSheet, fmt.Sprintf("A%d", row), &values)
+	if err != nil {
+		return fmt.Errorf("writing index of sheet %s: %w", sheet.name, err)
+	}
+
+	err = wb.f.SetCellHyperLink(xlsxIndexSheet, fmt.Sprintf("A%d", row), fmt.Sprintf("'%s'!A1", sheet.name), "Location")
+	if err != nil {
+		return fmt.Errorf("writing index of sheet %s: %w", sheet.name, err)
+	}
+
+	err = wb.f.SetCellStyle(xlsxIndexSheet, fmt.Sprintf("C%d", row), fmt.Sprintf("C%d", row), wb.dateStyle)
+	if err != nil {
+		return fmt.Errorf("writing index of sheet %s: %w", sheet.name, err)
+	}
+
+	return nil
+}
+
+// writeTo writes workbook as xlsx file into dst, first sheet is active, so index is shown, when workbook is opened
+func (wb *xlsxWorkbook) writeTo(dst io.Writer) error {
+	wb.f.SetActiveSheet(0)
+
+	_, err := wb.f.WriteTo(dst)
+	if err != nil {
+		return fmt.Errorf("writing xlsx file: %w", err)
+	}
+
+	return nil
+}
+
+// xlsxDate returns time as Excel date (amount of days since its epoch), in time zone of time
+func xlsxDate(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+
+	_, offset := t.Zone()
+	local := t.UTC().Add(time.Duration(offset) * time.Second)
+
+	return local.Sub(xlsxEpoch).Hours() / 24
+}
+
+// xlsxSheetName removes characters that Excel doesn't allow in sheet names, and shortens name to allowed length, so
+// suffix is kept at the end of name
+func xlsxSheetName(name, suffix string) string {
+	name = strings.NewReplacer("[", "", "]", "", ":", "", "*", "", "?", "", "/", "", `\`, "", "'", "").Replace(name)
+	if name == "" || strings.EqualFold(name, xlsxIndexSheet) {
+		name = "_" + name
+	}
+
+	runes := []rune(name)
+	if max := xlsxMaxSheetName - len([]rune(suffix)); len(runes) > max {
+		runes = runes[:max]
+	}
+
+	return string(runes) + suffix
+}
@@ -1,9 +1,22 @@
 module github.com/bejaneps/discord-user-monitor
 
-go 1.14
+go 1.15
 
 require (
-	github.com/jszwec/csvutil v1.3.1-0.20200626204610-43c0fc69ef2a
+	filippo.io/age v1.0.0
+	github.com/BurntSushi/toml v0.4.1
+	github.com/chromedp/cdproto v0.0.0-20210122124816-7a656c010d57
+	github.com/chromedp/chromedp v0.6.5
+	github.com/gobwas/ws v1.0.4
+	github.com/lib/pq v1.10.9
 	github.com/spf13/pflag v1.0.5
 	github.com/tebeka/selenium v0.9.9
+	github.com/xitongsys/parquet-go v1.6.0
+	github.com/xuri/excelize/v2 v2.4.1
+	golang.org/x/sys v0.0.0-20211007075335-d3039528d8ac
+	golang.org/x/term v0.0.0-20210615171337-6886f2dfbf5b
+	google.golang.org/grpc v1.43.0
+	google.golang.org/protobuf v1.27.1
+	gopkg.in/yaml.v3 v3.0.1
+	modernc.org/sqlite v1.14.0
 )